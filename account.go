@@ -0,0 +1,119 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Account scopes requests and events to a single trader account. Many accounts can share the same client, each one
+// is identified by its 'ctidTraderAccountId'.
+type Account struct {
+	ID     int64
+	client *Client
+}
+
+// Account returns a handle to the trader account. The account still needs to be authorized before it's used.
+func (c *Client) Account(id int64) *Account {
+	return &Account{ID: id, client: c}
+}
+
+// Client returns the client used by the account.
+func (a *Account) Client() *Client {
+	return a.client
+}
+
+// Authorize authorizes the account using an access token that has access to it.
+func (a *Account) Authorize(ctx context.Context, accessToken string) error {
+	req := &openapi.ProtoOAAccountAuthReq{AccessToken: &accessToken}
+	_, err := AccountCommand[*openapi.ProtoOAAccountAuthReq, *openapi.ProtoOAAccountAuthRes](ctx, a, req)
+	if err != nil {
+		return fmt.Errorf("failed to authorize the account: %w", err)
+	}
+	return nil
+}
+
+// Logout ends the account session, the account subscriptions are removed as well.
+func (a *Account) Logout(ctx context.Context) error {
+	req := &openapi.ProtoOAAccountLogoutReq{}
+	_, err := AccountCommand[*openapi.ProtoOAAccountLogoutReq, *openapi.ProtoOAAccountLogoutRes](ctx, a, req)
+	if err != nil {
+		return fmt.Errorf("failed to logout the account: %w", err)
+	}
+	return nil
+}
+
+// AccountCommand is the account scoped version of Command. The 'ctidTraderAccountId' field of the request is filled
+// with the account ID, the request itself is not modified.
+//
+// nolint ireturn
+func AccountCommand[A, B proto.Message](ctx context.Context, a *Account, req A) (B, error) {
+	scopedReq, err := accountScope(a, req)
+	if err != nil {
+		return *new(B), err
+	}
+	return Command[A, B](ctx, a.client, scopedReq)
+}
+
+// accountScope returns a copy of the request with the 'ctidTraderAccountId' field set to the account ID.
+//
+// nolint ireturn
+func accountScope[T proto.Message](a *Account, req T) (T, error) {
+	scopedReq, ok := proto.Clone(req).(T)
+	if !ok {
+		return req, fmt.Errorf("unexpected request type '%T'", req)
+	}
+	field := scopedReq.ProtoReflect().Descriptor().Fields().ByName(accountIDField)
+	if field == nil || field.Kind() != protoreflect.Int64Kind || field.IsList() {
+		return req, fmt.Errorf("request '%T' is not account scoped", req)
+	}
+	m := scopedReq.ProtoReflect()
+	if m.Has(field) && m.Get(field).Int() != a.ID {
+		return req, fmt.Errorf("request is scoped to the account '%d' instead of '%d'", m.Get(field).Int(), a.ID)
+	}
+	m.Set(field, protoreflect.ValueOfInt64(a.ID))
+	return scopedReq, nil
+}
+
+// AccountOn is the account scoped version of On. Only events that carry the account ID are delivered, events without
+// an account, like '*openapi.ProtoHeartbeatEvent', are ignored.
+func AccountOn[T proto.Message](a *Account, fn func(T)) (unsubscribe func()) {
+	return On(a.client, func(v T) {
+		if eventHasAccount(v, a.ID) {
+			fn(v)
+		}
+	})
+}
+
+// AccountEvents is the account scoped version of Events.
+func AccountEvents[T proto.Message](a *Account, size int) (<-chan T, func()) {
+	return eventChannel(a.client, size, func(fn func(T)) func() { return AccountOn(a, fn) })
+}
+
+const (
+	accountIDField  protoreflect.Name = "ctidTraderAccountId"
+	accountIDsField protoreflect.Name = "ctidTraderAccountIds"
+)
+
+// eventHasAccount checks if the message belongs to the account, some events, like
+// '*openapi.ProtoOAAccountsTokenInvalidatedEvent', are sent for many accounts at once.
+func eventHasAccount(m proto.Message, accountID int64) bool {
+	r := m.ProtoReflect()
+	fields := r.Descriptor().Fields()
+	if field := fields.ByName(accountIDField); field != nil && field.Kind() == protoreflect.Int64Kind {
+		return r.Has(field) && r.Get(field).Int() == accountID
+	}
+	if field := fields.ByName(accountIDsField); field != nil && field.IsList() {
+		list := r.Get(field).List()
+		for i := 0; i < list.Len(); i++ {
+			if list.Get(i).Int() == accountID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestAccount(t *testing.T) {
+	t.Parallel()
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+	ctx := context.Background()
+	account := c.Account(7)
+
+	t.Run("Should fill the account ID", func(t *testing.T) {
+		require.NoError(t, account.Authorize(ctx, "token"))
+		req := &openapi.ProtoOASubscribeSpotsReq{SymbolId: []int64{1}}
+		resp, err := AccountCommand[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](ctx, account, req)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), resp.GetCtidTraderAccountId())
+		require.Nil(t, req.CtidTraderAccountId)
+		require.NoError(t, account.Logout(ctx))
+
+		var accountIDs []int64
+		for _, sent := range transport.sent() {
+			if v, ok := sent.(interface{ GetCtidTraderAccountId() int64 }); ok {
+				accountIDs = append(accountIDs, v.GetCtidTraderAccountId())
+			}
+		}
+		require.Equal(t, []int64{7, 7, 7}, accountIDs)
+	})
+
+	t.Run("Should refuse requests from other accounts", func(t *testing.T) {
+		req := &openapi.ProtoOASubscribeSpotsReq{CtidTraderAccountId: lo.ToPtr(int64(8))}
+		_, err := AccountCommand[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](ctx, account, req)
+		require.ErrorContains(t, err, "scoped to the account '8'")
+
+		_, err = AccountCommand[*openapi.ProtoOAVersionReq, *openapi.ProtoOAVersionRes](
+			ctx, account, &openapi.ProtoOAVersionReq{},
+		)
+		require.ErrorContains(t, err, "not account scoped")
+	})
+
+	t.Run("Should only deliver the account events", func(t *testing.T) {
+		var symbols []int64
+		unsubscribe := AccountOn(account, func(e *openapi.ProtoOASpotEvent) {
+			symbols = append(symbols, e.GetSymbolId())
+		})
+		defer unsubscribe()
+		var events []proto.Message
+		unsubscribeAll := AccountOn(account, func(e proto.Message) { events = append(events, e) })
+		defer unsubscribeAll()
+
+		transport.event(&openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(7)), SymbolId: lo.ToPtr(int64(1))})
+		transport.event(&openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(8)), SymbolId: lo.ToPtr(int64(2))})
+		transport.event(&openapi.ProtoHeartbeatEvent{})
+		transport.event(&openapi.ProtoOAAccountsTokenInvalidatedEvent{CtidTraderAccountIds: []int64{9, 7}})
+		transport.event(&openapi.ProtoOAAccountsTokenInvalidatedEvent{CtidTraderAccountIds: []int64{9}})
+		require.Equal(t, []int64{1}, symbols)
+		require.Len(t, events, 2)
+		require.IsType(t, &openapi.ProtoOAAccountsTokenInvalidatedEvent{}, events[1])
+	})
+}
@@ -0,0 +1,229 @@
+package ctrader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// BarType is the rule used to close the bars of a BarAggregator.
+type BarType int
+
+const (
+	// TimeBars close at the end of a fixed duration, aligned to the start of the session.
+	TimeBars BarType = iota
+
+	// TickBars close after a number of price updates.
+	TickBars
+
+	// VolumeBars close when the sum of the volume of the updates reaches a threshold.
+	VolumeBars
+
+	// RangeBars close when the difference between the high and the low reaches a range.
+	RangeBars
+)
+
+// PriceSource is the price of the quotes used to build the bars.
+type PriceSource int
+
+const (
+	// SourceBid uses the bid, like the trendbars of the server.
+	SourceBid PriceSource = iota
+
+	// SourceAsk uses the ask.
+	SourceAsk
+
+	// SourceMid uses the price between the bid and the ask.
+	SourceMid
+)
+
+// BarSpec configures a BarAggregator. Only the field of the bar type is used.
+type BarSpec struct {
+	Type   BarType
+	Source PriceSource
+
+	// Duration of the time bars, up to a day, like 90 seconds or 2 minutes.
+	Duration time.Duration
+
+	// SessionStart is the time of the day, at Location, when the session starts. The time bars are aligned to it and
+	// the last bar of the session is shorter when the duration doesn't divide the day. Location defaults to UTC.
+	SessionStart time.Duration
+	Location     *time.Location
+
+	// Ticks is the number of updates of the tick bars.
+	Ticks int64
+
+	// Volume is the volume of the volume bars.
+	Volume int64
+
+	// Range is the difference between the high and the low of the range bars.
+	Range Price
+}
+
+// BarAggregator builds candles from quotes, for periods not offered by 'ProtoOATrendbarPeriod' or bars that are not
+// based on time. It's not safe for concurrent use.
+//
+// The candle Volume is the number of updates, like the trendbars, except at the volume bars where it's the sum of
+// the volume of the updates. The candles of the time bars open at the start of the bar and have its duration as
+// Period, the other candles open at the time of the first update and don't have a period.
+type BarAggregator struct {
+	spec    BarSpec
+	bid     Price
+	ask     Price
+	forming *Candle
+	end     time.Time
+	now     func() time.Time
+}
+
+// NewBarAggregator returns an aggregator of the bars described by the spec.
+func NewBarAggregator(spec BarSpec) (*BarAggregator, error) {
+	switch spec.Type {
+	case TimeBars:
+		if spec.Duration <= 0 || spec.Duration > 24*time.Hour {
+			return nil, errors.New("the duration of the time bars should be between zero and a day")
+		}
+		if spec.SessionStart < 0 || spec.SessionStart >= 24*time.Hour {
+			return nil, errors.New("the session start should be a time of the day")
+		}
+	case TickBars:
+		if spec.Ticks <= 0 {
+			return nil, errors.New("the number of ticks should be positive")
+		}
+	case VolumeBars:
+		if spec.Volume <= 0 {
+			return nil, errors.New("the volume should be positive")
+		}
+	case RangeBars:
+		if spec.Range <= 0 {
+			return nil, errors.New("the range should be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown bar type '%d'", spec.Type)
+	}
+	if spec.Source < SourceBid || spec.Source > SourceMid {
+		return nil, fmt.Errorf("unknown price source '%d'", spec.Source)
+	}
+	if spec.Location == nil {
+		spec.Location = time.UTC
+	}
+	return &BarAggregator{spec: spec, now: time.Now}, nil
+}
+
+// AddSpot updates the aggregator with a spot event and returns the bar closed by it. The spot events only have the
+// prices that changed, the previous ones are used for the others. The time is the event timestamp, when subscribed
+// with 'subscribeToSpotTimestamp', or the current time. The spot events don't have the traded volume, so each one
+// counts as one at the volume bars.
+func (a *BarAggregator) AddSpot(e *openapi.ProtoOASpotEvent) (Candle, bool) {
+	if e.Bid == nil && e.Ask == nil {
+		return Candle{}, false
+	}
+	t := a.now()
+	if e.Timestamp != nil {
+		t = time.UnixMilli(e.GetTimestamp())
+	}
+	return a.Add(Quote{Time: t, Bid: Price(e.GetBid()), Ask: Price(e.GetAsk())}, 1)
+}
+
+// Add updates the aggregator with a quote and returns the bar closed by it. A zero bid or ask keeps the previous one.
+// The volume is only used by the volume bars. The quotes are ignored until the prices of the source are known.
+func (a *BarAggregator) Add(q Quote, volume int64) (Candle, bool) {
+	if q.Bid != 0 {
+		a.bid = q.Bid
+	}
+	if q.Ask != 0 {
+		a.ask = q.Ask
+	}
+	price, ok := a.price()
+	if !ok {
+		return Candle{}, false
+	}
+
+	var (
+		closed   Candle
+		isClosed bool
+	)
+	if a.spec.Type == TimeBars {
+		closed, isClosed = a.Advance(q.Time)
+		if a.forming == nil {
+			start, end := a.boundaries(q.Time)
+			a.forming = &Candle{Time: start, Period: end.Sub(start), Open: price, High: price, Low: price}
+			a.end = end
+		}
+	} else if a.forming == nil {
+		a.forming = &Candle{Time: q.Time.UTC(), Open: price, High: price, Low: price}
+	}
+
+	a.forming.High = max(a.forming.High, price)
+	a.forming.Low = min(a.forming.Low, price)
+	a.forming.Close = price
+	if a.spec.Type == VolumeBars {
+		a.forming.Volume += volume
+	} else {
+		a.forming.Volume++
+	}
+
+	var full bool
+	switch a.spec.Type {
+	case TickBars:
+		full = a.forming.Volume >= a.spec.Ticks
+	case VolumeBars:
+		full = a.forming.Volume >= a.spec.Volume
+	case RangeBars:
+		full = a.forming.High-a.forming.Low >= a.spec.Range
+	case TimeBars:
+	}
+	if full {
+		closed, isClosed = *a.forming, true
+		a.forming = nil
+	}
+	return closed, isClosed
+}
+
+// Advance closes the time bar when its end is before or at the time. It's used to close the bars of the periods
+// without updates, the bars of other types are never closed by time.
+func (a *BarAggregator) Advance(t time.Time) (Candle, bool) {
+	if a.spec.Type != TimeBars || a.forming == nil || t.Before(a.end) {
+		return Candle{}, false
+	}
+	closed := *a.forming
+	a.forming = nil
+	return closed, true
+}
+
+// Forming returns the bar that is not closed yet.
+func (a *BarAggregator) Forming() (Candle, bool) {
+	if a.forming == nil {
+		return Candle{}, false
+	}
+	return *a.forming, true
+}
+
+func (a *BarAggregator) price() (Price, bool) {
+	switch a.spec.Source {
+	case SourceAsk:
+		return a.ask, a.ask != 0
+	case SourceMid:
+		return a.bid + (a.ask-a.bid)/2, a.bid != 0 && a.ask != 0
+	default:
+		return a.bid, a.bid != 0
+	}
+}
+
+// boundaries returns the start and end of the time bar of the time. The bars are counted from the start of the
+// session, which is found with the calendar of the location to follow the daylight saving time changes.
+func (a *BarAggregator) boundaries(t time.Time) (time.Time, time.Time) {
+	local := t.In(a.spec.Location)
+	session := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, int(a.spec.SessionStart), a.spec.Location)
+	if session.After(local) {
+		session = session.AddDate(0, 0, -1)
+	}
+	next := session.AddDate(0, 0, 1)
+	start := session.Add(local.Sub(session) / a.spec.Duration * a.spec.Duration)
+	end := start.Add(a.spec.Duration)
+	if end.After(next) {
+		end = next
+	}
+	return start.UTC(), end.UTC()
+}
@@ -0,0 +1,151 @@
+package ctrader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestBarAggregator(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	// aggregate adds the prices, one per second, and returns the closed bars.
+	aggregate := func(t *testing.T, a *BarAggregator, prices ...Price) []Candle {
+		t.Helper()
+		var candles []Candle
+		for i, price := range prices {
+			q := Quote{Time: start.Add(time.Duration(i) * time.Second), Bid: price, Ask: price + 2}
+			if candle, ok := a.Add(q, 10); ok {
+				candles = append(candles, candle)
+			}
+		}
+		return candles
+	}
+
+	t.Run("Should build the time bars", func(t *testing.T) {
+		t.Parallel()
+		a, err := NewBarAggregator(BarSpec{Type: TimeBars, Duration: 90 * time.Second})
+		require.NoError(t, err)
+		prices := make([]Price, 200)
+		for i := range prices {
+			prices[i] = Price(100 + i%7)
+		}
+		candles := aggregate(t, a, prices...)
+		require.Len(t, candles, 2)
+		require.Equal(t, Candle{
+			Time: start, Period: 90 * time.Second, Open: 100, High: 106, Low: 100, Close: 105, Volume: 90,
+		}, candles[0])
+		require.Equal(t, start.Add(90*time.Second), candles[1].Time)
+
+		forming, ok := a.Forming()
+		require.True(t, ok)
+		require.Equal(t, int64(20), forming.Volume)
+		_, ok = a.Advance(start.Add(269 * time.Second))
+		require.False(t, ok)
+		closed, ok := a.Advance(start.Add(270 * time.Second))
+		require.True(t, ok)
+		require.Equal(t, forming, closed)
+		_, ok = a.Forming()
+		require.False(t, ok)
+	})
+
+	t.Run("Should align the time bars to the session", func(t *testing.T) {
+		t.Parallel()
+		location, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		a, err := NewBarAggregator(BarSpec{
+			Type: TimeBars, Duration: 7 * time.Hour, SessionStart: 17 * time.Hour, Location: location,
+		})
+		require.NoError(t, err)
+
+		// 2024-03-10 has the daylight saving time change, the session starts at 21:00 UTC instead of 22:00 UTC.
+		for _, tc := range []struct {
+			at    time.Time
+			start time.Time
+			end   time.Time
+		}{
+			{
+				at:    time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC),
+				start: time.Date(2024, 3, 8, 22, 0, 0, 0, time.UTC),
+				end:   time.Date(2024, 3, 9, 5, 0, 0, 0, time.UTC),
+			},
+			{
+				at:    time.Date(2024, 3, 9, 20, 0, 0, 0, time.UTC),
+				start: time.Date(2024, 3, 9, 19, 0, 0, 0, time.UTC),
+				end:   time.Date(2024, 3, 9, 22, 0, 0, 0, time.UTC),
+			},
+			{
+				at:    time.Date(2024, 3, 10, 21, 30, 0, 0, time.UTC),
+				start: time.Date(2024, 3, 10, 21, 0, 0, 0, time.UTC),
+				end:   time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC),
+			},
+		} {
+			start, end := a.boundaries(tc.at)
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.end, end)
+		}
+	})
+
+	t.Run("Should build the tick, volume and range bars", func(t *testing.T) {
+		t.Parallel()
+		ticks, err := NewBarAggregator(BarSpec{Type: TickBars, Ticks: 3, Source: SourceAsk})
+		require.NoError(t, err)
+		require.Equal(t, []Candle{
+			{Time: start, Open: 12, High: 13, Low: 11, Close: 11, Volume: 3},
+			{Time: start.Add(3 * time.Second), Open: 15, High: 16, Low: 15, Close: 15, Volume: 3},
+		}, aggregate(t, ticks, 10, 11, 9, 13, 14, 13, 1))
+
+		volume, err := NewBarAggregator(BarSpec{Type: VolumeBars, Volume: 25})
+		require.NoError(t, err)
+		require.Equal(t, []Candle{
+			{Time: start, Open: 10, High: 11, Low: 9, Close: 9, Volume: 30},
+		}, aggregate(t, volume, 10, 11, 9, 13, 14))
+
+		ranges, err := NewBarAggregator(BarSpec{Type: RangeBars, Range: 5, Source: SourceMid})
+		require.NoError(t, err)
+		require.Equal(t, []Candle{
+			{Time: start, Open: 11, High: 16, Low: 11, Close: 16, Volume: 4},
+			{Time: start.Add(4 * time.Second), Open: 13, High: 13, Low: 8, Close: 8, Volume: 2},
+		}, aggregate(t, ranges, 10, 12, 14, 15, 12, 7, 8))
+	})
+
+	t.Run("Should use the spot events", func(t *testing.T) {
+		t.Parallel()
+		a, err := NewBarAggregator(BarSpec{Type: TickBars, Ticks: 2, Source: SourceMid})
+		require.NoError(t, err)
+		_, ok := a.AddSpot(&openapi.ProtoOASpotEvent{Bid: lo.ToPtr(uint64(100))})
+		require.False(t, ok)
+		_, ok = a.Forming()
+		require.False(t, ok)
+
+		_, ok = a.AddSpot(&openapi.ProtoOASpotEvent{Ask: lo.ToPtr(uint64(110)), Timestamp: lo.ToPtr(start.UnixMilli())})
+		require.False(t, ok)
+		_, ok = a.AddSpot(&openapi.ProtoOASpotEvent{})
+		require.False(t, ok)
+		candle, ok := a.AddSpot(&openapi.ProtoOASpotEvent{Bid: lo.ToPtr(uint64(104))})
+		require.True(t, ok)
+		require.Equal(t, Candle{Time: start, Open: 105, High: 107, Low: 105, Close: 107, Volume: 2}, candle)
+	})
+
+	t.Run("Should validate the spec", func(t *testing.T) {
+		t.Parallel()
+		for _, spec := range []BarSpec{
+			{Type: TimeBars},
+			{Type: TimeBars, Duration: 48 * time.Hour},
+			{Type: TimeBars, Duration: time.Hour, SessionStart: 25 * time.Hour},
+			{Type: TickBars},
+			{Type: VolumeBars},
+			{Type: RangeBars},
+			{Type: BarType(10)},
+			{Type: TickBars, Ticks: 1, Source: PriceSource(10)},
+		} {
+			_, err := NewBarAggregator(spec)
+			require.Error(t, err)
+		}
+	})
+}
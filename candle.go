@@ -0,0 +1,146 @@
+package ctrader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// PeriodMonth is the nominal duration of the MN1 period. Monthly candles don't have a fixed duration, Candle.End
+// uses the calendar to find the end of them.
+const PeriodMonth = 30 * 24 * time.Hour
+
+// PeriodDuration returns the duration of a trendbar period. MN1 returns PeriodMonth.
+func PeriodDuration(period openapi.ProtoOATrendbarPeriod) (time.Duration, error) {
+	switch period {
+	case openapi.ProtoOATrendbarPeriod_M1:
+		return time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M2:
+		return 2 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M3:
+		return 3 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M4:
+		return 4 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M5:
+		return 5 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M10:
+		return 10 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M15:
+		return 15 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_M30:
+		return 30 * time.Minute, nil
+	case openapi.ProtoOATrendbarPeriod_H1:
+		return time.Hour, nil
+	case openapi.ProtoOATrendbarPeriod_H4:
+		return 4 * time.Hour, nil
+	case openapi.ProtoOATrendbarPeriod_H12:
+		return 12 * time.Hour, nil
+	case openapi.ProtoOATrendbarPeriod_D1:
+		return 24 * time.Hour, nil
+	case openapi.ProtoOATrendbarPeriod_W1:
+		return 7 * 24 * time.Hour, nil
+	case openapi.ProtoOATrendbarPeriod_MN1:
+		return PeriodMonth, nil
+	default:
+		return 0, fmt.Errorf("unknown trendbar period '%d'", period)
+	}
+}
+
+// TrendbarPeriod returns the trendbar period of a duration, it's the inverse of PeriodDuration.
+func TrendbarPeriod(d time.Duration) (openapi.ProtoOATrendbarPeriod, error) {
+	for value := range openapi.ProtoOATrendbarPeriod_name {
+		period := openapi.ProtoOATrendbarPeriod(value)
+		if duration, err := PeriodDuration(period); err == nil && duration == d {
+			return period, nil
+		}
+	}
+	return 0, fmt.Errorf("there is no trendbar period of '%s'", d)
+}
+
+// Candle is a decoded 'openapi.ProtoOATrendbar'. The prices are the bid prices, like at the trendbars.
+type Candle struct {
+	// Time is when the candle opens.
+	Time   time.Time
+	Period time.Duration
+	Open   Price
+	High   Price
+	Low    Price
+	Close  Price
+
+	// Volume is the number of ticks.
+	Volume int64
+}
+
+// End returns when the candle closes.
+func (c Candle) End() time.Time {
+	if c.Period == PeriodMonth {
+		return c.Time.AddDate(0, 1, 0)
+	}
+	return c.Time.Add(c.Period)
+}
+
+// NewCandle decodes a trendbar.
+func NewCandle(t *openapi.ProtoOATrendbar) (Candle, error) {
+	if t.UtcTimestampInMinutes == nil {
+		return Candle{}, errors.New("trendbar without timestamp")
+	}
+	period, err := PeriodDuration(t.GetPeriod())
+	if err != nil {
+		return Candle{}, err
+	}
+	low := Price(t.GetLow())
+	return Candle{
+		Time:   time.Unix(int64(t.GetUtcTimestampInMinutes())*60, 0).UTC(),
+		Period: period,
+		Open:   low + Price(t.GetDeltaOpen()),
+		High:   low + Price(t.GetDeltaHigh()),
+		Low:    low,
+		Close:  low + Price(t.GetDeltaClose()),
+		Volume: t.GetVolume(),
+	}, nil
+}
+
+// CandlesFromTrendbars decodes the trendbars of a 'ProtoOAGetTrendbarsRes'. The trendbars without a period get the
+// period of the response.
+func CandlesFromTrendbars(res *openapi.ProtoOAGetTrendbarsRes) ([]Candle, error) {
+	candles := make([]Candle, 0, len(res.GetTrendbar()))
+	for _, trendbar := range res.GetTrendbar() {
+		if trendbar.Period == nil && res.Period != nil {
+			trendbar = &openapi.ProtoOATrendbar{
+				Volume:                trendbar.Volume,
+				Period:                res.Period,
+				Low:                   trendbar.Low,
+				DeltaOpen:             trendbar.DeltaOpen,
+				DeltaClose:            trendbar.DeltaClose,
+				DeltaHigh:             trendbar.DeltaHigh,
+				UtcTimestampInMinutes: trendbar.UtcTimestampInMinutes,
+			}
+		}
+		candle, err := NewCandle(trendbar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the trendbar: %w", err)
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// CandlesFromSpot decodes the live trendbars of a 'ProtoOASpotEvent', one for each period subscribed with
+// 'ProtoOASubscribeLiveTrendbarReq'. The live trendbars don't have the close price, it's the bid of the event. When
+// the event don't have the bid, because it didn't change, the close is only set if the trendbar has it.
+func CandlesFromSpot(e *openapi.ProtoOASpotEvent) ([]Candle, error) {
+	candles := make([]Candle, 0, len(e.GetTrendbar()))
+	for _, trendbar := range e.GetTrendbar() {
+		candle, err := NewCandle(trendbar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the trendbar: %w", err)
+		}
+		if trendbar.DeltaClose == nil && e.Bid != nil {
+			candle.Close = Price(e.GetBid())
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
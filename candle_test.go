@@ -0,0 +1,96 @@
+package ctrader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestPeriodDuration(t *testing.T) {
+	t.Parallel()
+
+	for value := range openapi.ProtoOATrendbarPeriod_name {
+		period := openapi.ProtoOATrendbarPeriod(value)
+		duration, err := PeriodDuration(period)
+		require.NoError(t, err)
+		result, err := TrendbarPeriod(duration)
+		require.NoError(t, err)
+		require.Equal(t, period, result)
+	}
+
+	duration, err := PeriodDuration(openapi.ProtoOATrendbarPeriod_H4)
+	require.NoError(t, err)
+	require.Equal(t, 4*time.Hour, duration)
+
+	_, err = PeriodDuration(openapi.ProtoOATrendbarPeriod(100))
+	require.Error(t, err)
+	_, err = TrendbarPeriod(7 * time.Minute)
+	require.Error(t, err)
+}
+
+func TestCandle(t *testing.T) {
+	t.Parallel()
+
+	timestamp := uint32(time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC).Unix() / 60)
+
+	t.Run("Should decode the trendbars", func(t *testing.T) {
+		t.Parallel()
+		candles, err := CandlesFromTrendbars(&openapi.ProtoOAGetTrendbarsRes{
+			Period: openapi.ProtoOATrendbarPeriod_H1.Enum(),
+			Trendbar: []*openapi.ProtoOATrendbar{
+				{
+					Volume:                lo.ToPtr(int64(42)),
+					Low:                   lo.ToPtr(int64(108000)),
+					DeltaOpen:             lo.ToPtr(uint64(10)),
+					DeltaHigh:             lo.ToPtr(uint64(50)),
+					DeltaClose:            lo.ToPtr(uint64(20)),
+					UtcTimestampInMinutes: lo.ToPtr(timestamp),
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Candle{
+			{
+				Time:   time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC),
+				Period: time.Hour,
+				Open:   108010,
+				High:   108050,
+				Low:    108000,
+				Close:  108020,
+				Volume: 42,
+			},
+		}, candles)
+		require.Equal(t, time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC), candles[0].End())
+
+		_, err = CandlesFromTrendbars(&openapi.ProtoOAGetTrendbarsRes{
+			Trendbar: []*openapi.ProtoOATrendbar{{Volume: lo.ToPtr(int64(1))}},
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Should decode the live trendbars", func(t *testing.T) {
+		t.Parallel()
+		candles, err := CandlesFromSpot(&openapi.ProtoOASpotEvent{
+			Bid: lo.ToPtr(uint64(108030)),
+			Trendbar: []*openapi.ProtoOATrendbar{
+				{
+					Volume:                lo.ToPtr(int64(7)),
+					Period:                openapi.ProtoOATrendbarPeriod_MN1.Enum(),
+					Low:                   lo.ToPtr(int64(108000)),
+					DeltaOpen:             lo.ToPtr(uint64(10)),
+					DeltaHigh:             lo.ToPtr(uint64(50)),
+					UtcTimestampInMinutes: lo.ToPtr(uint32(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).Unix() / 60)),
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, candles, 1)
+		require.Equal(t, Price(108030), candles[0].Close)
+		require.Equal(t, PeriodMonth, candles[0].Period)
+		require.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), candles[0].End())
+	})
+}
@@ -0,0 +1,352 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// defaultCandleStreamBuffer is the size of the channel of spot events used by a candle stream.
+const defaultCandleStreamBuffer = 1024
+
+// CandleEventType is the type of a CandleEvent.
+type CandleEventType int
+
+const (
+	// BarUpdated is sent when the forming candle changes, its values are not final.
+	BarUpdated CandleEventType = iota
+
+	// BarClosed is sent once per candle, in order, when the candle is final.
+	BarClosed
+)
+
+// String returns the name of the event type.
+func (t CandleEventType) String() string {
+	if t == BarClosed {
+		return "BarClosed"
+	}
+	return "BarUpdated"
+}
+
+// CandleEvent is a change of a candle stream.
+type CandleEvent struct {
+	Type   CandleEventType
+	Candle Candle
+}
+
+// CandleStreamQuery selects the candles of a stream.
+type CandleStreamQuery struct {
+	SymbolID int64
+	Period   openapi.ProtoOATrendbarPeriod
+
+	// From is the open time of the first candle. The candles before the live ones are downloaded with
+	// 'ProtoOAGetTrendbarsReq', the stream starts at the live candles when it's zero.
+	From time.Time
+
+	// Buffer is the size of the channels of spot events and of candle events, defaults to 1024. The spot events are
+	// dropped when the stream falls behind, which happens when the candle events are not received or while the
+	// missing candles are downloaded.
+	Buffer int
+}
+
+// CandleStream streams the candles of a symbol and period. The live trendbars, sent at the spot events after
+// 'ProtoOASubscribeLiveTrendbarReq', don't say when a candle is closed, so the forming candle is considered closed
+// when a trendbar of a newer time arrives.
+//
+// When the query has a start, the history is sent first as closed candles and the live trendbars received while it
+// was downloaded are applied after it. The candles missed between the history and the live trendbars, or while the
+// connection was lost, are downloaded, so the closed candles are a series without gaps. Periods without trades don't
+// have candles.
+//
+// The spot events dropped because the stream fell behind are counted at Dropped. When a candle had updates dropped,
+// it's downloaded again once it's closed, so the closed candles are final even then.
+//
+// There should be a single stream per symbol and period, because the server has a single live trendbar
+// subscription for them.
+type CandleStream struct {
+	account *Account
+	query   CandleStreamQuery
+	now     func() time.Time
+
+	subscription *SpotSubscription
+	events       chan CandleEvent
+	cancel       context.CancelFunc
+	done         chan struct{}
+	closeOnce    sync.Once
+	closeErr     error
+	err          error
+
+	// forming is the candle not closed yet and next is when the next candle can open, while dropped is the number of
+	// spot events dropped when the last candle was closed. They're only used by the goroutine of the stream.
+	forming *Candle
+	next    time.Time
+	bid     Price
+	dropped int64
+}
+
+type candleHistory struct {
+	candles []Candle
+	err     error
+}
+
+// StreamCandles subscribes to the live trendbars of the symbol and starts the stream. The spot subscription required by
+// the live trendbars is shared through the spot manager. Close must be called to unsubscribe.
+func StreamCandles(ctx context.Context, spots *SpotManager, q CandleStreamQuery) (*CandleStream, error) {
+	return streamCandles(ctx, spots, q, time.Now)
+}
+
+func streamCandles(
+	ctx context.Context, spots *SpotManager, q CandleStreamQuery, now func() time.Time,
+) (*CandleStream, error) {
+	if _, err := PeriodDuration(q.Period); err != nil {
+		return nil, err
+	}
+	buffer := q.Buffer
+	if buffer <= 0 {
+		buffer = defaultCandleStreamBuffer
+	}
+	subscription, err := spots.Subscribe(ctx, q.SymbolID, SpotOptions{Buffer: buffer})
+	if err != nil {
+		return nil, err
+	}
+	req := &openapi.ProtoOASubscribeLiveTrendbarReq{SymbolId: proto.Int64(q.SymbolID), Period: q.Period.Enum()}
+	_, err = AccountCommand[*openapi.ProtoOASubscribeLiveTrendbarReq, *openapi.ProtoOASubscribeLiveTrendbarRes](
+		ctx, spots.account, req,
+	)
+	if err != nil {
+		err = fmt.Errorf("failed to subscribe to the live trendbars: %w", err)
+		return nil, errors.Join(err, subscription.Close(ctx))
+	}
+
+	streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s := &CandleStream{
+		account:      spots.account,
+		query:        q,
+		now:          now,
+		subscription: subscription,
+		events:       make(chan CandleEvent, buffer),
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	go s.run(streamCtx)
+	return s, nil
+}
+
+// Events returns the channel of events. It's closed when the stream is closed or fails, Err returns the reason.
+func (s *CandleStream) Events() <-chan CandleEvent {
+	return s.events
+}
+
+// Err returns the error that stopped the stream, it should be called after the events channel is closed.
+func (s *CandleStream) Err() error {
+	return s.err
+}
+
+// Dropped returns the number of spot events dropped because the stream fell behind.
+func (s *CandleStream) Dropped() int64 {
+	return s.subscription.Dropped()
+}
+
+// Close stops the stream and unsubscribes from the live trendbars.
+func (s *CandleStream) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.done
+		req := &openapi.ProtoOAUnsubscribeLiveTrendbarReq{
+			SymbolId: proto.Int64(s.query.SymbolID),
+			Period:   s.query.Period.Enum(),
+		}
+		_, err := AccountCommand[*openapi.ProtoOAUnsubscribeLiveTrendbarReq, *openapi.ProtoOAUnsubscribeLiveTrendbarRes](
+			ctx, s.account, req,
+		)
+		if err != nil {
+			err = fmt.Errorf("failed to unsubscribe from the live trendbars: %w", err)
+		}
+		s.closeErr = errors.Join(err, s.subscription.Close(ctx))
+	})
+	return s.closeErr
+}
+
+func (s *CandleStream) run(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.events)
+
+	var (
+		history = make(chan candleHistory, 1)
+		loaded  = s.query.From.IsZero()
+		pending []*openapi.ProtoOASpotEvent
+	)
+	if !loaded {
+		go func() {
+			candles, err := s.download(ctx, s.query.From, s.now())
+			history <- candleHistory{candles: candles, err: err}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case h := <-history:
+			if h.err != nil {
+				s.err = h.err
+				return
+			}
+			if !s.applyHistory(ctx, h.candles) {
+				return
+			}
+			loaded = true
+			for _, e := range pending {
+				if !s.apply(ctx, e) {
+					return
+				}
+			}
+			pending = nil
+		case e, ok := <-s.subscription.Events():
+			if !ok {
+				s.err = errors.New("spot subscription closed")
+				return
+			}
+			if !loaded {
+				pending = append(pending, e)
+				continue
+			}
+			if !s.apply(ctx, e) {
+				return
+			}
+		}
+	}
+}
+
+// applyHistory sends the downloaded candles. The last one is the forming candle when it's not closed yet.
+func (s *CandleStream) applyHistory(ctx context.Context, candles []Candle) bool {
+	now := s.now()
+	for i, candle := range candles {
+		if i == len(candles)-1 && candle.End().After(now) {
+			s.forming = &candle
+			return s.send(ctx, BarUpdated, candle)
+		}
+		if !s.send(ctx, BarClosed, candle) {
+			return false
+		}
+		s.next = candle.End()
+	}
+	return true
+}
+
+// apply updates the stream with the trendbar of the period at a spot event.
+func (s *CandleStream) apply(ctx context.Context, e *openapi.ProtoOASpotEvent) bool {
+	if e.Bid != nil {
+		s.bid = Price(e.GetBid())
+	}
+	for _, trendbar := range e.GetTrendbar() {
+		if trendbar.GetPeriod() != s.query.Period {
+			continue
+		}
+		candle, err := NewCandle(trendbar)
+		if err != nil {
+			s.err = fmt.Errorf("failed to decode the trendbar: %w", err)
+			return false
+		}
+		// The live trendbars only have the close price when it's different from the bid, the bid of the last spot event
+		// is the close when 'DeltaClose' is missing.
+		if trendbar.DeltaClose == nil && s.bid != 0 {
+			candle.Close = s.bid
+		}
+
+		switch {
+		case s.forming == nil && candle.Time.Before(s.next):
+			continue
+		case s.forming != nil && candle.Time.Before(s.forming.Time):
+			continue
+		case s.forming != nil && candle.Time.Equal(s.forming.Time):
+			s.forming = &candle
+			if !s.send(ctx, BarUpdated, candle) {
+				return false
+			}
+			continue
+		case s.forming != nil:
+			closed, ok := s.final(ctx, *s.forming)
+			if !ok || !s.send(ctx, BarClosed, closed) {
+				return false
+			}
+			s.next = closed.End()
+		}
+		if !s.fill(ctx, candle.Time) {
+			return false
+		}
+		s.forming = &candle
+		if !s.send(ctx, BarUpdated, candle) {
+			return false
+		}
+	}
+	return true
+}
+
+// final returns the closed candle. It's downloaded again when spot events were dropped since the last candle was
+// closed, as some of its updates may be missing.
+func (s *CandleStream) final(ctx context.Context, candle Candle) (Candle, bool) {
+	dropped := s.subscription.Dropped()
+	if dropped == s.dropped {
+		return candle, true
+	}
+	candles, err := s.download(ctx, candle.Time, candle.End())
+	if err != nil {
+		s.err = err
+		return Candle{}, false
+	}
+	s.dropped = dropped
+	if len(candles) > 0 && candles[0].Time.Equal(candle.Time) {
+		return candles[0], true
+	}
+	return candle, true
+}
+
+// fill downloads and sends the candles closed between the last one sent and the new forming candle.
+func (s *CandleStream) fill(ctx context.Context, to time.Time) bool {
+	if s.next.IsZero() || !s.next.Before(to) {
+		return true
+	}
+	candles, err := s.download(ctx, s.next, to)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	for _, candle := range candles {
+		if !s.send(ctx, BarClosed, candle) {
+			return false
+		}
+		s.next = candle.End()
+	}
+	return true
+}
+
+func (s *CandleStream) download(ctx context.Context, from, to time.Time) ([]Candle, error) {
+	iterator := s.account.DownloadTrendbars(ctx, TrendbarQuery{
+		SymbolID: s.query.SymbolID, Period: s.query.Period, From: from, To: to,
+	})
+	defer iterator.Close()
+	var candles []Candle
+	for iterator.Next() {
+		candles = append(candles, iterator.Candle())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, fmt.Errorf("failed to download the candles: %w", err)
+	}
+	return candles, nil
+}
+
+func (s *CandleStream) send(ctx context.Context, t CandleEventType, c Candle) bool {
+	select {
+	case s.events <- CandleEvent{Type: t, Candle: c}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newTestLiveTrendbar(t time.Time, low int64, bid uint64) *openapi.ProtoOASpotEvent {
+	return &openapi.ProtoOASpotEvent{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		SymbolId:            lo.ToPtr(int64(1)),
+		Bid:                 lo.ToPtr(bid),
+		Trendbar: []*openapi.ProtoOATrendbar{
+			{
+				Volume:                lo.ToPtr(int64(5)),
+				Period:                openapi.ProtoOATrendbarPeriod_M5.Enum(),
+				Low:                   lo.ToPtr(int64(1)),
+				UtcTimestampInMinutes: lo.ToPtr(uint32(t.Unix() / 60)),
+			},
+			{
+				Volume:                lo.ToPtr(int64(1)),
+				Period:                openapi.ProtoOATrendbarPeriod_M1.Enum(),
+				Low:                   lo.ToPtr(low),
+				DeltaHigh:             lo.ToPtr(uint64(2)),
+				UtcTimestampInMinutes: lo.ToPtr(uint32(t.Unix() / 60)),
+			},
+		},
+	}
+}
+
+func TestCandleStream(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 10, 30, 0, time.UTC)
+
+	start := func(t *testing.T) (*fakeTransport, *SpotManager) {
+		t.Helper()
+		transport := &fakeTransport{respond: (&fakeTrendbars{chunkSize: 1000}).respond}
+		c := newTestClient(transport)
+		c.RateLimit.Disabled = true
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		return transport, NewSpotManager(c.Account(1))
+	}
+
+	receive := func(t *testing.T, stream *CandleStream, count int) []CandleEvent {
+		t.Helper()
+		events := make([]CandleEvent, 0, count)
+		for len(events) < count {
+			select {
+			case event := <-stream.Events():
+				events = append(events, event)
+			case <-time.After(time.Second):
+				require.FailNow(t, "timeout waiting for the events", "received %v", events)
+			}
+		}
+		return events
+	}
+
+	t.Run("Should detect the closed candles", func(t *testing.T) {
+		t.Parallel()
+		transport, spots := start(t)
+		stream, err := streamCandles(ctx, spots, CandleStreamQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1,
+		}, func() time.Time { return now })
+		require.NoError(t, err)
+
+		minute := now.Truncate(time.Minute)
+		transport.event(newTestLiveTrendbar(minute, 100, 101))
+		transport.event(newTestLiveTrendbar(minute, 100, 102))
+		transport.event(newTestLiveTrendbar(minute.Add(time.Minute), 102, 103))
+
+		events := receive(t, stream, 4)
+		require.Equal(t, []CandleEventType{BarUpdated, BarUpdated, BarClosed, BarUpdated}, lo.Map(
+			events, func(e CandleEvent, _ int) CandleEventType { return e.Type },
+		))
+		require.Equal(t, Candle{
+			Time: minute, Period: time.Minute, Open: 100, High: 102, Low: 100, Close: 102, Volume: 1,
+		}, events[2].Candle)
+		require.Equal(t, minute.Add(time.Minute), events[3].Candle.Time)
+		require.Equal(t, Price(103), events[3].Candle.Close)
+
+		require.NoError(t, stream.Close(ctx))
+		_, ok := <-stream.Events()
+		require.False(t, ok)
+		require.NoError(t, stream.Err())
+		sent := transport.sent()
+		require.IsType(t, &openapi.ProtoOAUnsubscribeLiveTrendbarReq{}, sent[len(sent)-2])
+		require.IsType(t, &openapi.ProtoOAUnsubscribeSpotsReq{}, sent[len(sent)-1])
+		require.NoError(t, stream.Close(ctx))
+	})
+
+	t.Run("Should stitch the history with the live candles", func(t *testing.T) {
+		t.Parallel()
+		transport, spots := start(t)
+		from := now.Add(-5 * time.Minute).Truncate(time.Minute)
+		stream, err := streamCandles(ctx, spots, CandleStreamQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: from,
+		}, func() time.Time { return now })
+		require.NoError(t, err)
+		defer func() { require.NoError(t, stream.Close(ctx)) }()
+
+		// The history has the closed candles from 00:05 to 00:09 and the forming one at 00:10. The live candle of 00:13
+		// closes 00:10 and the candles of 00:11 and 00:12 are downloaded.
+		minute := now.Truncate(time.Minute)
+		transport.event(newTestLiveTrendbar(minute.Add(-time.Minute), 9, 9))
+		transport.event(newTestLiveTrendbar(minute, 10, 11))
+		transport.event(newTestLiveTrendbar(minute.Add(3*time.Minute), 13, 14))
+
+		events := receive(t, stream, 11)
+		var closed []time.Time
+		for _, event := range events {
+			if event.Type == BarClosed {
+				closed = append(closed, event.Candle.Time)
+			}
+		}
+		expected := make([]time.Time, 0, 8)
+		for at := from; at.Before(minute.Add(3 * time.Minute)); at = at.Add(time.Minute) {
+			expected = append(expected, at)
+		}
+		require.Equal(t, expected, closed)
+
+		// The fake server uses the minute as the low of the history.
+		low := Price(minute.Unix() / 60 % 1000)
+		require.Equal(t, CandleEvent{Type: BarUpdated, Candle: Candle{
+			Time: minute, Period: time.Minute, Open: low, High: low + 2, Low: low, Close: low, Volume: 1,
+		}}, events[5])
+		require.Equal(t, CandleEvent{Type: BarClosed, Candle: Candle{
+			Time: minute, Period: time.Minute, Open: 10, High: 12, Low: 10, Close: 11, Volume: 1,
+		}}, events[7])
+		require.Equal(t, BarUpdated, events[10].Type)
+		require.Equal(t, minute.Add(3*time.Minute), events[10].Candle.Time)
+	})
+	t.Run("Should download the closed candles with dropped updates", func(t *testing.T) {
+		t.Parallel()
+		transport, spots := start(t)
+		stream, err := streamCandles(ctx, spots, CandleStreamQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, Buffer: 1,
+		}, func() time.Time { return now })
+		require.NoError(t, err)
+		defer func() { require.NoError(t, stream.Close(ctx)) }()
+
+		// The events are not received, so the stream blocks and the spot events are dropped.
+		minute := now.Truncate(time.Minute)
+		for i := 0; i < 10; i++ {
+			transport.event(newTestLiveTrendbar(minute, 100, uint64(101+i)))
+		}
+		require.Eventually(t, func() bool { return stream.Dropped() > 0 }, time.Second, time.Millisecond)
+		// The next candle is sent again while the stream is idle, as it may be dropped as well.
+		var closed CandleEvent
+		timeout := time.After(time.Second)
+		for closed.Type != BarClosed {
+			select {
+			case closed = <-stream.Events():
+			case <-timeout:
+				require.FailNow(t, "timeout waiting for the closed candle")
+			case <-time.After(10 * time.Millisecond):
+				transport.event(newTestLiveTrendbar(minute.Add(time.Minute), 102, 103))
+			}
+		}
+
+		// The fake server uses the minute as the low of the history.
+		low := Price(minute.Unix() / 60 % 1000)
+		require.Equal(t, Candle{
+			Time: minute, Period: time.Minute, Open: low, High: low + 2, Low: low, Close: low, Volume: 1,
+		}, closed.Candle)
+	})
+}
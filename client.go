@@ -2,11 +2,13 @@ package ctrader
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/satori/uuid"
 	"golang.org/x/exp/slog"
 	"google.golang.org/protobuf/proto"
@@ -21,96 +23,219 @@ type clientTransport interface {
 	setHandler(func([]byte), func(error))
 }
 
+// Transport defines the protocol used to reach the cTrader Open API.
+type Transport int
+
+const (
+	// TransportTCP sends length-prefixed messages over a TLS connection.
+	TransportTCP Transport = iota
+
+	// TransportWebSocket sends binary frames, or text frames when the encoding is JSON, over a secure WebSocket
+	// connection. The server port is the same of TCP, 5035 for protobuf and 5036 for JSON.
+	TransportWebSocket
+)
+
 type Client struct {
 	ApplicationClientID string
 	ApplicationSecret   string
 	HandlerEvent        func(proto.Message)
 	Deadline            time.Duration
+	HeartbeatInterval   time.Duration
 	Logger              *slog.Logger
 	Live                bool
+	Transport           Transport
+	Encoding            Encoding
+	Backoff             Backoff
+	RateLimit           RateLimit
+	Address             string
+	TLSConfig           *tls.Config
+	Recorder            *Recorder
+	Replay              *Replay
 
+	address              string
 	transport            clientTransport
+	codec                codec
+	session              session
+	stateMachine         stateMachine
+	events               eventRegistry
+	rateLimiter          rateLimiter
 	stopSignal           atomic.Bool
+	reconnecting         atomic.Bool
+	done                 chan struct{}
+	errorSignal          chan error
 	wg                   sync.WaitGroup
-	requestRegistry      map[string]chan *openapi.ProtoMessage
+	requestRegistry      map[string]chan response
 	requestRegistryMutex sync.Mutex
 }
 
+type response struct {
+	message proto.Message
+	err     error
+}
+
 func (c *Client) Start() error {
-	c.transport = &transportTCP{deadline: c.Deadline}
-	var address string
-	if c.Live {
-		address = "live.ctraderapi.com:5035"
-	} else {
-		address = "demo.ctraderapi.com:5035"
+	c.stopSignal.Store(false)
+	c.done = make(chan struct{})
+	c.errorSignal = make(chan error, 1)
+	c.requestRegistry = make(map[string]chan response)
+	c.rateLimiter = newRateLimiter(c.RateLimit)
+	if c.Replay != nil {
+		return c.startReplay()
 	}
-	c.transport.setHandler(c.handlerMessage, c.handlerError)
-	if err := c.transport.start(address); err != nil {
-		return fmt.Errorf("failed to open the transport: %w", err)
+
+	webSocketMessage := websocket.BinaryMessage
+	switch c.Encoding {
+	case EncodingJSON:
+		c.codec = codecJSON{}
+		webSocketMessage = websocket.TextMessage
+	default:
+		c.codec = codecProtobuf{}
 	}
-	c.requestRegistry = make(map[string]chan *openapi.ProtoMessage)
-	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
-	defer ctxCancel()
-	if err := c.applicationAuthorization(ctx); err != nil {
-		return fmt.Errorf("failed to authenticate the application: %w", err)
+	c.address = serverAddress(c.Live, c.Transport, c.Encoding)
+	// Address overrides the cTrader server, it's used to reach proxies or fake servers like the one at 'ctradertest'.
+	if c.Address != "" {
+		c.address = c.Address
+	}
+
+	// The transport is kept between restarts, this way a transport can be injected at the tests.
+	if c.transport == nil {
+		switch c.Transport {
+		case TransportWebSocket:
+			c.transport = &transportWebSocket{
+				deadline: c.Deadline, messageType: webSocketMessage, tlsConfig: c.TLSConfig,
+			}
+		default:
+			c.transport = &transportTCP{deadline: c.Deadline, tlsConfig: c.TLSConfig}
+		}
+	}
+	c.transport.setHandler(c.handlerMessage, c.handlerError)
+
+	if err := c.connect(); err != nil {
+		c.setState(StateDisconnected, err)
+		return err
 	}
 	c.keepalive()
+	c.supervisor()
+	c.setState(StateReady, nil)
 	return nil
 }
 
+// serverAddress returns the address of the cTrader server. The port depends only on the encoding, 5035 for protobuf
+// and 5036 for JSON, at both transports.
+func serverAddress(live bool, transport Transport, encoding Encoding) string {
+	host := "demo.ctraderapi.com"
+	if live {
+		host = "live.ctraderapi.com"
+	}
+	port := "5035"
+	if encoding == EncodingJSON {
+		port = "5036"
+	}
+	address := host + ":" + port
+	if transport == TransportWebSocket {
+		address = "wss://" + address
+	}
+	return address
+}
+
 func (c *Client) Stop() error {
-	c.stopSignal.Store(true)
+	if c.stopSignal.Swap(true) {
+		return nil
+	}
+	close(c.done)
 	c.wg.Wait()
-	if err := c.transport.stop(); err != nil {
-		return fmt.Errorf("failed to close the transport: %w", err)
+	errTransport := c.transport.stop()
+	c.setState(StateClosed, nil)
+	var errRecorder error
+	if c.Recorder != nil {
+		errRecorder = c.Recorder.Flush()
+	}
+	if errTransport != nil {
+		return fmt.Errorf("failed to close the transport: %w", errTransport)
+	}
+	if errRecorder != nil {
+		return fmt.Errorf("failed to flush the recorder: %w", errRecorder)
 	}
 	return nil
 }
 
-func (c *Client) handlerMessage(payload []byte) {
-	var msg openapi.ProtoMessage
-	if err := proto.Unmarshal(payload, &msg); err != nil {
-		c.Logger.Error("failed to unmarshal message", "error", err)
-		return
+// startReplay starts the client without a connection, the messages come from the recorded session.
+func (c *Client) startReplay() error {
+	c.codec = codecProtobuf{}
+	if c.transport == nil {
+		c.transport = &transportReplay{
+			replay: *c.Replay,
+			finish: func(err error) { c.setState(StateDisconnected, err) },
+		}
 	}
-	if msg.GetClientMsgId() == "" {
-		message, err := mappingResponse(msg.GetPayloadType())
-		if err != nil {
-			c.Logger.Error("unknow message type", "error", err)
-			return
+	c.transport.setHandler(c.handlerMessage, c.handlerError)
+
+	// The state is set before the start, as the replay may deliver its messages, and even finish, right away.
+	c.setState(StateReady, nil)
+	if err := c.transport.start(""); err != nil {
+		c.setState(StateDisconnected, err)
+		return fmt.Errorf("failed to start the replay: %w", err)
+	}
+	return nil
+}
+
+// connect opens the transport and authorizes the application.
+func (c *Client) connect() error {
+	c.setState(StateConnecting, nil)
+	if err := c.transport.start(c.address); err != nil {
+		return fmt.Errorf("failed to open the transport: %w", err)
+	}
+	ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
+	defer ctxCancel()
+	if err := c.applicationAuthorization(ctx); err != nil {
+		if errStop := c.transport.stop(); errStop != nil {
+			c.Logger.Debug("failed to stop the transport", "error", errStop.Error())
 		}
-		if err = proto.Unmarshal(msg.GetPayload(), message); err != nil {
-			c.Logger.Error("failed to unmarshal payload", "error", err)
+		return fmt.Errorf("failed to authenticate the application: %w", err)
+	}
+	c.setState(StateAppAuthorized, nil)
+	return nil
+}
+
+func (c *Client) handlerMessage(payload []byte) {
+	msg, err := c.codec.decode(payload)
+	c.record(DirectionInbound, payload, msg)
+	if msg.clientMsgID == "" {
+		if err != nil {
+			c.Logger.Error("failed to decode message", "error", err)
 			return
 		}
-		c.HandlerEvent(message)
-	} else {
-		c.requestRegistryMutex.Lock()
-		chanResponse, ok := c.requestRegistry[msg.GetClientMsgId()]
-		c.requestRegistryMutex.Unlock()
-		if !ok {
-			c.Logger.Error("client message ID not found", "clientMessageID", msg.GetClientMsgId())
-			return
+		c.handlerEvent(msg.payload)
+		return
+	}
+
+	c.requestRegistryMutex.Lock()
+	chanResponse, ok := c.requestRegistry[msg.clientMsgID]
+	c.requestRegistryMutex.Unlock()
+	if ok {
+		// The channel may be full if the request was already canceled by a connection error.
+		select {
+		case chanResponse <- response{message: msg.payload, err: err}:
+		default:
 		}
-		chanResponse <- &msg
+	}
+	if err == nil && isEvent(msg.payload) {
+		c.handlerEvent(msg.payload)
+		return
+	}
+	if !ok {
+		c.Logger.Error("client message ID not found", "clientMessageID", msg.clientMsgID)
 	}
 }
 
-func (c *Client) handlerError(err error) {
-	c.Logger.Error("Asynchronous error", "error", err.Error())
-	for {
-		if err = c.Stop(); err != nil {
-			c.Logger.Error("failed to stop the client", "error", err.Error())
-			time.Sleep(time.Second)
-			continue
-		}
-		if err = c.Start(); err != nil {
-			c.Logger.Error("failed to start the client", "error", err.Error())
-			time.Sleep(time.Second)
-			continue
-		}
-		break
+func (c *Client) handlerEvent(m proto.Message) {
+	switch v := m.(type) {
+	case *openapi.ProtoOAAccountsTokenInvalidatedEvent:
+		c.session.forget(v.GetCtidTraderAccountIds()...)
+	case *openapi.ProtoOAClientDisconnectEvent:
+		c.handlerError(fmt.Errorf("disconnected by the server: %s", v.GetReason()))
 	}
+	c.dispatchEvent(m)
 }
 
 func (c *Client) sendRequest(ctx context.Context, req proto.Message) (proto.Message, error) {
@@ -119,29 +244,27 @@ func (c *Client) sendRequest(ctx context.Context, req proto.Message) (proto.Mess
 		return nil, fmt.Errorf("failed to get the payload type: %w", err)
 	}
 
-	payloadBase, err := proto.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal base request: %w", err)
+	if err = c.rateLimiter.wait(ctx, req); err != nil {
+		return nil, fmt.Errorf("failed to wait for the rate limit: %w", err)
 	}
 
 	id := uuid.NewV4().String()
-	reqType := uint32(payloadType)
-	message := openapi.ProtoMessage{
-		ClientMsgId: &id,
-		Payload:     payloadBase,
-		PayloadType: &reqType,
-	}
-	payload, err := proto.Marshal(&message)
+	payload, err := c.codec.encode(id, uint32(payloadType), req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to encode request: %w", err)
 	}
 
-	chanResponse := make(chan *openapi.ProtoMessage, 1)
+	chanResponse := make(chan response, 1)
 	c.requestRegistryMutex.Lock()
 	c.requestRegistry[id] = chanResponse
 	c.requestRegistryMutex.Unlock()
-	defer delete(c.requestRegistry, id)
+	defer func() {
+		c.requestRegistryMutex.Lock()
+		delete(c.requestRegistry, id)
+		c.requestRegistryMutex.Unlock()
+	}()
 
+	c.record(DirectionOutbound, payload, envelope{clientMsgID: id, payloadType: uint32(payloadType), payload: req})
 	if errSend := c.transport.send(payload); errSend != nil {
 		return nil, fmt.Errorf("failed to send the message: %w", errSend)
 	}
@@ -149,26 +272,40 @@ func (c *Client) sendRequest(ctx context.Context, req proto.Message) (proto.Mess
 	select {
 	case <-ctx.Done():
 		return nil, fmt.Errorf("context error: %w", ctx.Err())
-	case messageBase := <-chanResponse:
-		message, errMessage := mappingResponse(messageBase.GetPayloadType())
-		if errMessage != nil {
-			return nil, fmt.Errorf("failed to get the response type: %w", errMessage)
+	case resp := <-chanResponse:
+		if resp.err != nil {
+			return nil, fmt.Errorf("failed to receive the response: %w", resp.err)
 		}
-		if err = proto.Unmarshal(messageBase.GetPayload(), message); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal the response: %w", err)
+		switch resp.message.(type) {
+		case *openapi.ProtoOAErrorRes, *openapi.ProtoErrorRes:
+		default:
+			c.session.track(req)
 		}
-		return message, nil
+		return resp.message, nil
 	}
 }
 
-func (c *Client) sendEvent(ctx context.Context, e proto.Message) error {
-	payload, err := proto.Marshal(e)
+// cancelRequests fails every request waiting for a response, as they will never be answered by a broken connection.
+func (c *Client) cancelRequests(err error) {
+	c.requestRegistryMutex.Lock()
+	defer c.requestRegistryMutex.Unlock()
+	for _, chanResponse := range c.requestRegistry {
+		select {
+		case chanResponse <- response{err: fmt.Errorf("connection lost: %w", err)}:
+		default:
+		}
+	}
+}
+
+func (c *Client) sendEvent(ctx context.Context, payloadType uint32, e proto.Message) error {
+	payload, err := c.codec.encode("", payloadType, e)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return fmt.Errorf("failed to encode event: %w", err)
 	}
 	if err = ctx.Err(); err != nil {
 		return fmt.Errorf("context error: %w", err)
 	}
+	c.record(DirectionOutbound, payload, envelope{payloadType: payloadType, payload: e})
 	if errSend := c.transport.send(payload); errSend != nil {
 		return fmt.Errorf("failed to send the message: %w", errSend)
 	}
@@ -190,21 +327,30 @@ func (c *Client) applicationAuthorization(ctx context.Context) error {
 func (c *Client) keepalive() {
 	c.wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		// The server closes the connections without messages, the heartbeat is sent every 10 seconds by default.
+		interval := c.HeartbeatInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		ticker := time.NewTicker(interval)
 		defer func() {
 			ticker.Stop()
 			c.wg.Done()
 		}()
-		payloadTypeRaw := openapi.ProtoPayloadType_HEARTBEAT_EVENT
-		payloadType := uint32(payloadTypeRaw)
-		req := openapi.ProtoMessage{
-			PayloadType: &payloadType,
-		}
-		for range ticker.C {
+		payloadType := uint32(openapi.ProtoPayloadType_HEARTBEAT_EVENT)
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
 			if c.stopSignal.Load() {
 				return
 			}
-			if err := c.sendEvent(context.Background(), &req); err != nil {
+			if c.reconnecting.Load() {
+				continue
+			}
+			if err := c.sendEvent(context.Background(), payloadType, &openapi.ProtoHeartbeatEvent{}); err != nil {
 				c.handlerError(fmt.Errorf("failed to send the heartbeat event: %w", err))
 			}
 		}
@@ -1,12 +1,19 @@
 package ctrader
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/diegobernardes/ctrader/openapi"
@@ -30,11 +37,406 @@ func (m *mockClient) send(payload []byte) error {
 func TestClientKeepAlive(t *testing.T) {
 	t.Parallel()
 	mc := mockClient{t: t}
-	c := Client{transport: &mc}
+	c := Client{transport: &mc, codec: codecProtobuf{}, HeartbeatInterval: 10 * time.Millisecond}
 	c.keepalive()
-	time.Sleep(21 * time.Second)
-	require.Equal(t, int64(2), mc.count.Load())
+	require.Eventually(t, func() bool { return mc.count.Load() >= 2 }, time.Second, time.Millisecond)
 	c.stopSignal.Store(true)
-	time.Sleep(11 * time.Second)
-	require.Equal(t, int64(2), mc.count.Load())
+	time.Sleep(20 * time.Millisecond)
+	count := mc.count.Load()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, count, mc.count.Load())
+}
+
+// fakeTransport answers the requests sent by the client as the cTrader Open API would.
+type fakeTransport struct {
+	mutex          sync.Mutex
+	handlerMessage func([]byte)
+	handlerError   func(error)
+	requests       []proto.Message
+	starts         atomic.Int64
+	failStart      atomic.Bool
+	respond        func(proto.Message) proto.Message
+}
+
+func (f *fakeTransport) start(string) error {
+	f.starts.Add(1)
+	if f.failStart.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (f *fakeTransport) stop() error {
+	return nil
+}
+
+func (f *fakeTransport) send(payload []byte) error {
+	var message openapi.ProtoMessage
+	if err := proto.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	if message.GetPayloadType() == uint32(openapi.ProtoPayloadType_HEARTBEAT_EVENT) {
+		return nil
+	}
+
+	var req proto.Message
+	for _, candidate := range []proto.Message{
+		&openapi.ProtoOAApplicationAuthReq{},
+		&openapi.ProtoOAAccountAuthReq{},
+		&openapi.ProtoOAAccountLogoutReq{},
+		&openapi.ProtoOASubscribeSpotsReq{},
+		&openapi.ProtoOAUnsubscribeSpotsReq{},
+		&openapi.ProtoOASubscribeDepthQuotesReq{},
+		&openapi.ProtoOAUnsubscribeDepthQuotesReq{},
+		&openapi.ProtoOASubscribeLiveTrendbarReq{},
+		&openapi.ProtoOAUnsubscribeLiveTrendbarReq{},
+		&openapi.ProtoOANewOrderReq{},
+		&openapi.ProtoOAAmendOrderReq{},
+		&openapi.ProtoOAAmendPositionSLTPReq{},
+		&openapi.ProtoOACancelOrderReq{},
+		&openapi.ProtoOAClosePositionReq{},
+		&openapi.ProtoOAAssetListReq{},
+		&openapi.ProtoOASymbolCategoryListReq{},
+		&openapi.ProtoOASymbolsListReq{},
+		&openapi.ProtoOASymbolByIdReq{},
+		&openapi.ProtoOAGetTrendbarsReq{},
+		&openapi.ProtoOAGetTickDataReq{},
+		&openapi.ProtoOAReconcileReq{},
+		&openapi.ProtoOATraderReq{},
+		&openapi.ProtoOASymbolsForConversionReq{},
+		&openapi.ProtoOAGetPositionUnrealizedPnLReq{},
+	} {
+		if fakePayloadType(candidate) == message.GetPayloadType() {
+			req = candidate
+			break
+		}
+	}
+	if req == nil {
+		return fmt.Errorf("unexpected payload type '%d'", message.GetPayloadType())
+	}
+	if err := proto.Unmarshal(message.GetPayload(), req); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	f.requests = append(f.requests, req)
+	respond := f.respond
+	f.mutex.Unlock()
+
+	var resp proto.Message
+	if respond != nil {
+		resp = respond(req)
+	}
+	if resp == nil {
+		resp = fakeResponse(req)
+	}
+	buf, err := codecProtobuf{}.encode(message.GetClientMsgId(), fakePayloadType(resp), resp)
+	if err != nil {
+		return err
+	}
+	go f.handlerMessage(buf)
+	return nil
+}
+
+func (f *fakeTransport) setHandler(handlerMessage func([]byte), handlerError func(error)) {
+	f.handlerMessage = handlerMessage
+	f.handlerError = handlerError
+}
+
+func (f *fakeTransport) drop() {
+	f.handlerError(errors.New("connection reset by peer"))
+}
+
+// event delivers a message from the server that is not a response to a request.
+func (f *fakeTransport) event(m proto.Message) {
+	buf, err := codecProtobuf{}.encode("", fakePayloadType(m), m)
+	if err != nil {
+		panic(err)
+	}
+	f.handlerMessage(buf)
+}
+
+func (f *fakeTransport) sent() []proto.Message {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]proto.Message(nil), f.requests...)
+}
+
+func (f *fakeTransport) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = nil
+}
+
+func fakeResponse(req proto.Message) proto.Message {
+	switch v := req.(type) {
+	case *openapi.ProtoOAApplicationAuthReq:
+		return &openapi.ProtoOAApplicationAuthRes{}
+	case *openapi.ProtoOAAccountAuthReq:
+		return &openapi.ProtoOAAccountAuthRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOAAccountLogoutReq:
+		return &openapi.ProtoOAAccountLogoutRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOASubscribeSpotsReq:
+		return &openapi.ProtoOASubscribeSpotsRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOAUnsubscribeSpotsReq:
+		return &openapi.ProtoOAUnsubscribeSpotsRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOASubscribeDepthQuotesReq:
+		return &openapi.ProtoOASubscribeDepthQuotesRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOAUnsubscribeDepthQuotesReq:
+		return &openapi.ProtoOAUnsubscribeDepthQuotesRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOASubscribeLiveTrendbarReq:
+		return &openapi.ProtoOASubscribeLiveTrendbarRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOAUnsubscribeLiveTrendbarReq:
+		return &openapi.ProtoOAUnsubscribeLiveTrendbarRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOANewOrderReq:
+		return &openapi.ProtoOAExecutionEvent{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			ExecutionType:       openapi.ProtoOAExecutionType_ORDER_ACCEPTED.Enum(),
+		}
+	case *openapi.ProtoOAAmendOrderReq:
+		return newTestExecution(openapi.ProtoOAExecutionType_ORDER_REPLACED, v.GetOrderId(), 0)
+	case *openapi.ProtoOAAmendPositionSLTPReq:
+		return &openapi.ProtoOAExecutionEvent{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			ExecutionType:       openapi.ProtoOAExecutionType_ORDER_REPLACED.Enum(),
+			Position: newTestPosition(
+				v.GetPositionId(), openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1,
+			),
+		}
+	case *openapi.ProtoOACancelOrderReq:
+		return newTestExecution(openapi.ProtoOAExecutionType_ORDER_CANCELLED, v.GetOrderId(), 0)
+	case *openapi.ProtoOAClosePositionReq:
+		return newTestExecution(openapi.ProtoOAExecutionType_ORDER_ACCEPTED, 100, v.GetPositionId())
+	case *openapi.ProtoOAReconcileReq:
+		return &openapi.ProtoOAReconcileRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOATraderReq:
+		return &openapi.ProtoOATraderRes{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			Trader: &openapi.ProtoOATrader{
+				CtidTraderAccountId: v.CtidTraderAccountId,
+				Balance:             lo.ToPtr(int64(0)),
+				DepositAssetId:      lo.ToPtr(int64(1)),
+			},
+		}
+	case *openapi.ProtoOASymbolsForConversionReq:
+		return &openapi.ProtoOASymbolsForConversionRes{CtidTraderAccountId: v.CtidTraderAccountId}
+	case *openapi.ProtoOAGetPositionUnrealizedPnLReq:
+		return &openapi.ProtoOAGetPositionUnrealizedPnLRes{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			MoneyDigits:         lo.ToPtr(uint32(2)),
+		}
+	default:
+		panic(fmt.Sprintf("unexpected request '%T'", req))
+	}
+}
+
+// fakePayloadType returns the payload type from the default value of the 'payloadType' field.
+func fakePayloadType(m proto.Message) uint32 {
+	field := m.ProtoReflect().Descriptor().Fields().ByName("payloadType")
+	return uint32(field.Default().Enum())
+}
+
+func newTestClient(transport clientTransport) *Client {
+	return &Client{
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Backoff:   Backoff{InitialInterval: time.Millisecond, Jitter: -1},
+		transport: transport,
+	}
+}
+
+func newTestOrder() *openapi.ProtoOANewOrderReq {
+	return &openapi.ProtoOANewOrderReq{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		SymbolId:            lo.ToPtr(int64(1)),
+		OrderType:           openapi.ProtoOAOrderType_MARKET.Enum(),
+		TradeSide:           openapi.ProtoOATradeSide_BUY.Enum(),
+		Volume:              lo.ToPtr(int64(100000)),
+	}
+}
+
+// newTestExecution returns an execution event of the account 1 with all the required fields set.
+func newTestExecution(
+	executionType openapi.ProtoOAExecutionType, orderID, positionID int64,
+) *openapi.ProtoOAExecutionEvent {
+	tradeData := &openapi.ProtoOATradeData{
+		SymbolId:  lo.ToPtr(int64(1)),
+		Volume:    lo.ToPtr(int64(100000)),
+		TradeSide: openapi.ProtoOATradeSide_BUY.Enum(),
+	}
+	return &openapi.ProtoOAExecutionEvent{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		ExecutionType:       executionType.Enum(),
+		Order: &openapi.ProtoOAOrder{
+			OrderId:     lo.ToPtr(orderID),
+			TradeData:   tradeData,
+			OrderType:   openapi.ProtoOAOrderType_MARKET.Enum(),
+			OrderStatus: openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED.Enum(),
+			PositionId:  lo.ToPtr(positionID),
+		},
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should restore the session", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+
+		ctx := context.Background()
+		_, err := Command[*openapi.ProtoOAAccountAuthReq, *openapi.ProtoOAAccountAuthRes](
+			ctx, c, &openapi.ProtoOAAccountAuthReq{CtidTraderAccountId: lo.ToPtr(int64(1)), AccessToken: lo.ToPtr("t")},
+		)
+		require.NoError(t, err)
+		_, err = Command[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](
+			ctx, c, &openapi.ProtoOASubscribeSpotsReq{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: []int64{10, 11}},
+		)
+		require.NoError(t, err)
+		_, err = Command[*openapi.ProtoOAUnsubscribeSpotsReq, *openapi.ProtoOAUnsubscribeSpotsRes](
+			ctx, c, &openapi.ProtoOAUnsubscribeSpotsReq{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: []int64{11}},
+		)
+		require.NoError(t, err)
+		_, err = Command[*openapi.ProtoOASubscribeDepthQuotesReq, *openapi.ProtoOASubscribeDepthQuotesRes](
+			ctx, c, &openapi.ProtoOASubscribeDepthQuotesReq{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: []int64{10}},
+		)
+		require.NoError(t, err)
+		_, err = Command[*openapi.ProtoOASubscribeLiveTrendbarReq, *openapi.ProtoOASubscribeLiveTrendbarRes](
+			ctx, c, &openapi.ProtoOASubscribeLiveTrendbarReq{
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+				SymbolId:            lo.ToPtr(int64(10)),
+				Period:              openapi.ProtoOATrendbarPeriod_M1.Enum(),
+			},
+		)
+		require.NoError(t, err)
+
+		transport.reset()
+		transport.drop()
+		require.Eventually(t, func() bool { return len(transport.sent()) == 5 }, time.Second, time.Millisecond)
+		sent := transport.sent()
+		require.IsType(t, &openapi.ProtoOAApplicationAuthReq{}, sent[0])
+		require.IsType(t, &openapi.ProtoOAAccountAuthReq{}, sent[1])
+		require.Equal(t, []int64{10}, sent[2].(*openapi.ProtoOASubscribeSpotsReq).GetSymbolId())
+		require.Equal(t, []int64{10}, sent[3].(*openapi.ProtoOASubscribeDepthQuotesReq).GetSymbolId())
+		require.Equal(t, openapi.ProtoOATrendbarPeriod_M1, sent[4].(*openapi.ProtoOASubscribeLiveTrendbarReq).GetPeriod())
+		require.Equal(t, int64(2), transport.starts.Load())
+	})
+
+	t.Run("Should forget the accounts rejected by the server", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+
+		ctx := context.Background()
+		_, err := Command[*openapi.ProtoOAAccountAuthReq, *openapi.ProtoOAAccountAuthRes](
+			ctx, c, &openapi.ProtoOAAccountAuthReq{CtidTraderAccountId: lo.ToPtr(int64(1)), AccessToken: lo.ToPtr("t")},
+		)
+		require.NoError(t, err)
+		_, err = Command[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](
+			ctx, c, &openapi.ProtoOASubscribeSpotsReq{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: []int64{10}},
+		)
+		require.NoError(t, err)
+
+		transport.mutex.Lock()
+		transport.respond = func(req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOAAccountAuthReq); !ok {
+				return nil
+			}
+			return &openapi.ProtoOAErrorRes{
+				ErrorCode:           lo.ToPtr("CH_ACCESS_TOKEN_INVALID"),
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+			}
+		}
+		transport.mutex.Unlock()
+		transport.reset()
+		transport.drop()
+		require.Eventually(t, func() bool { return len(transport.sent()) == 2 }, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return len(c.session.requests()) == 0 }, time.Second, time.Millisecond)
+		require.Len(t, transport.sent(), 2)
+	})
+
+	t.Run("Should give up after the max attempts", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		c.Backoff.MaxAttempts = 3
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+
+		transport.failStart.Store(true)
+		transport.drop()
+		require.Eventually(t, func() bool { return transport.starts.Load() == 4 }, time.Second, time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, int64(4), transport.starts.Load())
+	})
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+	b := Backoff{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Jitter: -1}
+	require.Equal(t, time.Second, b.delay(1))
+	require.Equal(t, 2*time.Second, b.delay(2))
+	require.Equal(t, 4*time.Second, b.delay(3))
+	require.Equal(t, 5*time.Second, b.delay(4))
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := b.delay(2)
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.LessOrEqual(t, delay, 3*time.Second)
+	}
+}
+
+func TestClientState(t *testing.T) {
+	t.Parallel()
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	c.HandlerEvent = func(proto.Message) {}
+	var (
+		mutex   sync.Mutex
+		changes []State
+	)
+	c.OnStateChange(func(change StateChange) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		changes = append(changes, change.To)
+	})
+	waitChanges := func(expected ...State) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(changes) == len(expected)
+		}, time.Second, time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Equal(t, expected, changes)
+		changes = nil
+	}
+
+	require.Equal(t, StateDisconnected, c.State())
+	require.NoError(t, c.Start())
+	waitChanges(StateConnecting, StateAppAuthorized, StateReady)
+	require.Equal(t, StateReady, c.State())
+
+	transport.drop()
+	waitChanges(StateReconnecting, StateConnecting, StateAppAuthorized, StateReady)
+
+	transport.event(&openapi.ProtoOAClientDisconnectEvent{Reason: lo.ToPtr("maintenance")})
+	waitChanges(StateReconnecting, StateConnecting, StateAppAuthorized, StateReady)
+
+	transport.failStart.Store(true)
+	transport.drop()
+	require.Eventually(t, func() bool { return c.State() == StateReconnecting }, time.Second, time.Millisecond)
+	require.NoError(t, c.Stop())
+	require.Equal(t, StateClosed, c.State())
+
+	unsubscribe := c.OnStateChange(func(StateChange) { require.FailNow(t, "unexpected call") })
+	unsubscribe()
+	c.setState(StateDisconnected, nil)
 }
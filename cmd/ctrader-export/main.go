@@ -0,0 +1,278 @@
+// Command ctrader-export downloads the market data of a symbol and writes it as CSV or Apache Parquet.
+//
+// The credentials are read from the environment variables CTRADER_CLIENT_ID, CTRADER_SECRET, CTRADER_ACCOUNT_ID and
+// CTRADER_TOKEN:
+//
+//	ctrader-export -symbol EURUSD -data candles -period M1 -from 2024-01-01 -to 2024-02-01 -format parquet \
+//		-output eurusd.parquet
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/diegobernardes/ctrader"
+	"github.com/diegobernardes/ctrader/export"
+	"github.com/diegobernardes/ctrader/openapi"
+	"github.com/diegobernardes/ctrader/store"
+)
+
+type config struct {
+	symbol string
+	data   string
+	period string
+	from   time.Time
+	to     time.Time
+	format export.Format
+	output string
+	store  string
+	live   bool
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parseFlags(args []string) (config, error) {
+	var (
+		cfg          config
+		from, to     string
+		format       string
+		flags        = flag.NewFlagSet("ctrader-export", flag.ContinueOnError)
+		defaultRange = time.Now().UTC().Truncate(24 * time.Hour)
+	)
+	flags.StringVar(&cfg.symbol, "symbol", "", "name of the symbol, like EURUSD")
+	flags.StringVar(&cfg.data, "data", "candles", "data to export: candles, bid, ask or quotes")
+	flags.StringVar(&cfg.period, "period", "M1", "period of the candles, like M1, H1 or D1")
+	flags.StringVar(&from, "from", defaultRange.Add(-24*time.Hour).Format(time.DateOnly), "start of the range")
+	flags.StringVar(&to, "to", defaultRange.Format(time.DateOnly), "end of the range, exclusive")
+	flags.StringVar(&format, "format", string(export.FormatCSV), "output format: csv or parquet")
+	flags.StringVar(&cfg.output, "output", "", "output file, defaults to the standard output")
+	flags.StringVar(&cfg.store, "store", "", "directory of the local store, only the missing data is downloaded")
+	flags.BoolVar(&cfg.live, "live", false, "use the live server instead of the demo one")
+	if err := flags.Parse(args); err != nil {
+		return config{}, fmt.Errorf("failed to parse the flags: %w", err)
+	}
+
+	if cfg.symbol == "" {
+		return config{}, errors.New("the symbol is required")
+	}
+	var err error
+	if cfg.from, err = parseTime(from); err != nil {
+		return config{}, err
+	}
+	if cfg.to, err = parseTime(to); err != nil {
+		return config{}, err
+	}
+	cfg.format = export.Format(strings.ToLower(format))
+	return cfg, nil
+}
+
+// parseTime accepts a date, like '2024-01-02', or RFC 3339, like '2024-01-02T15:04:05Z'.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+	}
+	return t.UTC(), nil
+}
+
+func run(ctx context.Context, cfg config) error {
+	accountID, err := strconv.ParseInt(os.Getenv("CTRADER_ACCOUNT_ID"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid CTRADER_ACCOUNT_ID: %w", err)
+	}
+	client := &ctrader.Client{
+		ApplicationClientID: os.Getenv("CTRADER_CLIENT_ID"),
+		ApplicationSecret:   os.Getenv("CTRADER_SECRET"),
+		Deadline:            30 * time.Second,
+		Logger:              slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		Live:                cfg.live,
+	}
+	if err := client.Start(); err != nil {
+		return fmt.Errorf("failed to start the client: %w", err)
+	}
+	defer client.Stop() //nolint:errcheck
+
+	account := client.Account(accountID)
+	if err := account.Authorize(ctx, os.Getenv("CTRADER_TOKEN")); err != nil {
+		return fmt.Errorf("failed to authorize the account: %w", err)
+	}
+	catalog, err := ctrader.NewSymbolCatalog(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to load the symbols: %w", err)
+	}
+	defer catalog.Close()
+	symbol, err := catalog.SymbolByName(ctx, cfg.symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get the symbol: %w", err)
+	}
+
+	var s *store.Store
+	if cfg.store != "" {
+		if s, err = store.Open(cfg.store); err != nil {
+			return fmt.Errorf("failed to open the store: %w", err)
+		}
+	}
+
+	if cfg.output == "" {
+		return exportData(ctx, cfg, account, s, symbol, os.Stdout)
+	}
+	f, err := os.Create(cfg.output)
+	if err != nil {
+		return fmt.Errorf("failed to create the output: %w", err)
+	}
+	if err := exportData(ctx, cfg, account, s, symbol, f); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the output: %w", err)
+	}
+	return nil
+}
+
+func exportData(
+	ctx context.Context, cfg config, account *ctrader.Account, s *store.Store, symbol ctrader.Symbol, output io.Writer,
+) error {
+	switch cfg.data {
+	case "candles":
+		return exportCandles(ctx, cfg, account, s, symbol, output)
+	case "bid", "ask":
+		return exportTicks(ctx, cfg, account, s, symbol, output)
+	case "quotes":
+		return exportQuotes(ctx, cfg, account, symbol, output)
+	default:
+		return fmt.Errorf("unknown data '%s'", cfg.data)
+	}
+}
+
+func exportCandles(
+	ctx context.Context, cfg config, account *ctrader.Account, s *store.Store, symbol ctrader.Symbol, output io.Writer,
+) error {
+	period, ok := openapi.ProtoOATrendbarPeriod_value[strings.ToUpper(cfg.period)]
+	if !ok {
+		return fmt.Errorf("unknown period '%s'", cfg.period)
+	}
+	query := ctrader.TrendbarQuery{
+		SymbolID: symbol.ID, Period: openapi.ProtoOATrendbarPeriod(period), From: cfg.from, To: cfg.to,
+	}
+	writer, err := export.NewCandleWriter(output, cfg.format, symbol.Digits)
+	if err != nil {
+		return fmt.Errorf("failed to create the writer: %w", err)
+	}
+
+	if s != nil {
+		candles, err := s.DownloadTrendbars(ctx, account, query)
+		if err != nil {
+			return fmt.Errorf("failed to download the candles: %w", err)
+		}
+		for _, candle := range candles {
+			if err := writer.Write(candle); err != nil {
+				return fmt.Errorf("failed to write the candle: %w", err)
+			}
+		}
+		return closeWriter(writer)
+	}
+
+	candles := account.DownloadTrendbars(ctx, query)
+	defer candles.Close()
+	for candles.Next() {
+		if err := writer.Write(candles.Candle()); err != nil {
+			return fmt.Errorf("failed to write the candle: %w", err)
+		}
+	}
+	if err := candles.Err(); err != nil {
+		return fmt.Errorf("failed to download the candles: %w", err)
+	}
+	return closeWriter(writer)
+}
+
+func exportTicks(
+	ctx context.Context, cfg config, account *ctrader.Account, s *store.Store, symbol ctrader.Symbol, output io.Writer,
+) error {
+	quoteType := openapi.ProtoOAQuoteType_BID
+	if cfg.data == "ask" {
+		quoteType = openapi.ProtoOAQuoteType_ASK
+	}
+	query := ctrader.TickQuery{SymbolID: symbol.ID, From: cfg.from, To: cfg.to}
+	writer, err := export.NewTickWriter(output, cfg.format, symbol.Digits)
+	if err != nil {
+		return fmt.Errorf("failed to create the writer: %w", err)
+	}
+
+	if s != nil {
+		ticks, err := s.DownloadTicks(ctx, account, query, quoteType)
+		if err != nil {
+			return fmt.Errorf("failed to download the ticks: %w", err)
+		}
+		for _, tick := range ticks {
+			if err := writer.Write(tick); err != nil {
+				return fmt.Errorf("failed to write the tick: %w", err)
+			}
+		}
+		return closeWriter(writer)
+	}
+
+	ticks := account.DownloadTicks(ctx, query, quoteType)
+	defer ticks.Close()
+	for ticks.Next() {
+		if err := writer.Write(ticks.Tick()); err != nil {
+			return fmt.Errorf("failed to write the tick: %w", err)
+		}
+	}
+	if err := ticks.Err(); err != nil {
+		return fmt.Errorf("failed to download the ticks: %w", err)
+	}
+	return closeWriter(writer)
+}
+
+func exportQuotes(
+	ctx context.Context, cfg config, account *ctrader.Account, symbol ctrader.Symbol, output io.Writer,
+) error {
+	writer, err := export.NewQuoteWriter(output, cfg.format, symbol.Digits)
+	if err != nil {
+		return fmt.Errorf("failed to create the writer: %w", err)
+	}
+	quotes := account.DownloadQuotes(ctx, ctrader.TickQuery{SymbolID: symbol.ID, From: cfg.from, To: cfg.to})
+	defer quotes.Close()
+	for quotes.Next() {
+		if err := writer.Write(quotes.Quote()); err != nil {
+			return fmt.Errorf("failed to write the quote: %w", err)
+		}
+	}
+	if err := quotes.Err(); err != nil {
+		return fmt.Errorf("failed to download the quotes: %w", err)
+	}
+	return closeWriter(writer)
+}
+
+func closeWriter(w io.Closer) error {
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish the output: %w", err)
+	}
+	return nil
+}
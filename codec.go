@@ -0,0 +1,120 @@
+package ctrader
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Encoding defines the wire format used to exchange messages with the cTrader Open API.
+type Encoding int
+
+const (
+	// EncodingProtobuf exchanges 'openapi.ProtoMessage' values serialized as protobuf.
+	EncodingProtobuf Encoding = iota
+
+	// EncodingJSON exchanges '{"clientMsgId","payloadType","payload"}' JSON objects.
+	EncodingJSON
+)
+
+// envelope is the decoded version of 'openapi.ProtoMessage', independently of the wire format.
+type envelope struct {
+	clientMsgID string
+	payloadType uint32
+	payload     proto.Message
+}
+
+type codec interface {
+	encode(clientMsgID string, payloadType uint32, payload proto.Message) ([]byte, error)
+
+	// decode always return the client message ID and payload type when they're available, even if the payload could
+	// not be decoded, this way the error can be delivered to the request that is waiting for it.
+	decode([]byte) (envelope, error)
+}
+
+type codecProtobuf struct{}
+
+func (codecProtobuf) encode(clientMsgID string, payloadType uint32, payload proto.Message) ([]byte, error) {
+	payloadBase, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the payload: %w", err)
+	}
+	message := openapi.ProtoMessage{
+		PayloadType: &payloadType,
+		Payload:     payloadBase,
+	}
+	if clientMsgID != "" {
+		message.ClientMsgId = &clientMsgID
+	}
+	buf, err := proto.Marshal(&message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the message: %w", err)
+	}
+	return buf, nil
+}
+
+func (codecProtobuf) decode(buf []byte) (envelope, error) {
+	var message openapi.ProtoMessage
+	if err := proto.Unmarshal(buf, &message); err != nil {
+		return envelope{}, fmt.Errorf("failed to unmarshal the message: %w", err)
+	}
+	e := envelope{clientMsgID: message.GetClientMsgId(), payloadType: message.GetPayloadType()}
+	payload, err := mappingResponse(e.payloadType)
+	if err != nil {
+		return e, fmt.Errorf("failed to get the payload type: %w", err)
+	}
+	if err = proto.Unmarshal(message.GetPayload(), payload); err != nil {
+		return e, fmt.Errorf("failed to unmarshal the payload: %w", err)
+	}
+	e.payload = payload
+	return e, nil
+}
+
+type codecJSON struct{}
+
+type codecJSONMessage struct {
+	ClientMsgID string          `json:"clientMsgId,omitempty"`
+	PayloadType uint32          `json:"payloadType"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+func (codecJSON) encode(clientMsgID string, payloadType uint32, payload proto.Message) ([]byte, error) {
+	// The server expects enums as numbers, the same way they're represented at the 'payloadType' field.
+	payloadBase, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the payload: %w", err)
+	}
+	message := codecJSONMessage{
+		ClientMsgID: clientMsgID,
+		PayloadType: payloadType,
+		Payload:     payloadBase,
+	}
+	buf, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the message: %w", err)
+	}
+	return buf, nil
+}
+
+func (codecJSON) decode(buf []byte) (envelope, error) {
+	var message codecJSONMessage
+	if err := json.Unmarshal(buf, &message); err != nil {
+		return envelope{}, fmt.Errorf("failed to unmarshal the message: %w", err)
+	}
+	e := envelope{clientMsgID: message.ClientMsgID, payloadType: message.PayloadType}
+	payload, err := mappingResponse(e.payloadType)
+	if err != nil {
+		return e, fmt.Errorf("failed to get the payload type: %w", err)
+	}
+	if len(message.Payload) > 0 {
+		if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(message.Payload, payload); err != nil {
+			return e, fmt.Errorf("failed to unmarshal the payload: %w", err)
+		}
+	}
+	e.payload = payload
+	return e, nil
+}
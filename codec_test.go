@@ -0,0 +1,144 @@
+package ctrader
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// populateMessage sets every field of the message with a deterministic value. Nested messages are populated until
+// the depth is reached, which is required because some messages are recursive.
+func populateMessage(t *testing.T, m protoreflect.Message, depth int) {
+	t.Helper()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		switch {
+		case field.IsMap():
+			require.FailNow(t, "map fields are not supported", string(field.FullName()))
+		case field.IsList():
+			list := m.Mutable(field).List()
+			for j := 0; j < 2; j++ {
+				if field.Message() != nil {
+					if depth == 0 {
+						break
+					}
+					element := list.NewElement()
+					populateMessage(t, element.Message(), depth-1)
+					list.Append(element)
+					continue
+				}
+				list.Append(populateScalar(field, i+j))
+			}
+		case field.Message() != nil:
+			switch {
+			case depth > 0:
+				populateMessage(t, m.Mutable(field).Message(), depth-1)
+			case field.Cardinality() == protoreflect.Required:
+				populateMessage(t, m.Mutable(field).Message(), 0)
+			}
+		default:
+			m.Set(field, populateScalar(field, i))
+		}
+	}
+}
+
+func populateScalar(field protoreflect.FieldDescriptor, seed int) protoreflect.Value {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(seed % values.Len()).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(seed + 1))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(int64(seed) + 1<<40)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(seed + 1))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(seed) + 1<<40)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(seed) + 0.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(seed) + 0.25)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(fmt.Sprintf("value-%d", seed))
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{byte(seed), 1, 2})
+	default:
+		panic(fmt.Sprintf("unsupported kind '%s'", field.Kind()))
+	}
+}
+
+func mappingResponsePayloadTypes() []uint32 {
+	var payloadTypes []uint32
+	for value := range openapi.ProtoPayloadType_name {
+		payloadTypes = append(payloadTypes, uint32(value))
+	}
+	for value := range openapi.ProtoOAPayloadType_name {
+		payloadTypes = append(payloadTypes, uint32(value))
+	}
+	var result []uint32
+	for _, payloadType := range payloadTypes {
+		if _, err := mappingResponse(payloadType); err == nil {
+			result = append(result, payloadType)
+		}
+	}
+	return result
+}
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	codecs := map[string]codec{
+		"protobuf": codecProtobuf{},
+		"json":     codecJSON{},
+	}
+	payloadTypes := mappingResponsePayloadTypes()
+	require.NotEmpty(t, payloadTypes)
+
+	for name, c := range codecs {
+		for _, payloadType := range payloadTypes {
+			t.Run(fmt.Sprintf("Should round-trip %d using %s", payloadType, name), func(t *testing.T) {
+				t.Parallel()
+				message, err := mappingResponse(payloadType)
+				require.NoError(t, err)
+				populateMessage(t, message.ProtoReflect(), 2)
+
+				payload, err := c.encode("id", payloadType, message)
+				require.NoError(t, err)
+				e, err := c.decode(payload)
+				require.NoError(t, err)
+				require.Equal(t, "id", e.clientMsgID)
+				require.Equal(t, payloadType, e.payloadType)
+				require.True(t, proto.Equal(message, e.payload), "expected %v, got %v", message, e.payload)
+			})
+		}
+	}
+
+	t.Run("Should decode the JSON format expected by the server", func(t *testing.T) {
+		t.Parallel()
+		payload := `{"clientMsgId":"cm_id_2","payloadType":2101,"payload":{"payloadType":2101,"unknown":true}}`
+		e, err := codecJSON{}.decode([]byte(payload))
+		require.NoError(t, err)
+		require.Equal(t, "cm_id_2", e.clientMsgID)
+		require.IsType(t, &openapi.ProtoOAApplicationAuthRes{}, e.payload)
+	})
+
+	t.Run("Should keep the client message ID on unknown payload types", func(t *testing.T) {
+		t.Parallel()
+		for name, c := range codecs {
+			payload, err := c.encode("id", 1, &openapi.ProtoHeartbeatEvent{})
+			require.NoError(t, err, name)
+			e, err := c.decode(payload)
+			require.Error(t, err, name)
+			require.Equal(t, "id", e.clientMsgID, name)
+		}
+	})
+}
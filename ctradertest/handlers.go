@@ -0,0 +1,666 @@
+package ctradertest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Account is a trader account known by the server.
+type Account struct {
+	ID          int64
+	AccessToken string
+	Live        bool
+
+	// Balance is in cents of the deposit asset, the server always uses 2 money digits.
+	Balance int64
+}
+
+// Symbol is a symbol known by the server. Volumes are in cents, like at the Open API.
+type Symbol struct {
+	ID           int64
+	Name         string
+	Digits       int32
+	PipPosition  int32
+	BaseAssetID  int64
+	QuoteAssetID int64
+	CategoryID   int64
+	LotSize      int64
+	MinVolume    int64
+	MaxVolume    int64
+	StepVolume   int64
+}
+
+// Asset is an asset known by the server, like a currency.
+type Asset struct {
+	ID          int64
+	Name        string
+	DisplayName string
+	Digits      int32
+}
+
+// Category is a symbol category known by the server.
+type Category struct {
+	ID           int64
+	AssetClassID int64
+	Name         string
+}
+
+type price struct {
+	bid uint64
+	ask uint64
+}
+
+// state is the trading state of the server, it's protected by the server mutex.
+type state struct {
+	accounts   map[int64]*Account
+	symbols    map[int64]Symbol
+	assets     map[int64]Asset
+	categories map[int64]Category
+	prices     map[int64]price
+	orders     map[int64]*orderEntry
+	positions  map[int64]*positionEntry
+	sequence   int64
+}
+
+type orderEntry struct {
+	accountID int64
+	order     *openapi.ProtoOAOrder
+}
+
+type positionEntry struct {
+	accountID int64
+	position  *openapi.ProtoOAPosition
+}
+
+func newState() state {
+	return state{
+		accounts:   make(map[int64]*Account),
+		symbols:    make(map[int64]Symbol),
+		assets:     make(map[int64]Asset),
+		categories: make(map[int64]Category),
+		prices:     make(map[int64]price),
+		orders:     make(map[int64]*orderEntry),
+		positions:  make(map[int64]*positionEntry),
+	}
+}
+
+func (s *state) next() int64 {
+	s.sequence++
+	return s.sequence
+}
+
+// AddAccount registers a trader account, an account with the same ID is replaced.
+func (s *Server) AddAccount(account Account) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state.accounts[account.ID] = &account
+}
+
+// AddSymbol registers a symbol, a symbol with the same ID is replaced. Symbols are shared by all the accounts.
+func (s *Server) AddSymbol(symbol Symbol) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state.symbols[symbol.ID] = symbol
+}
+
+// AddAsset registers an asset, an asset with the same ID is replaced.
+func (s *Server) AddAsset(asset Asset) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state.assets[asset.ID] = asset
+}
+
+// AddCategory registers a symbol category, a category with the same ID is replaced.
+func (s *Server) AddCategory(category Category) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state.categories[category.ID] = category
+}
+
+// Balance returns the account balance.
+func (s *Server) Balance(accountID int64) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if account, ok := s.state.accounts[accountID]; ok {
+		return account.Balance
+	}
+	return 0
+}
+
+// Spot updates the symbol price and sends a spot event to the connections subscribed to it. Prices are in 1/100000
+// of unit, like at the Open API. Market orders are filled using the last price.
+func (s *Server) Spot(symbolID int64, bid, ask uint64) {
+	type target struct {
+		conn      *conn
+		accountID int64
+	}
+	s.mutex.Lock()
+	s.state.prices[symbolID] = price{bid: bid, ask: ask}
+	var targets []target
+	for c := range s.conns {
+		for accountID, symbols := range c.spots {
+			if _, ok := symbols[symbolID]; ok {
+				targets = append(targets, target{conn: c, accountID: accountID})
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	timestamp := time.Now().UnixMilli()
+	for _, t := range targets {
+		t.conn.write("", &openapi.ProtoOASpotEvent{
+			CtidTraderAccountId: proto.Int64(t.accountID),
+			SymbolId:            proto.Int64(symbolID),
+			Bid:                 proto.Uint64(bid),
+			Ask:                 proto.Uint64(ask),
+			Timestamp:           proto.Int64(timestamp),
+		})
+	}
+}
+
+// Positions returns the open positions of the account.
+func (s *Server) Positions(accountID int64) []*openapi.ProtoOAPosition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state.accountPositions(accountID)
+}
+
+// Orders returns the pending orders of the account.
+func (s *Server) Orders(accountID int64) []*openapi.ProtoOAOrder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state.accountOrders(accountID)
+}
+
+func (s *state) accountPositions(accountID int64) []*openapi.ProtoOAPosition {
+	var result []*openapi.ProtoOAPosition
+	for _, entry := range s.positions {
+		if entry.accountID == accountID {
+			result = append(result, proto.Clone(entry.position).(*openapi.ProtoOAPosition)) //nolint:forcetypeassert
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetPositionId() < result[j].GetPositionId() })
+	return result
+}
+
+func (s *state) accountOrders(accountID int64) []*openapi.ProtoOAOrder {
+	var result []*openapi.ProtoOAOrder
+	for _, entry := range s.orders {
+		if entry.accountID == accountID {
+			result = append(result, proto.Clone(entry.order).(*openapi.ProtoOAOrder)) //nolint:forcetypeassert
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetOrderId() < result[j].GetOrderId() })
+	return result
+}
+
+// defaultHandler returns the handler used when the request type was not scripted with Handle. The handlers run with
+// the server mutex locked.
+func (s *Server) defaultHandler(req proto.Message) handler {
+	var fn handler
+	switch req.(type) {
+	case *openapi.ProtoOAApplicationAuthReq:
+		fn = typed(s.applicationAuth)
+	case *openapi.ProtoOAGetAccountListByAccessTokenReq:
+		fn = typed(s.accountList)
+	case *openapi.ProtoOAAccountAuthReq:
+		fn = typed(s.accountAuth)
+	case *openapi.ProtoOAAccountLogoutReq:
+		fn = typed(s.accountLogout)
+	case *openapi.ProtoOATraderReq:
+		fn = typed(s.trader)
+	case *openapi.ProtoOAAssetListReq:
+		fn = typed(s.assetList)
+	case *openapi.ProtoOASymbolCategoryListReq:
+		fn = typed(s.symbolCategoryList)
+	case *openapi.ProtoOASymbolsListReq:
+		fn = typed(s.symbolsList)
+	case *openapi.ProtoOASymbolByIdReq:
+		fn = typed(s.symbolByID)
+	case *openapi.ProtoOASubscribeSpotsReq:
+		fn = typed(s.subscribeSpots)
+	case *openapi.ProtoOAUnsubscribeSpotsReq:
+		fn = typed(s.unsubscribeSpots)
+	case *openapi.ProtoOAReconcileReq:
+		fn = typed(s.reconcile)
+	case *openapi.ProtoOANewOrderReq:
+		fn = typed(s.newOrder)
+	case *openapi.ProtoOAAmendOrderReq:
+		fn = typed(s.amendOrder)
+	case *openapi.ProtoOACancelOrderReq:
+		fn = typed(s.cancelOrder)
+	case *openapi.ProtoOAClosePositionReq:
+		fn = typed(s.closePosition)
+	default:
+		return nil
+	}
+	return func(c *conn, req proto.Message) []proto.Message {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if _, ok := req.(*openapi.ProtoOAApplicationAuthReq); !ok && !c.applicationAuthorized {
+			return errorResponse(0, openapi.ProtoOAErrorCode_CH_CLIENT_NOT_AUTHENTICATED, "application not authorized")
+		}
+		accountScoped, ok := req.(interface{ GetCtidTraderAccountId() int64 })
+		if _, auth := req.(*openapi.ProtoOAAccountAuthReq); ok && !auth {
+			if _, authorized := c.accounts[accountScoped.GetCtidTraderAccountId()]; !authorized {
+				return errorResponse(
+					accountScoped.GetCtidTraderAccountId(), openapi.ProtoOAErrorCode_ACCOUNT_NOT_AUTHORIZED,
+					"account not authorized",
+				)
+			}
+		}
+		return fn(c, req)
+	}
+}
+
+func typed[T proto.Message](fn func(*conn, T) []proto.Message) handler {
+	return func(c *conn, req proto.Message) []proto.Message {
+		v, ok := req.(T)
+		if !ok {
+			return nil
+		}
+		return fn(c, v)
+	}
+}
+
+func errorResponse(accountID int64, code openapi.ProtoOAErrorCode, description string) []proto.Message {
+	resp := &openapi.ProtoOAErrorRes{
+		ErrorCode:   proto.String(code.String()),
+		Description: proto.String(description),
+	}
+	if accountID != 0 {
+		resp.CtidTraderAccountId = proto.Int64(accountID)
+	}
+	return []proto.Message{resp}
+}
+
+func orderError(accountID int64, code openapi.ProtoOAErrorCode, description string) []proto.Message {
+	return []proto.Message{&openapi.ProtoOAOrderErrorEvent{
+		CtidTraderAccountId: proto.Int64(accountID),
+		ErrorCode:           proto.String(code.String()),
+		Description:         proto.String(description),
+	}}
+}
+
+func (s *Server) applicationAuth(c *conn, req *openapi.ProtoOAApplicationAuthReq) []proto.Message {
+	if req.GetClientId() != ClientID || req.GetClientSecret() != ClientSecret {
+		return errorResponse(0, openapi.ProtoOAErrorCode_CH_CLIENT_AUTH_FAILURE, "invalid client credentials")
+	}
+	c.applicationAuthorized = true
+	return []proto.Message{&openapi.ProtoOAApplicationAuthRes{}}
+}
+
+func (s *Server) accountList(_ *conn, req *openapi.ProtoOAGetAccountListByAccessTokenReq) []proto.Message {
+	resp := &openapi.ProtoOAGetAccountListByAccessTokenRes{AccessToken: proto.String(req.GetAccessToken())}
+	for _, account := range s.state.accounts {
+		if account.AccessToken != req.GetAccessToken() {
+			continue
+		}
+		resp.CtidTraderAccount = append(resp.CtidTraderAccount, &openapi.ProtoOACtidTraderAccount{
+			CtidTraderAccountId: proto.Uint64(uint64(account.ID)),
+			IsLive:              proto.Bool(account.Live),
+		})
+	}
+	sort.Slice(resp.CtidTraderAccount, func(i, j int) bool {
+		return resp.CtidTraderAccount[i].GetCtidTraderAccountId() < resp.CtidTraderAccount[j].GetCtidTraderAccountId()
+	})
+	return []proto.Message{resp}
+}
+
+func (s *Server) accountAuth(c *conn, req *openapi.ProtoOAAccountAuthReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	account, ok := s.state.accounts[accountID]
+	if !ok {
+		return errorResponse(accountID, openapi.ProtoOAErrorCode_CH_CTID_TRADER_ACCOUNT_NOT_FOUND, "account not found")
+	}
+	if account.AccessToken != req.GetAccessToken() {
+		return errorResponse(accountID, openapi.ProtoOAErrorCode_CH_ACCESS_TOKEN_INVALID, "invalid access token")
+	}
+	c.accounts[accountID] = struct{}{}
+	return []proto.Message{&openapi.ProtoOAAccountAuthRes{CtidTraderAccountId: proto.Int64(accountID)}}
+}
+
+func (s *Server) accountLogout(c *conn, req *openapi.ProtoOAAccountLogoutReq) []proto.Message {
+	delete(c.accounts, req.GetCtidTraderAccountId())
+	delete(c.spots, req.GetCtidTraderAccountId())
+	return []proto.Message{&openapi.ProtoOAAccountLogoutRes{CtidTraderAccountId: req.CtidTraderAccountId}}
+}
+
+func (s *Server) trader(_ *conn, req *openapi.ProtoOATraderReq) []proto.Message {
+	account := s.state.accounts[req.GetCtidTraderAccountId()]
+	return []proto.Message{&openapi.ProtoOATraderRes{
+		CtidTraderAccountId: req.CtidTraderAccountId,
+		Trader: &openapi.ProtoOATrader{
+			CtidTraderAccountId: req.CtidTraderAccountId,
+			Balance:             proto.Int64(account.Balance),
+			DepositAssetId:      proto.Int64(0),
+			MoneyDigits:         proto.Uint32(2),
+			IsLimitedRisk:       proto.Bool(false),
+		},
+	}}
+}
+
+func (s *Server) assetList(_ *conn, req *openapi.ProtoOAAssetListReq) []proto.Message {
+	resp := &openapi.ProtoOAAssetListRes{CtidTraderAccountId: req.CtidTraderAccountId}
+	for _, asset := range s.state.assets {
+		resp.Asset = append(resp.Asset, &openapi.ProtoOAAsset{
+			AssetId:     proto.Int64(asset.ID),
+			Name:        proto.String(asset.Name),
+			DisplayName: proto.String(asset.DisplayName),
+			Digits:      proto.Int32(asset.Digits),
+		})
+	}
+	sort.Slice(resp.Asset, func(i, j int) bool { return resp.Asset[i].GetAssetId() < resp.Asset[j].GetAssetId() })
+	return []proto.Message{resp}
+}
+
+func (s *Server) symbolCategoryList(_ *conn, req *openapi.ProtoOASymbolCategoryListReq) []proto.Message {
+	resp := &openapi.ProtoOASymbolCategoryListRes{CtidTraderAccountId: req.CtidTraderAccountId}
+	for _, category := range s.state.categories {
+		resp.SymbolCategory = append(resp.SymbolCategory, &openapi.ProtoOASymbolCategory{
+			Id:           proto.Int64(category.ID),
+			AssetClassId: proto.Int64(category.AssetClassID),
+			Name:         proto.String(category.Name),
+		})
+	}
+	sort.Slice(resp.SymbolCategory, func(i, j int) bool {
+		return resp.SymbolCategory[i].GetId() < resp.SymbolCategory[j].GetId()
+	})
+	return []proto.Message{resp}
+}
+
+func (s *Server) symbolsList(_ *conn, req *openapi.ProtoOASymbolsListReq) []proto.Message {
+	resp := &openapi.ProtoOASymbolsListRes{CtidTraderAccountId: req.CtidTraderAccountId}
+	for _, symbol := range s.state.symbols {
+		resp.Symbol = append(resp.Symbol, &openapi.ProtoOALightSymbol{
+			SymbolId:         proto.Int64(symbol.ID),
+			SymbolName:       proto.String(symbol.Name),
+			Enabled:          proto.Bool(true),
+			BaseAssetId:      proto.Int64(symbol.BaseAssetID),
+			QuoteAssetId:     proto.Int64(symbol.QuoteAssetID),
+			SymbolCategoryId: proto.Int64(symbol.CategoryID),
+		})
+	}
+	sort.Slice(resp.Symbol, func(i, j int) bool { return resp.Symbol[i].GetSymbolId() < resp.Symbol[j].GetSymbolId() })
+	return []proto.Message{resp}
+}
+
+func (s *Server) symbolByID(_ *conn, req *openapi.ProtoOASymbolByIdReq) []proto.Message {
+	resp := &openapi.ProtoOASymbolByIdRes{CtidTraderAccountId: req.CtidTraderAccountId}
+	for _, id := range req.GetSymbolId() {
+		symbol, ok := s.state.symbols[id]
+		if !ok {
+			continue
+		}
+		resp.Symbol = append(resp.Symbol, &openapi.ProtoOASymbol{
+			SymbolId:    proto.Int64(symbol.ID),
+			Digits:      proto.Int32(symbol.Digits),
+			PipPosition: proto.Int32(symbol.PipPosition),
+			LotSize:     proto.Int64(symbol.LotSize),
+			MinVolume:   proto.Int64(symbol.MinVolume),
+			MaxVolume:   proto.Int64(symbol.MaxVolume),
+			StepVolume:  proto.Int64(symbol.StepVolume),
+		})
+	}
+	return []proto.Message{resp}
+}
+
+func (s *Server) subscribeSpots(c *conn, req *openapi.ProtoOASubscribeSpotsReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	for _, id := range req.GetSymbolId() {
+		if _, ok := s.state.symbols[id]; !ok {
+			return errorResponse(accountID, openapi.ProtoOAErrorCode_SYMBOL_NOT_FOUND, "symbol not found")
+		}
+	}
+	if c.spots[accountID] == nil {
+		c.spots[accountID] = make(map[int64]struct{})
+	}
+	for _, id := range req.GetSymbolId() {
+		c.spots[accountID][id] = struct{}{}
+	}
+	return []proto.Message{&openapi.ProtoOASubscribeSpotsRes{CtidTraderAccountId: req.CtidTraderAccountId}}
+}
+
+func (s *Server) unsubscribeSpots(c *conn, req *openapi.ProtoOAUnsubscribeSpotsReq) []proto.Message {
+	for _, id := range req.GetSymbolId() {
+		delete(c.spots[req.GetCtidTraderAccountId()], id)
+	}
+	return []proto.Message{&openapi.ProtoOAUnsubscribeSpotsRes{CtidTraderAccountId: req.CtidTraderAccountId}}
+}
+
+func (s *Server) reconcile(_ *conn, req *openapi.ProtoOAReconcileReq) []proto.Message {
+	return []proto.Message{&openapi.ProtoOAReconcileRes{
+		CtidTraderAccountId: req.CtidTraderAccountId,
+		Position:            s.state.accountPositions(req.GetCtidTraderAccountId()),
+		Order:               s.state.accountOrders(req.GetCtidTraderAccountId()),
+	}}
+}
+
+// newOrder fills market orders at once using the last spot price, the other order types are kept as pending.
+func (s *Server) newOrder(_ *conn, req *openapi.ProtoOANewOrderReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	if _, ok := s.state.symbols[req.GetSymbolId()]; !ok {
+		return orderError(accountID, openapi.ProtoOAErrorCode_SYMBOL_NOT_FOUND, "symbol not found")
+	}
+	if req.GetVolume() <= 0 {
+		return orderError(accountID, openapi.ProtoOAErrorCode_TRADING_BAD_VOLUME, "invalid volume")
+	}
+	now := time.Now().UnixMilli()
+	order := &openapi.ProtoOAOrder{
+		OrderId: proto.Int64(s.state.next()),
+		TradeData: &openapi.ProtoOATradeData{
+			SymbolId:      req.SymbolId,
+			Volume:        req.Volume,
+			TradeSide:     req.TradeSide,
+			OpenTimestamp: proto.Int64(now),
+			Label:         req.Label,
+			Comment:       req.Comment,
+		},
+		OrderType:              req.OrderType,
+		OrderStatus:            openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED.Enum(),
+		ClientOrderId:          req.ClientOrderId,
+		LimitPrice:             req.LimitPrice,
+		StopPrice:              req.StopPrice,
+		ExpirationTimestamp:    req.ExpirationTimestamp,
+		StopLoss:               req.StopLoss,
+		TakeProfit:             req.TakeProfit,
+		UtcLastUpdateTimestamp: proto.Int64(now),
+	}
+	if req.GetOrderType() != openapi.ProtoOAOrderType_MARKET {
+		s.state.orders[order.GetOrderId()] = &orderEntry{accountID: accountID, order: order}
+		return []proto.Message{execution(accountID, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, order, nil, nil)}
+	}
+
+	executionPrice, ok := s.state.executionPrice(req.GetSymbolId(), req.GetTradeSide())
+	if !ok {
+		return orderError(accountID, openapi.ProtoOAErrorCode_NO_QUOTES, "no quotes for the symbol")
+	}
+	accepted := execution(accountID, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, order, nil, nil)
+
+	position := &openapi.ProtoOAPosition{
+		PositionId:             proto.Int64(s.state.next()),
+		TradeData:              proto.Clone(order.GetTradeData()).(*openapi.ProtoOATradeData), //nolint:forcetypeassert
+		PositionStatus:         openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN.Enum(),
+		Swap:                   proto.Int64(0),
+		Price:                  proto.Float64(executionPrice),
+		StopLoss:               req.StopLoss,
+		TakeProfit:             req.TakeProfit,
+		UtcLastUpdateTimestamp: proto.Int64(now),
+		MoneyDigits:            proto.Uint32(2),
+	}
+	s.state.positions[position.GetPositionId()] = &positionEntry{accountID: accountID, position: position}
+
+	filled := proto.Clone(order).(*openapi.ProtoOAOrder) //nolint:forcetypeassert
+	filled.OrderStatus = openapi.ProtoOAOrderStatus_ORDER_STATUS_FILLED.Enum()
+	filled.ExecutionPrice = proto.Float64(executionPrice)
+	filled.ExecutedVolume = req.Volume
+	filled.PositionId = position.PositionId
+	deal := s.state.deal(filled, now, nil)
+	return []proto.Message{
+		accepted,
+		execution(accountID, openapi.ProtoOAExecutionType_ORDER_FILLED, filled, position, deal),
+	}
+}
+
+func (s *Server) amendOrder(_ *conn, req *openapi.ProtoOAAmendOrderReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	entry, ok := s.state.orders[req.GetOrderId()]
+	if !ok || entry.accountID != accountID {
+		return orderError(accountID, openapi.ProtoOAErrorCode_ORDER_NOT_FOUND, "order not found")
+	}
+	order := entry.order
+	if req.Volume != nil {
+		order.TradeData.Volume = req.Volume
+	}
+	if req.LimitPrice != nil {
+		order.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		order.StopPrice = req.StopPrice
+	}
+	if req.ExpirationTimestamp != nil {
+		order.ExpirationTimestamp = req.ExpirationTimestamp
+	}
+	order.UtcLastUpdateTimestamp = proto.Int64(time.Now().UnixMilli())
+	return []proto.Message{execution(accountID, openapi.ProtoOAExecutionType_ORDER_REPLACED, order, nil, nil)}
+}
+
+func (s *Server) cancelOrder(_ *conn, req *openapi.ProtoOACancelOrderReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	entry, ok := s.state.orders[req.GetOrderId()]
+	if !ok || entry.accountID != accountID {
+		return orderError(accountID, openapi.ProtoOAErrorCode_ORDER_NOT_FOUND, "order not found")
+	}
+	delete(s.state.orders, req.GetOrderId())
+	order := entry.order
+	order.OrderStatus = openapi.ProtoOAOrderStatus_ORDER_STATUS_CANCELLED.Enum()
+	order.UtcLastUpdateTimestamp = proto.Int64(time.Now().UnixMilli())
+	return []proto.Message{execution(accountID, openapi.ProtoOAExecutionType_ORDER_CANCELLED, order, nil, nil)}
+}
+
+// closePosition closes the position using the last spot price. The profit assumes the quote asset is the deposit
+// asset, which is enough for tests.
+func (s *Server) closePosition(_ *conn, req *openapi.ProtoOAClosePositionReq) []proto.Message {
+	accountID := req.GetCtidTraderAccountId()
+	entry, ok := s.state.positions[req.GetPositionId()]
+	if !ok || entry.accountID != accountID {
+		return orderError(accountID, openapi.ProtoOAErrorCode_POSITION_NOT_FOUND, "position not found")
+	}
+	position := entry.position
+	if req.GetVolume() <= 0 || req.GetVolume() > position.GetTradeData().GetVolume() {
+		return orderError(accountID, openapi.ProtoOAErrorCode_TRADING_BAD_VOLUME, "invalid volume")
+	}
+	side := openapi.ProtoOATradeSide_SELL
+	direction := 1.0
+	if position.GetTradeData().GetTradeSide() == openapi.ProtoOATradeSide_SELL {
+		side = openapi.ProtoOATradeSide_BUY
+		direction = -1
+	}
+	executionPrice, ok := s.state.executionPrice(position.GetTradeData().GetSymbolId(), side)
+	if !ok {
+		return orderError(accountID, openapi.ProtoOAErrorCode_NO_QUOTES, "no quotes for the symbol")
+	}
+
+	now := time.Now().UnixMilli()
+	order := &openapi.ProtoOAOrder{
+		OrderId: proto.Int64(s.state.next()),
+		TradeData: &openapi.ProtoOATradeData{
+			SymbolId:      position.GetTradeData().SymbolId,
+			Volume:        req.Volume,
+			TradeSide:     side.Enum(),
+			OpenTimestamp: proto.Int64(now),
+		},
+		OrderType:              openapi.ProtoOAOrderType_MARKET.Enum(),
+		OrderStatus:            openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED.Enum(),
+		PositionId:             position.PositionId,
+		ClosingOrder:           proto.Bool(true),
+		UtcLastUpdateTimestamp: proto.Int64(now),
+	}
+	accepted := execution(accountID, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, order, position, nil)
+
+	account := s.state.accounts[accountID]
+	grossProfit := int64(math.Round(direction * (executionPrice - position.GetPrice()) * float64(req.GetVolume())))
+	account.Balance += grossProfit
+	position.TradeData.Volume = proto.Int64(position.GetTradeData().GetVolume() - req.GetVolume())
+	position.UtcLastUpdateTimestamp = proto.Int64(now)
+	if position.GetTradeData().GetVolume() == 0 {
+		position.PositionStatus = openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED.Enum()
+		delete(s.state.positions, position.GetPositionId())
+	}
+
+	filled := proto.Clone(order).(*openapi.ProtoOAOrder) //nolint:forcetypeassert
+	filled.OrderStatus = openapi.ProtoOAOrderStatus_ORDER_STATUS_FILLED.Enum()
+	filled.ExecutionPrice = proto.Float64(executionPrice)
+	filled.ExecutedVolume = req.Volume
+	deal := s.state.deal(filled, now, &openapi.ProtoOAClosePositionDetail{
+		EntryPrice:     position.Price,
+		GrossProfit:    proto.Int64(grossProfit),
+		Swap:           proto.Int64(0),
+		Commission:     proto.Int64(0),
+		Balance:        proto.Int64(account.Balance),
+		ClosedVolume:   req.Volume,
+		MoneyDigits:    proto.Uint32(2),
+		BalanceVersion: proto.Int64(now),
+	})
+	return []proto.Message{
+		accepted,
+		execution(accountID, openapi.ProtoOAExecutionType_ORDER_FILLED, filled, position, deal),
+	}
+}
+
+// executionPrice returns the price used to fill a market order, buys are filled at the ask and sells at the bid.
+func (s *state) executionPrice(symbolID int64, side openapi.ProtoOATradeSide) (float64, bool) {
+	p, ok := s.prices[symbolID]
+	if !ok {
+		return 0, false
+	}
+	value := p.bid
+	if side == openapi.ProtoOATradeSide_BUY {
+		value = p.ask
+	}
+	return float64(value) / 100000, true
+}
+
+func (s *state) deal(
+	order *openapi.ProtoOAOrder, timestamp int64, detail *openapi.ProtoOAClosePositionDetail,
+) *openapi.ProtoOADeal {
+	return &openapi.ProtoOADeal{
+		DealId:              proto.Int64(s.next()),
+		OrderId:             order.OrderId,
+		PositionId:          order.PositionId,
+		Volume:              order.GetTradeData().Volume,
+		FilledVolume:        order.ExecutedVolume,
+		SymbolId:            order.GetTradeData().SymbolId,
+		CreateTimestamp:     proto.Int64(timestamp),
+		ExecutionTimestamp:  proto.Int64(timestamp),
+		ExecutionPrice:      order.ExecutionPrice,
+		TradeSide:           order.GetTradeData().TradeSide,
+		DealStatus:          openapi.ProtoOADealStatus_FILLED.Enum(),
+		ClosePositionDetail: detail,
+		MoneyDigits:         proto.Uint32(2),
+	}
+}
+
+func execution(
+	accountID int64,
+	executionType openapi.ProtoOAExecutionType,
+	order *openapi.ProtoOAOrder,
+	position *openapi.ProtoOAPosition,
+	deal *openapi.ProtoOADeal,
+) *openapi.ProtoOAExecutionEvent {
+	event := &openapi.ProtoOAExecutionEvent{
+		CtidTraderAccountId: proto.Int64(accountID),
+		ExecutionType:       executionType.Enum(),
+		Order:               proto.Clone(order).(*openapi.ProtoOAOrder), //nolint:forcetypeassert
+		Deal:                deal,
+	}
+	if position != nil {
+		event.Position = proto.Clone(position).(*openapi.ProtoOAPosition) //nolint:forcetypeassert
+	}
+	return event
+}
@@ -0,0 +1,394 @@
+// Package ctradertest provides a fake cTrader Open API server, it's used to test code built on top of ctrader.Client
+// without network access or live credentials.
+//
+// The server speaks the length-prefixed protobuf protocol over a TLS listener at localhost and keeps a small trading
+// state: accounts, symbols, prices, orders and positions. Every request type can be scripted with Handle, and events
+// or connection errors can be injected at any time.
+package ctradertest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/diegobernardes/ctrader"
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+const (
+	// ClientID is the application client ID accepted by the server.
+	ClientID = "ctradertest-client-id"
+
+	// ClientSecret is the application secret accepted by the server.
+	ClientSecret = "ctradertest-client-secret"
+)
+
+type handler func(*conn, proto.Message) []proto.Message
+
+// Server is a fake cTrader Open API server. It must be created with NewServer and closed with Close.
+type Server struct {
+	listener     net.Listener
+	tlsConfig    *tls.Config
+	payloadTypes map[uint32]protoreflect.MessageType
+	wg           sync.WaitGroup
+
+	mutex    sync.Mutex
+	closed   bool
+	conns    map[*conn]struct{}
+	handlers map[protoreflect.FullName]handler
+	requests []proto.Message
+	state    state
+}
+
+// NewServer starts a server listening at a random localhost port. It panics if the server can't be started, like
+// 'httptest.NewServer'.
+func NewServer() *Server {
+	certificate, err := newCertificate()
+	if err != nil {
+		panic(fmt.Sprintf("ctradertest: failed to generate the certificate: %v", err))
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("ctradertest: failed to listen: %v", err))
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate.Leaf)
+	s := &Server{
+		listener:     listener,
+		tlsConfig:    &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		payloadTypes: payloadTypes(),
+		conns:        make(map[*conn]struct{}),
+		handlers:     make(map[protoreflect.FullName]handler),
+		state:        newState(),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Addr returns the server address, like '127.0.0.1:40000'.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// TLSConfig returns a client TLS configuration that trusts the server certificate.
+func (s *Server) TLSConfig() *tls.Config {
+	return s.tlsConfig.Clone()
+}
+
+// Client returns a client configured to use the server. The client still needs to be started.
+func (s *Server) Client() *ctrader.Client {
+	return &ctrader.Client{
+		ApplicationClientID: ClientID,
+		ApplicationSecret:   ClientSecret,
+		Deadline:            5 * time.Second,
+		Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Address:             s.Addr(),
+		TLSConfig:           s.TLSConfig(),
+		Backoff:             ctrader.Backoff{InitialInterval: 10 * time.Millisecond, MaxInterval: 100 * time.Millisecond},
+	}
+}
+
+// Close stops the server and closes all the connections.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	s.closed = true
+	conns := s.connections()
+	s.mutex.Unlock()
+
+	_ = s.listener.Close()
+	for _, c := range conns {
+		c.close()
+	}
+	s.wg.Wait()
+}
+
+// Handle replaces the handler of the requests of type T. The returned messages are sent, in order, with the request
+// 'clientMsgId', this is how the server answers with a response or with the execution events of an order. Returning
+// nothing leaves the request without an answer, which is useful to test timeouts.
+func Handle[T proto.Message](s *Server, fn func(req T) []proto.Message) {
+	var zero T
+	name := zero.ProtoReflect().Descriptor().FullName()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[name] = func(_ *conn, req proto.Message) []proto.Message {
+		v, ok := req.(T)
+		if !ok {
+			return nil
+		}
+		return fn(v)
+	}
+}
+
+// Send delivers the events to every connection.
+func (s *Server) Send(events ...proto.Message) {
+	s.mutex.Lock()
+	conns := s.connections()
+	s.mutex.Unlock()
+	for _, c := range conns {
+		for _, event := range events {
+			c.write("", event)
+		}
+	}
+}
+
+// Drop closes all the connections, the clients see it as a network failure.
+func (s *Server) Drop() {
+	s.mutex.Lock()
+	conns := s.connections()
+	s.mutex.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// Requests returns the requests received by the server, heartbeats are not included.
+func (s *Server) Requests() []proto.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]proto.Message(nil), s.requests...)
+}
+
+// Connections returns the number of open connections.
+func (s *Server) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+func (s *Server) connections() []*conn {
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{
+			server:   s,
+			netConn:  netConn,
+			accounts: make(map[int64]struct{}),
+			spots:    make(map[int64]map[int64]struct{}),
+		}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			_ = netConn.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.receive()
+			s.mutex.Lock()
+			delete(s.conns, c)
+			s.mutex.Unlock()
+		}()
+	}
+}
+
+// handle processes a request, the custom handlers take precedence over the default ones.
+func (s *Server) handle(c *conn, clientMsgID string, req proto.Message) {
+	s.mutex.Lock()
+	s.requests = append(s.requests, req)
+	fn, ok := s.handlers[req.ProtoReflect().Descriptor().FullName()]
+	if !ok {
+		fn = s.defaultHandler(req)
+	}
+	s.mutex.Unlock()
+
+	var messages []proto.Message
+	if fn == nil {
+		name := req.ProtoReflect().Descriptor().Name()
+		messages = []proto.Message{&openapi.ProtoErrorRes{
+			ErrorCode:   proto.String(openapi.ProtoErrorCode_UNSUPPORTED_MESSAGE.String()),
+			Description: proto.String(fmt.Sprintf("request '%s' is not supported", name)),
+		}}
+	} else {
+		messages = fn(c, req)
+	}
+	for _, m := range messages {
+		c.write(clientMsgID, m)
+	}
+}
+
+// conn is a client connection. The state fields are protected by the server mutex.
+type conn struct {
+	server     *Server
+	netConn    net.Conn
+	writeMutex sync.Mutex
+
+	applicationAuthorized bool
+	accounts              map[int64]struct{}
+	spots                 map[int64]map[int64]struct{}
+}
+
+func (c *conn) receive() {
+	defer c.close()
+	reader := bufio.NewReader(c.netConn)
+	bufferLength := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, bufferLength); err != nil {
+			return
+		}
+		buffer := make([]byte, binary.BigEndian.Uint32(bufferLength))
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return
+		}
+		clientMsgID, req, err := c.decode(buffer)
+		if err != nil {
+			c.write(clientMsgID, &openapi.ProtoErrorRes{
+				ErrorCode:   proto.String(openapi.ProtoErrorCode_INVALID_REQUEST.String()),
+				Description: proto.String(err.Error()),
+			})
+			continue
+		}
+		if req == nil {
+			continue
+		}
+		c.server.handle(c, clientMsgID, req)
+	}
+}
+
+// decode returns a nil message for heartbeats.
+func (c *conn) decode(payload []byte) (string, proto.Message, error) {
+	var message openapi.ProtoMessage
+	if err := proto.Unmarshal(payload, &message); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal the message: %w", err)
+	}
+	if message.GetPayloadType() == uint32(openapi.ProtoPayloadType_HEARTBEAT_EVENT) {
+		return "", nil, nil
+	}
+	messageType, ok := c.server.payloadTypes[message.GetPayloadType()]
+	if !ok {
+		return message.GetClientMsgId(), nil, fmt.Errorf("unknown payload type '%d'", message.GetPayloadType())
+	}
+	req := messageType.New().Interface()
+	if err := proto.Unmarshal(message.GetPayload(), req); err != nil {
+		return message.GetClientMsgId(), nil, fmt.Errorf("failed to unmarshal the payload: %w", err)
+	}
+	return message.GetClientMsgId(), req, nil
+}
+
+// write errors are ignored, a broken connection is detected by the receive loop.
+func (c *conn) write(clientMsgID string, m proto.Message) {
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		panic(fmt.Sprintf("ctradertest: failed to marshal '%T': %v", m, err))
+	}
+	message := &openapi.ProtoMessage{
+		PayloadType: proto.Uint32(payloadType(m)),
+		Payload:     payload,
+	}
+	if clientMsgID != "" {
+		message.ClientMsgId = proto.String(clientMsgID)
+	}
+	buf, err := proto.Marshal(message)
+	if err != nil {
+		panic(fmt.Sprintf("ctradertest: failed to marshal the message: %v", err))
+	}
+	frame := make([]byte, 4, 4+len(buf))
+	binary.BigEndian.PutUint32(frame, uint32(len(buf)))
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if err := c.netConn.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return
+	}
+	_, _ = c.netConn.Write(append(frame, buf...))
+}
+
+func (c *conn) close() {
+	_ = c.netConn.Close()
+}
+
+// payloadType returns the default value of the message 'payloadType' field.
+func payloadType(m proto.Message) uint32 {
+	field := m.ProtoReflect().Descriptor().Fields().ByName("payloadType")
+	if field == nil {
+		panic(fmt.Sprintf("ctradertest: message '%T' has no payload type", m))
+	}
+	return uint32(field.Default().Enum())
+}
+
+// payloadTypes indexes the Open API messages by payload type.
+func payloadTypes() map[uint32]protoreflect.MessageType {
+	result := make(map[uint32]protoreflect.MessageType)
+	files := []protoreflect.FileDescriptor{
+		openapi.File_OpenApiCommonMessages_proto,
+		openapi.File_OpenApiMessages_proto,
+	}
+	for _, file := range files {
+		for i := 0; i < file.Messages().Len(); i++ {
+			descriptor := file.Messages().Get(i)
+			field := descriptor.Fields().ByName("payloadType")
+			if field == nil || !field.HasDefault() {
+				continue
+			}
+			messageType, err := protoregistry.GlobalTypes.FindMessageByName(descriptor.FullName())
+			if err != nil {
+				panic(fmt.Sprintf("ctradertest: failed to find the message '%s': %v", descriptor.FullName(), err))
+			}
+			result[uint32(field.Default().Enum())] = messageType
+		}
+	}
+	return result
+}
+
+func newCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate the key: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"ctradertest"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create the certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse the certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,186 @@
+package ctradertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader"
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newTestServer(t *testing.T) (*Server, *ctrader.Client, *ctrader.Account) {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	s.AddAccount(Account{ID: 1, AccessToken: "token", Balance: 1000000})
+	s.AddSymbol(Symbol{ID: 1, Name: "EURUSD", Digits: 5, PipPosition: 4, BaseAssetID: 1, QuoteAssetID: 2})
+	s.Spot(1, 110000, 110010)
+
+	c := s.Client()
+	require.NoError(t, c.Start())
+	t.Cleanup(func() { require.NoError(t, c.Stop()) })
+	account := c.Account(1)
+	require.NoError(t, account.Authorize(context.Background(), "token"))
+	return s, c, account
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should authorize the application and the account", func(t *testing.T) {
+		t.Parallel()
+		s, c, _ := newTestServer(t)
+		require.Equal(t, ctrader.StateReady, c.State())
+		require.Error(t, c.Account(1).Authorize(context.Background(), "invalid"))
+		require.ErrorIs(t, c.Account(2).Authorize(context.Background(), "token"), ctrader.ErrCHCTIDTraderAccountNotFound)
+
+		_, err := ctrader.AccountCommand[*openapi.ProtoOATraderReq, *openapi.ProtoOATraderRes](
+			context.Background(), c.Account(2), &openapi.ProtoOATraderReq{},
+		)
+		require.ErrorIs(t, err, ctrader.ErrAccountNotAuthorized)
+
+		c2 := s.Client()
+		c2.ApplicationSecret = "invalid"
+		require.ErrorIs(t, c2.Start(), ctrader.ErrCHClientAuthFailure)
+	})
+
+	t.Run("Should list the symbols", func(t *testing.T) {
+		t.Parallel()
+		_, _, account := newTestServer(t)
+		resp, err := ctrader.AccountCommand[*openapi.ProtoOASymbolsListReq, *openapi.ProtoOASymbolsListRes](
+			context.Background(), account, &openapi.ProtoOASymbolsListReq{},
+		)
+		require.NoError(t, err)
+		require.Len(t, resp.GetSymbol(), 1)
+		require.Equal(t, "EURUSD", resp.GetSymbol()[0].GetSymbolName())
+
+		respByID, err := ctrader.AccountCommand[*openapi.ProtoOASymbolByIdReq, *openapi.ProtoOASymbolByIdRes](
+			context.Background(), account, &openapi.ProtoOASymbolByIdReq{SymbolId: []int64{1, 2}},
+		)
+		require.NoError(t, err)
+		require.Len(t, respByID.GetSymbol(), 1)
+		require.Equal(t, int32(5), respByID.GetSymbol()[0].GetDigits())
+	})
+
+	t.Run("Should send the spot events", func(t *testing.T) {
+		t.Parallel()
+		s, _, account := newTestServer(t)
+		spots, unsubscribe := ctrader.AccountEvents[*openapi.ProtoOASpotEvent](account, 10)
+		defer unsubscribe()
+
+		_, err := ctrader.AccountCommand[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](
+			context.Background(), account, &openapi.ProtoOASubscribeSpotsReq{SymbolId: []int64{1}},
+		)
+		require.NoError(t, err)
+		s.Spot(1, 110020, 110030)
+		select {
+		case spot := <-spots:
+			require.Equal(t, uint64(110020), spot.GetBid())
+			require.Equal(t, uint64(110030), spot.GetAsk())
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "spot event not received")
+		}
+	})
+
+	t.Run("Should execute the orders", func(t *testing.T) {
+		t.Parallel()
+		s, _, account := newTestServer(t)
+		event, err := account.NewOrder(context.Background(), &openapi.ProtoOANewOrderReq{
+			SymbolId:  proto.Int64(1),
+			OrderType: openapi.ProtoOAOrderType_MARKET.Enum(),
+			TradeSide: openapi.ProtoOATradeSide_BUY.Enum(),
+			Volume:    proto.Int64(100000),
+		}, ctrader.WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_FILLED, event.GetExecutionType())
+		require.InDelta(t, 1.1001, event.GetOrder().GetExecutionPrice(), 0.000001)
+		require.Len(t, s.Positions(1), 1)
+
+		s.Spot(1, 110110, 110120)
+		event, err = account.ClosePosition(context.Background(), &openapi.ProtoOAClosePositionReq{
+			PositionId: event.GetPosition().PositionId,
+			Volume:     proto.Int64(100000),
+		}, ctrader.WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED, event.GetPosition().GetPositionStatus())
+		require.Equal(t, int64(100), event.GetDeal().GetClosePositionDetail().GetGrossProfit())
+		require.Equal(t, int64(1000100), s.Balance(1))
+		require.Empty(t, s.Positions(1))
+
+		event, err = account.NewOrder(context.Background(), &openapi.ProtoOANewOrderReq{
+			SymbolId:   proto.Int64(1),
+			OrderType:  openapi.ProtoOAOrderType_LIMIT.Enum(),
+			TradeSide:  openapi.ProtoOATradeSide_BUY.Enum(),
+			Volume:     proto.Int64(100000),
+			LimitPrice: proto.Float64(1.09),
+		}, ctrader.WaitResponse)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, event.GetExecutionType())
+		require.Len(t, s.Orders(1), 1)
+
+		cancelReq := &openapi.ProtoOACancelOrderReq{OrderId: event.GetOrder().OrderId}
+		event, err = account.CancelOrder(context.Background(), cancelReq, ctrader.WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_CANCELLED, event.GetExecutionType())
+		_, err = account.CancelOrder(context.Background(), cancelReq, ctrader.WaitTerminal)
+		require.ErrorIs(t, err, ctrader.ErrOrderNotFound)
+	})
+
+	t.Run("Should use the scripted handlers", func(t *testing.T) {
+		t.Parallel()
+		s, _, account := newTestServer(t)
+		Handle(s, func(req *openapi.ProtoOANewOrderReq) []proto.Message {
+			return []proto.Message{&openapi.ProtoOAErrorRes{
+				CtidTraderAccountId: req.CtidTraderAccountId,
+				ErrorCode:           proto.String(string(ctrader.ErrMarketClosed)),
+			}}
+		})
+		_, err := account.NewOrder(context.Background(), &openapi.ProtoOANewOrderReq{
+			SymbolId:  proto.Int64(1),
+			OrderType: openapi.ProtoOAOrderType_MARKET.Enum(),
+			TradeSide: openapi.ProtoOATradeSide_BUY.Enum(),
+			Volume:    proto.Int64(100000),
+		}, ctrader.WaitResponse)
+		require.ErrorIs(t, err, ctrader.ErrMarketClosed)
+	})
+
+	t.Run("Should reconnect and restore the session after a drop", func(t *testing.T) {
+		t.Parallel()
+		s, c, _ := newTestServer(t)
+		changes := make(chan ctrader.StateChange, 10)
+		c.OnStateChange(func(change ctrader.StateChange) { changes <- change })
+		events, unsubscribe := ctrader.Events[*openapi.ProtoOATraderUpdatedEvent](c, 1)
+		defer unsubscribe()
+
+		s.Drop()
+		for change := range changes {
+			if change.To == ctrader.StateReady {
+				break
+			}
+		}
+		require.Eventually(t, func() bool { return s.Connections() == 1 }, 5*time.Second, 10*time.Millisecond)
+		authorizations := 0
+		for _, req := range s.Requests() {
+			if _, ok := req.(*openapi.ProtoOAAccountAuthReq); ok {
+				authorizations++
+			}
+		}
+		require.Equal(t, 2, authorizations)
+
+		s.Send(&openapi.ProtoOATraderUpdatedEvent{
+			CtidTraderAccountId: proto.Int64(1),
+			Trader: &openapi.ProtoOATrader{
+				CtidTraderAccountId: proto.Int64(1), Balance: proto.Int64(1), DepositAssetId: proto.Int64(1),
+			},
+		})
+		select {
+		case <-events:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "event not received")
+		}
+	})
+}
@@ -0,0 +1,428 @@
+package ctrader
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// PriceDigits is the number of decimal digits of the prices sent by the Open API. The prices at spots, trendbars and
+// tick data are integers in 1/100000 of the unit, whatever the digits of the symbol are.
+const PriceDigits = 5
+
+// VolumeDigits is the number of decimal digits of the volumes, which are in cents of the base asset units.
+const VolumeDigits = 2
+
+// maxDecimalScale is the largest scale supported, bigger values overflow an int64.
+const maxDecimalScale = 18
+
+// Decimal is a fixed point number with the value of 'Value * 10^-Scale'. It's used to represent the prices, volumes
+// and money without the rounding errors of float64.
+type Decimal struct {
+	Value int64
+	Scale int32
+}
+
+// NewDecimal returns the decimal 'value * 10^-scale'.
+func NewDecimal(value int64, scale int32) Decimal {
+	return Decimal{Value: value, Scale: scale}
+}
+
+// ParseDecimal parses a number like '-1.2345'. The scale of the result is the number of digits after the point.
+func ParseDecimal(s string) (Decimal, error) {
+	raw := s
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	integer, fraction, _ := strings.Cut(s, ".")
+	if integer == "" && fraction == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal '%s'", raw)
+	}
+	if len(integer)+len(fraction) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal '%s' has too many digits", raw)
+	}
+	for _, digit := range integer + fraction {
+		if digit < '0' || digit > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal '%s'", raw)
+		}
+	}
+	value, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("failed to parse the decimal '%s': %w", raw, err)
+	}
+	if negative {
+		value = -value
+	}
+	return Decimal{Value: value, Scale: int32(len(fraction))}, nil
+}
+
+// Rescale returns the decimal with another scale. When the scale is reduced the value is rounded half away from
+// zero. The error is returned when the value doesn't fit an int64 at the new scale.
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	if scale <= d.Scale {
+		return Decimal{Value: scaleDown(d.Value, d.Scale-scale), Scale: scale}, nil
+	}
+	value, ok := scaleUp(d.Value, scale-d.Scale)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal '%s' overflows at the scale %d", d, scale)
+	}
+	return Decimal{Value: value, Scale: scale}, nil
+}
+
+// Add returns the sum of the decimals, with the largest scale of both. The error is returned on overflow.
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	scale := max(d.Scale, o.Scale)
+	a, err := d.Rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	b, err := o.Rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	sum := a.Value + b.Value
+	if (sum > a.Value) != (b.Value > 0) {
+		return Decimal{}, fmt.Errorf("the sum of '%s' and '%s' overflows", d, o)
+	}
+	return Decimal{Value: sum, Scale: scale}, nil
+}
+
+// Sub returns the difference of the decimals, with the largest scale of both. The error is returned on overflow.
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	neg, err := o.Neg()
+	if err != nil {
+		return Decimal{}, err
+	}
+	return d.Add(neg)
+}
+
+// Neg returns the decimal with the opposite sign. The error is returned for the smallest int64, which has no
+// opposite.
+func (d Decimal) Neg() (Decimal, error) {
+	if d.Value == math.MinInt64 {
+		return Decimal{}, fmt.Errorf("the negation of '%s' overflows", d)
+	}
+	return Decimal{Value: -d.Value, Scale: d.Scale}, nil
+}
+
+// Cmp compares the decimals and returns -1, 0 or +1. The comparison is exact, whatever the scales are.
+func (d Decimal) Cmp(o Decimal) int {
+	return d.rat().Cmp(o.rat())
+}
+
+// IsZero tells if the decimal is zero.
+func (d Decimal) IsZero() bool {
+	return d.Value == 0
+}
+
+// Float64 returns the closest float64 of the decimal.
+func (d Decimal) Float64() float64 {
+	f, err := strconv.ParseFloat(d.String(), 64)
+	if err != nil {
+		return float64(d.Value) / math.Pow10(int(d.Scale))
+	}
+	return f
+}
+
+// String returns the decimal with all the digits of its scale, like '1.20000'.
+func (d Decimal) String() string {
+	if d.Scale <= 0 {
+		if d.Value == 0 {
+			return "0"
+		}
+		return strconv.FormatInt(d.Value, 10) + strings.Repeat("0", int(-d.Scale))
+	}
+	digits := strconv.FormatUint(absUint(d.Value), 10)
+	if pad := int(d.Scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.Scale)
+	s := digits[:point] + "." + digits[point:]
+	if d.Value < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// Price is a price as sent by the Open API, in 1/100000 of the unit. The price of EURUSD 1.08123 is 108123 and the
+// price of USDJPY 151.123 is 15112300.
+type Price int64
+
+// NewPrice converts a decimal to a price, rounding the digits that don't fit.
+func NewPrice(d Decimal) (Price, error) {
+	d, err := d.Rescale(PriceDigits)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert to price: %w", err)
+	}
+	return Price(d.Value), nil
+}
+
+// ParsePrice parses a price like '1.08123'.
+func ParsePrice(s string) (Price, error) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return 0, err
+	}
+	return NewPrice(d)
+}
+
+// PriceFromFloat64 converts the prices that the Open API sends as double, like 'ProtoOAPosition.Price', rounding them
+// to 'PriceDigits'.
+func PriceFromFloat64(f float64) Price {
+	return Price(math.Round(f * math.Pow10(PriceDigits)))
+}
+
+// Decimal returns the price as a decimal with the scale of 'PriceDigits'.
+func (p Price) Decimal() Decimal {
+	return Decimal{Value: int64(p), Scale: PriceDigits}
+}
+
+// Round rounds the price to the digits of the symbol. The prices too close to the int64 limits to be rounded away
+// from zero are truncated instead.
+func (p Price) Round(digits int32) Price {
+	if digits >= PriceDigits {
+		return p
+	}
+	if PriceDigits-digits > maxDecimalScale {
+		return 0
+	}
+	factor := pow10(PriceDigits - digits)
+	if value, ok := mulInt64(divRound(int64(p), factor), factor); ok {
+		return Price(value)
+	}
+	return Price(int64(p) / factor * factor)
+}
+
+// Format returns the price with the digits of the symbol, like '151.123' for USDJPY.
+func (p Price) Format(digits int32) string {
+	if digits >= PriceDigits {
+		return p.Decimal().String() + strings.Repeat("0", int(digits-PriceDigits))
+	}
+	return Decimal{Value: scaleDown(int64(p), PriceDigits-digits), Scale: digits}.String()
+}
+
+// Float64 returns the price as float64.
+func (p Price) Float64() float64 {
+	return p.Decimal().Float64()
+}
+
+// String returns the price with all the 'PriceDigits' digits.
+func (p Price) String() string {
+	return p.Decimal().String()
+}
+
+// Pips returns the number of pips of a price difference. The pip position is 4 for most of the pairs and 2 for the
+// JPY ones.
+func (p Price) Pips(pipPosition int32) Decimal {
+	return Decimal{Value: int64(p), Scale: PriceDigits - pipPosition}
+}
+
+// Points returns the number of points of a price difference, which is the smallest change of the price of the
+// symbol.
+func (p Price) Points(digits int32) Decimal {
+	return Decimal{Value: int64(p), Scale: PriceDigits - digits}
+}
+
+// PriceFromPips returns the price difference of a number of pips.
+func PriceFromPips(pips Decimal, pipPosition int32) (Price, error) {
+	d, err := pips.Rescale(PriceDigits - pipPosition)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert the pips to price: %w", err)
+	}
+	return Price(d.Value), nil
+}
+
+// PriceFromPoints returns the price difference of a number of points.
+func PriceFromPoints(points Decimal, digits int32) (Price, error) {
+	d, err := points.Rescale(PriceDigits - digits)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert the points to price: %w", err)
+	}
+	return Price(d.Value), nil
+}
+
+// Volume is a volume as used by the Open API, in cents of the base asset units. A volume of 1000 units is 100000.
+type Volume int64
+
+// NewVolume converts a number of units to volume, rounding the digits that don't fit.
+func NewVolume(units Decimal) (Volume, error) {
+	d, err := units.Rescale(VolumeDigits)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert to volume: %w", err)
+	}
+	return Volume(d.Value), nil
+}
+
+// VolumeFromLots converts a number of lots to volume. The lot size is in cents, as found at 'ProtoOASymbol.LotSize'.
+func VolumeFromLots(lots Decimal, lotSize int64) (Volume, error) {
+	volume := new(big.Rat).Mul(lots.rat(), new(big.Rat).SetInt64(lotSize))
+	value, ok := roundRat(volume)
+	if !ok {
+		return 0, fmt.Errorf("the volume of %s lots overflows", lots)
+	}
+	return Volume(value), nil
+}
+
+// Units returns the volume in units of the base asset.
+func (v Volume) Units() Decimal {
+	return Decimal{Value: int64(v), Scale: VolumeDigits}
+}
+
+// Lots returns the volume in lots. The lot size is in cents, as found at 'ProtoOASymbol.LotSize'. The result is exact
+// when possible and rounded to 8 digits otherwise.
+func (v Volume) Lots(lotSize int64) Decimal {
+	const maxScale = 8
+	if lotSize == 0 {
+		return Decimal{}
+	}
+	for scale := int32(0); scale < maxScale; scale++ {
+		value, ok := mulInt64(int64(v), pow10(scale))
+		if !ok {
+			break
+		}
+		if value%lotSize == 0 {
+			return Decimal{Value: value / lotSize, Scale: scale}
+		}
+	}
+	// The volume divided by the lot size always fits at the scale 0, so the largest scale that fits is used.
+	lots := new(big.Rat).SetFrac(big.NewInt(int64(v)), big.NewInt(lotSize))
+	for scale := int32(maxScale); scale > 0; scale-- {
+		if value, ok := roundRat(new(big.Rat).Mul(lots, new(big.Rat).SetInt64(pow10(scale)))); ok {
+			return Decimal{Value: value, Scale: scale}
+		}
+	}
+	value, _ := roundRat(lots)
+	return Decimal{Value: value}
+}
+
+// String returns the volume in units.
+func (v Volume) String() string {
+	return v.Units().String()
+}
+
+// Money is a monetary value as sent by the Open API. The digits come from the 'moneyDigits' field of the message, or
+// from the trader when the message don't have it.
+type Money struct {
+	Value  int64
+	Digits uint32
+}
+
+// NewMoney returns the money of a value with the given money digits.
+func NewMoney(value int64, moneyDigits uint32) Money {
+	return Money{Value: value, Digits: moneyDigits}
+}
+
+// Decimal returns the money as decimal.
+func (m Money) Decimal() Decimal {
+	return Decimal{Value: m.Value, Scale: int32(m.Digits)}
+}
+
+// Round returns the money with other digits, like the money digits of another message or 2 for USD. It's rounded
+// half away from zero when the digits are reduced, and the error is returned when the value doesn't fit an int64.
+func (m Money) Round(digits uint32) (Decimal, error) {
+	return m.Decimal().Rescale(int32(digits))
+}
+
+// Float64 returns the money as float64.
+func (m Money) Float64() float64 {
+	return m.Decimal().Float64()
+}
+
+// String returns the money with all its digits.
+func (m Money) String() string {
+	return m.Decimal().String()
+}
+
+// pow10 returns 10^n, n must be between 0 and maxDecimalScale.
+func pow10(n int32) int64 {
+	if n < 0 || n > maxDecimalScale {
+		panic(errors.New("decimal scale out of range"))
+	}
+	result := int64(1)
+	for range n {
+		result *= 10
+	}
+	return result
+}
+
+// scaleUp multiplies the value by 10^n, it's false on overflow.
+func scaleUp(value int64, n int32) (int64, bool) {
+	if value == 0 {
+		return 0, true
+	}
+	if n > maxDecimalScale {
+		return 0, false
+	}
+	return mulInt64(value, pow10(n))
+}
+
+// scaleDown divides the value by 10^n rounding half away from zero. Only the values with 19 digits can round to one
+// when n is 19, and every value rounds to zero with a larger n.
+func scaleDown(value int64, n int32) int64 {
+	switch {
+	case n <= maxDecimalScale:
+		return divRound(value, pow10(n))
+	case n == maxDecimalScale+1 && absUint(value) >= 5*uint64(pow10(maxDecimalScale)):
+		if value < 0 {
+			return -1
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+// mulInt64 multiplies the values, it's false on overflow.
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
+
+// rat returns the decimal as an exact rational number.
+func (d Decimal) rat() *big.Rat {
+	r := new(big.Rat).SetInt64(d.Value)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absUint(int64(d.Scale)))), nil))
+	if d.Scale >= 0 {
+		return r.Quo(r, scale)
+	}
+	return r.Mul(r, scale)
+}
+
+// roundRat rounds the rational number half away from zero, it's false when the result doesn't fit an int64.
+func roundRat(r *big.Rat) (int64, bool) {
+	quotient, remainder := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(r.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return 0, false
+	}
+	return quotient.Int64(), true
+}
+
+// divRound divides rounding half away from zero.
+func divRound(a, b int64) int64 {
+	quotient, remainder := a/b, a%b
+	if absUint(remainder)*2 >= absUint(b) {
+		if (a < 0) != (b < 0) {
+			return quotient - 1
+		}
+		return quotient + 1
+	}
+	return quotient
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
@@ -0,0 +1,189 @@
+package ctrader
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should parse and format", func(t *testing.T) {
+		t.Parallel()
+		for _, tt := range []struct {
+			input    string
+			expected Decimal
+			output   string
+		}{
+			{"1.08123", NewDecimal(108123, 5), "1.08123"},
+			{"-0.005", NewDecimal(-5, 3), "-0.005"},
+			{"+12", NewDecimal(12, 0), "12"},
+			{".5", NewDecimal(5, 1), "0.5"},
+			{"10.", NewDecimal(10, 0), "10"},
+		} {
+			d, err := ParseDecimal(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, d)
+			require.Equal(t, tt.output, d.String())
+		}
+
+		for _, input := range []string{"", "-", ".", "1.2.3", "1e5", "abc", "1234567890123456789"} {
+			_, err := ParseDecimal(input)
+			require.Error(t, err, input)
+		}
+	})
+
+	t.Run("Should rescale rounding half away from zero", func(t *testing.T) {
+		t.Parallel()
+		for _, tt := range []struct {
+			input    Decimal
+			scale    int32
+			expected Decimal
+		}{
+			{NewDecimal(105, 2), 1, NewDecimal(11, 1)},
+			{NewDecimal(-105, 2), 1, NewDecimal(-11, 1)},
+			{NewDecimal(104, 2), 1, NewDecimal(10, 1)},
+			{NewDecimal(12, 1), 3, NewDecimal(1200, 3)},
+			{NewDecimal(math.MaxInt64, 19), 0, NewDecimal(1, 0)},
+			{NewDecimal(math.MaxInt64, 40), 0, NewDecimal(0, 0)},
+			{NewDecimal(0, 0), 40, NewDecimal(0, 40)},
+		} {
+			d, err := tt.input.Rescale(tt.scale)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, d)
+		}
+		require.Equal(t, "120", NewDecimal(12, -1).String())
+		require.Equal(t, "1000000000000000000000", NewDecimal(1, -21).String())
+	})
+
+	t.Run("Should return an error on overflow", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewDecimal(math.MaxInt64/10+1, 0).Rescale(1)
+		require.Error(t, err)
+		_, err = NewDecimal(1, 0).Rescale(19)
+		require.Error(t, err)
+		_, err = NewDecimal(1, 0).Add(NewDecimal(1, 19))
+		require.Error(t, err)
+		_, err = NewDecimal(math.MaxInt64, 0).Add(NewDecimal(1, 0))
+		require.Error(t, err)
+		_, err = NewDecimal(math.MinInt64, 0).Sub(NewDecimal(1, 0))
+		require.Error(t, err)
+		_, err = NewDecimal(0, 0).Sub(NewDecimal(math.MinInt64, 0))
+		require.Error(t, err)
+		_, err = NewPrice(NewDecimal(math.MaxInt64, 0))
+		require.Error(t, err)
+		_, err = ParsePrice("999999999999999")
+		require.Error(t, err)
+	})
+
+	t.Run("Should do arithmetic", func(t *testing.T) {
+		t.Parallel()
+		sum, err := NewDecimal(1, 1).Add(NewDecimal(2, 1))
+		require.NoError(t, err)
+		require.Equal(t, "0.3", sum.String())
+		require.InDelta(t, 0.3, sum.Float64(), 0)
+		difference, err := NewDecimal(1, 0).Sub(NewDecimal(5, 2))
+		require.NoError(t, err)
+		require.Equal(t, "0.95", difference.String())
+		require.Equal(t, 0, NewDecimal(10, 1).Cmp(NewDecimal(1, 0)))
+		require.Equal(t, -1, NewDecimal(-1, 0).Cmp(NewDecimal(1, 3)))
+		require.Equal(t, 1, NewDecimal(1, 0).Cmp(NewDecimal(1, 19)))
+		require.Equal(t, 1, NewDecimal(math.MaxInt64, 0).Cmp(NewDecimal(math.MaxInt64, 1)))
+		require.True(t, NewDecimal(0, 4).IsZero())
+	})
+}
+
+func TestPrice(t *testing.T) {
+	t.Parallel()
+
+	eurusd := Symbol{Digits: 5, PipPosition: 4, LotSize: 10000000}
+	usdjpy := Symbol{Digits: 3, PipPosition: 2, LotSize: 10000000}
+
+	t.Run("Should format with the symbol digits", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, "1.08123", eurusd.FormatPrice(108123))
+		require.Equal(t, "151.123", usdjpy.FormatPrice(15112300))
+		require.Equal(t, "151.12300", Price(15112300).String())
+		require.Equal(t, Price(15112400), Price(15112350).Round(usdjpy.Digits))
+		require.Equal(t, Price(math.MaxInt64/100*100), Price(math.MaxInt64).Round(3))
+		require.Equal(t, Price(0), Price(math.MaxInt64).Round(-20))
+		require.Equal(t, "1.0812300", Price(108123).Format(7))
+
+		price, err := ParsePrice("151.123")
+		require.NoError(t, err)
+		require.Equal(t, Price(15112300), price)
+		require.InDelta(t, 151.123, price.Float64(), 0)
+		require.Equal(t, Price(110000), PriceFromFloat64(1.1))
+		require.Equal(t, Price(-15112300), PriceFromFloat64(-151.123))
+	})
+
+	t.Run("Should convert pips and points", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, "1.5", eurusd.Pips(Price(108138)-Price(108123)).String())
+		require.Equal(t, "15", Price(15).Points(eurusd.Digits).String())
+		require.Equal(t, "1.500", usdjpy.Pips(Price(15112300)-Price(15110800)).String())
+		require.Equal(t, "15.00", Price(1500).Points(usdjpy.Digits).String())
+
+		for _, tt := range []struct {
+			convert  func() (Price, error)
+			expected Price
+		}{
+			{func() (Price, error) { return usdjpy.PriceFromPips(NewDecimal(1, 0)) }, 1000},
+			{func() (Price, error) { return eurusd.PriceFromPips(NewDecimal(1, 0)) }, 10},
+			{func() (Price, error) { return PriceFromPips(NewDecimal(-25, 1), 4) }, -25},
+			{func() (Price, error) { return PriceFromPoints(NewDecimal(3, 0), 3) }, 300},
+		} {
+			price, err := tt.convert()
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, price)
+		}
+		_, err := eurusd.PriceFromPips(NewDecimal(math.MaxInt64, 0))
+		require.Error(t, err)
+	})
+}
+
+func TestVolume(t *testing.T) {
+	t.Parallel()
+
+	symbol := Symbol{LotSize: 10000000}
+	require.Equal(t, "1000.00", Volume(100000).String())
+	require.Equal(t, "0.01", symbol.Lots(100000).String())
+	require.Equal(t, "1", symbol.Lots(10000000).String())
+	require.Equal(t, "0.33333333", Volume(1).Lots(3).String())
+	require.Equal(t, "3074457345618258602", Volume(math.MaxInt64).Lots(3).String())
+
+	for _, tt := range []struct {
+		lots     Decimal
+		expected Volume
+	}{
+		{NewDecimal(1, 2), 100000},
+		{NewDecimal(2, 0), 20000000},
+		{NewDecimal(1, 8), 0},
+	} {
+		volume, err := symbol.VolumeFromLots(tt.lots)
+		require.NoError(t, err)
+		require.Equal(t, tt.expected, volume)
+	}
+	_, err := symbol.VolumeFromLots(NewDecimal(math.MaxInt64, 0))
+	require.Error(t, err)
+
+	volume, err := NewVolume(NewDecimal(15, 1))
+	require.NoError(t, err)
+	require.Equal(t, Volume(150), volume)
+}
+
+func TestMoney(t *testing.T) {
+	t.Parallel()
+
+	money := NewMoney(10053099944, 8)
+	require.Equal(t, "100.53099944", money.String())
+	rounded, err := money.Round(2)
+	require.NoError(t, err)
+	require.Equal(t, "100.53", rounded.String())
+	_, err = NewMoney(math.MaxInt64, 0).Round(2)
+	require.Error(t, err)
+	require.InDelta(t, 100.53099944, money.Float64(), 0)
+	require.Equal(t, "-12.34", NewMoney(-1234, 2).String())
+}
@@ -0,0 +1,147 @@
+package ctrader
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultDownloadConcurrency is the number of windows downloaded at the same time when the concurrency is not set.
+// The requests are still subject to the historical rate limit of the client.
+const defaultDownloadConcurrency = 4
+
+// downloadWindow is a half-open time range, [from, to), downloaded by a set of requests.
+type downloadWindow struct {
+	from time.Time
+	to   time.Time
+}
+
+// splitWindows splits the time range into windows of at most size.
+func splitWindows(from, to time.Time, size time.Duration) []downloadWindow {
+	var windows []downloadWindow
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		windows = append(windows, downloadWindow{from: start, to: end})
+	}
+	return windows
+}
+
+type downloadResult[T any] struct {
+	items []T
+	err   error
+}
+
+// downloader fetches windows concurrently and delivers their items in order. A window only starts when there is a
+// free slot, and the slot is released when the consumer moves to the next window, this way the memory is bounded by
+// the concurrency. When timeOf is set, the items of each window are sorted and the items with a time that is not after
+// the previous item are dropped, which removes the duplicates at the boundaries of the windows and pages.
+type downloader[T any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	slots  chan struct{}
+	fetch  func(context.Context, downloadWindow) ([]T, error)
+	timeOf func(T) time.Time
+
+	windows []downloadWindow
+	results []chan downloadResult[T]
+	index   int
+	items   []T
+	current T
+	last    time.Time
+	started bool
+	err     error
+}
+
+func newDownloader[T any](
+	ctx context.Context,
+	windows []downloadWindow,
+	concurrency int,
+	fetch func(context.Context, downloadWindow) ([]T, error),
+	timeOf func(T) time.Time,
+) *downloader[T] {
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+	d := &downloader[T]{
+		slots:   make(chan struct{}, concurrency),
+		fetch:   fetch,
+		timeOf:  timeOf,
+		windows: windows,
+		results: make([]chan downloadResult[T], len(windows)),
+	}
+	for i := range d.results {
+		d.results[i] = make(chan downloadResult[T], 1)
+	}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// newFailedDownloader returns a downloader that only reports the error.
+func newFailedDownloader[T any](err error) *downloader[T] {
+	return &downloader[T]{err: err, cancel: func() {}}
+}
+
+func (d *downloader[T]) run() {
+	defer d.wg.Done()
+	for i, window := range d.windows {
+		select {
+		case d.slots <- struct{}{}:
+		case <-d.ctx.Done():
+			return
+		}
+		d.wg.Add(1)
+		go func(i int, window downloadWindow) {
+			defer d.wg.Done()
+			items, err := d.fetch(d.ctx, window)
+			d.results[i] <- downloadResult[T]{items: items, err: err}
+		}(i, window)
+	}
+}
+
+func (d *downloader[T]) next() bool {
+	for d.err == nil {
+		if len(d.items) > 0 {
+			item := d.items[0]
+			d.items = d.items[1:]
+			if d.timeOf != nil {
+				t := d.timeOf(item)
+				if d.started && !t.After(d.last) {
+					continue
+				}
+				d.last, d.started = t, true
+			}
+			d.current = item
+			return true
+		}
+		if d.index == len(d.windows) {
+			return false
+		}
+
+		result := <-d.results[d.index]
+		<-d.slots
+		d.index++
+		if result.err != nil {
+			d.err = result.err
+			d.cancel()
+			return false
+		}
+		d.items = result.items
+		if d.timeOf == nil {
+			continue
+		}
+		sort.SliceStable(d.items, func(i, j int) bool { return d.timeOf(d.items[i]).Before(d.timeOf(d.items[j])) })
+	}
+	return false
+}
+
+func (d *downloader[T]) close() {
+	d.cancel()
+	d.wg.Wait()
+}
@@ -0,0 +1,241 @@
+package ctrader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// ErrorCode is an error code returned by the cTrader Open API, the name of an 'openapi.ProtoErrorCode' or
+// 'openapi.ProtoOAErrorCode' value. The codes are errors, which allows checks like
+// 'errors.Is(err, ctrader.ErrNotEnoughMoney)' on the errors returned by Command.
+type ErrorCode string //nolint:errname
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
+// Codes from 'openapi.ProtoErrorCode', check the enum for the description of each code.
+const (
+	ErrUnknownError           ErrorCode = "UNKNOWN_ERROR"
+	ErrUnsupportedMessage     ErrorCode = "UNSUPPORTED_MESSAGE"
+	ErrInvalidRequest         ErrorCode = "INVALID_REQUEST"
+	ErrTimeoutError           ErrorCode = "TIMEOUT_ERROR"
+	ErrEntityNotFound         ErrorCode = "ENTITY_NOT_FOUND"
+	ErrCantRouteRequest       ErrorCode = "CANT_ROUTE_REQUEST"
+	ErrFrameTooLong           ErrorCode = "FRAME_TOO_LONG"
+	ErrMarketClosed           ErrorCode = "MARKET_CLOSED"
+	ErrConcurrentModification ErrorCode = "CONCURRENT_MODIFICATION"
+	ErrBlockedPayloadType     ErrorCode = "BLOCKED_PAYLOAD_TYPE"
+)
+
+// Codes from 'openapi.ProtoOAErrorCode', check the enum for the description of each code.
+const (
+	// Authorization.
+	ErrOAAuthTokenExpired           ErrorCode = "OA_AUTH_TOKEN_EXPIRED"
+	ErrAccountNotAuthorized         ErrorCode = "ACCOUNT_NOT_AUTHORIZED"
+	ErrRetNoSuchLogin               ErrorCode = "RET_NO_SUCH_LOGIN"
+	ErrAlreadyLoggedIn              ErrorCode = "ALREADY_LOGGED_IN"
+	ErrRetAccountDisabled           ErrorCode = "RET_ACCOUNT_DISABLED"
+	ErrCHClientAuthFailure          ErrorCode = "CH_CLIENT_AUTH_FAILURE"
+	ErrCHClientNotAuthenticated     ErrorCode = "CH_CLIENT_NOT_AUTHENTICATED"
+	ErrCHClientAlreadyAuthenticated ErrorCode = "CH_CLIENT_ALREADY_AUTHENTICATED"
+	ErrCHAccessTokenInvalid         ErrorCode = "CH_ACCESS_TOKEN_INVALID"
+	ErrCHServerNotReachable         ErrorCode = "CH_SERVER_NOT_REACHABLE"
+	ErrCHCTIDTraderAccountNotFound  ErrorCode = "CH_CTID_TRADER_ACCOUNT_NOT_FOUND"
+	ErrCHOAClientNotFound           ErrorCode = "CH_OA_CLIENT_NOT_FOUND"
+
+	// General.
+	ErrRequestFrequencyExceeded ErrorCode = "REQUEST_FREQUENCY_EXCEEDED"
+	ErrServerIsUnderMaintenance ErrorCode = "SERVER_IS_UNDER_MAINTENANCE"
+	ErrChannelIsBlocked         ErrorCode = "CHANNEL_IS_BLOCKED"
+	ErrConnectionsLimitExceeded ErrorCode = "CONNECTIONS_LIMIT_EXCEEDED"
+	ErrWorseGSLNotAllowed       ErrorCode = "WORSE_GSL_NOT_ALLOWED"
+	ErrSymbolHasHoliday         ErrorCode = "SYMBOL_HAS_HOLIDAY"
+
+	// Pricing.
+	ErrNotSubscribedToSpots ErrorCode = "NOT_SUBSCRIBED_TO_SPOTS"
+	ErrAlreadySubscribed    ErrorCode = "ALREADY_SUBSCRIBED"
+	ErrSymbolNotFound       ErrorCode = "SYMBOL_NOT_FOUND"
+	ErrUnknownSymbol        ErrorCode = "UNKNOWN_SYMBOL"
+	ErrIncorrectBoundaries  ErrorCode = "INCORRECT_BOUNDARIES"
+
+	// Trading.
+	ErrNoQuotes                     ErrorCode = "NO_QUOTES"
+	ErrNotEnoughMoney               ErrorCode = "NOT_ENOUGH_MONEY"
+	ErrMaxExposureReached           ErrorCode = "MAX_EXPOSURE_REACHED"
+	ErrPositionNotFound             ErrorCode = "POSITION_NOT_FOUND"
+	ErrOrderNotFound                ErrorCode = "ORDER_NOT_FOUND"
+	ErrPositionNotOpen              ErrorCode = "POSITION_NOT_OPEN"
+	ErrPositionLocked               ErrorCode = "POSITION_LOCKED"
+	ErrTooManyPositions             ErrorCode = "TOO_MANY_POSITIONS"
+	ErrTradingBadVolume             ErrorCode = "TRADING_BAD_VOLUME"
+	ErrTradingBadStops              ErrorCode = "TRADING_BAD_STOPS"
+	ErrTradingBadPrices             ErrorCode = "TRADING_BAD_PRICES"
+	ErrTradingBadStake              ErrorCode = "TRADING_BAD_STAKE"
+	ErrProtectionIsTooCloseToMarket ErrorCode = "PROTECTION_IS_TOO_CLOSE_TO_MARKET"
+	ErrTradingBadExpirationDate     ErrorCode = "TRADING_BAD_EXPIRATION_DATE"
+	ErrPendingExecution             ErrorCode = "PENDING_EXECUTION"
+	ErrTradingDisabled              ErrorCode = "TRADING_DISABLED"
+	ErrTradingNotAllowed            ErrorCode = "TRADING_NOT_ALLOWED"
+	ErrUnableToCancelOrder          ErrorCode = "UNABLE_TO_CANCEL_ORDER"
+	ErrUnableToAmendOrder           ErrorCode = "UNABLE_TO_AMEND_ORDER"
+	ErrShortSellingNotAllowed       ErrorCode = "SHORT_SELLING_NOT_ALLOWED"
+	ErrNotSubscribedToPnL           ErrorCode = "NOT_SUBSCRIBED_TO_PNL"
+)
+
+// Retryable reports if the same request may succeed if it's sent again later.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrTimeoutError, ErrCantRouteRequest, ErrConcurrentModification, ErrBlockedPayloadType,
+		ErrCHServerNotReachable, ErrRequestFrequencyExceeded, ErrServerIsUnderMaintenance, ErrNoQuotes,
+		ErrPositionLocked, ErrPendingExecution:
+		return true
+	default:
+		return false
+	}
+}
+
+// Auth reports if the error is caused by the application or account authorization.
+func (c ErrorCode) Auth() bool {
+	switch c {
+	case ErrOAAuthTokenExpired, ErrAccountNotAuthorized, ErrRetNoSuchLogin, ErrAlreadyLoggedIn, ErrRetAccountDisabled,
+		ErrCHClientAuthFailure, ErrCHClientNotAuthenticated, ErrCHClientAlreadyAuthenticated, ErrCHAccessTokenInvalid,
+		ErrCHCTIDTraderAccountNotFound, ErrCHOAClientNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// Maintenance reports if the error is caused by a server maintenance.
+func (c ErrorCode) Maintenance() bool {
+	return c == ErrServerIsUnderMaintenance
+}
+
+// RateLimited reports if the error is caused by the server rate limit.
+func (c ErrorCode) RateLimited() bool {
+	return c == ErrRequestFrequencyExceeded || c == ErrBlockedPayloadType
+}
+
+// ErrorCodeOf returns the error code found at the error chain.
+func ErrorCodeOf(err error) (ErrorCode, bool) {
+	var code ErrorCode
+	if errors.As(err, &code) {
+		return code, true
+	}
+	return "", false
+}
+
+// IsRetryable reports if the error has a code that may succeed if the request is sent again later.
+func IsRetryable(err error) bool {
+	code, ok := ErrorCodeOf(err)
+	return ok && code.Retryable()
+}
+
+// IsAuthError reports if the error has a code related to the application or account authorization.
+func IsAuthError(err error) bool {
+	code, ok := ErrorCodeOf(err)
+	return ok && code.Auth()
+}
+
+// IsMaintenance reports if the error is caused by a server maintenance.
+func IsMaintenance(err error) bool {
+	var errOA ProtoOAError
+	if errors.As(err, &errOA) && errOA.MaintenanceEndTimestamp > 0 {
+		return true
+	}
+	var errProto ProtoError
+	if errors.As(err, &errProto) && errProto.MaintenanceEndTimestamp > 0 {
+		return true
+	}
+	code, ok := ErrorCodeOf(err)
+	return ok && code.Maintenance()
+}
+
+// IsRateLimited reports if the error is caused by the server rate limit.
+func IsRateLimited(err error) bool {
+	code, ok := ErrorCodeOf(err)
+	return ok && code.RateLimited()
+}
+
+// ProtoOAError is the error returned when the server answers with 'openapi.ProtoOAErrorRes'.
+type ProtoOAError struct {
+	ErrorCode           ErrorCode
+	Description         string
+	CtidTraderAccountID int64
+
+	// MaintenanceEndTimestamp is the Unix time in seconds.
+	MaintenanceEndTimestamp int64
+	RetryAfter              time.Duration
+}
+
+func (e ProtoOAError) Error() string {
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.Description)
+}
+
+func (e ProtoOAError) Unwrap() error {
+	return e.ErrorCode
+}
+
+// MaintenanceEnd returns when the current maintenance ends, or the zero time if there is no maintenance.
+func (e ProtoOAError) MaintenanceEnd() time.Time {
+	if e.MaintenanceEndTimestamp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(e.MaintenanceEndTimestamp, 0)
+}
+
+// ProtoError is the error returned when the server answers with 'openapi.ProtoErrorRes'.
+type ProtoError struct {
+	ErrorCode   ErrorCode
+	Description string
+
+	// MaintenanceEndTimestamp is the Unix time in milliseconds, unlike the one of ProtoOAError.
+	MaintenanceEndTimestamp int64
+}
+
+func (e ProtoError) Error() string {
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.Description)
+}
+
+func (e ProtoError) Unwrap() error {
+	return e.ErrorCode
+}
+
+// MaintenanceEnd returns when the current maintenance ends, or the zero time if there is no maintenance.
+func (e ProtoError) MaintenanceEnd() time.Time {
+	if e.MaintenanceEndTimestamp <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(e.MaintenanceEndTimestamp)
+}
+
+// OrderError is the error returned when the server answers a trading request with 'openapi.ProtoOAOrderErrorEvent'.
+type OrderError struct {
+	ErrorCode           ErrorCode
+	Description         string
+	CtidTraderAccountID int64
+	OrderID             int64
+	PositionID          int64
+}
+
+func (e OrderError) Error() string {
+	return fmt.Sprintf("%s: %s", e.ErrorCode, e.Description)
+}
+
+func (e OrderError) Unwrap() error {
+	return e.ErrorCode
+}
+
+func newOrderError(e *openapi.ProtoOAOrderErrorEvent) OrderError {
+	return OrderError{
+		ErrorCode:           ErrorCode(e.GetErrorCode()),
+		Description:         e.GetDescription(),
+		CtidTraderAccountID: e.GetCtidTraderAccountId(),
+		OrderID:             e.GetOrderId(),
+		PositionID:          e.GetPositionId(),
+	}
+}
@@ -0,0 +1,136 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestErrorCode(t *testing.T) {
+	t.Parallel()
+
+	// Every code of the enums should have a constant.
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	require.NoError(t, err)
+	constants := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok || fmt.Sprint(spec.Type) != "ErrorCode" {
+			return true
+		}
+		for _, value := range spec.Values {
+			if literal, ok := value.(*ast.BasicLit); ok && literal.Kind == token.STRING {
+				name, err := strconv.Unquote(literal.Value)
+				require.NoError(t, err)
+				constants[name] = true
+			}
+		}
+		return true
+	})
+	for _, names := range []map[int32]string{openapi.ProtoErrorCode_name, openapi.ProtoOAErrorCode_name} {
+		for _, name := range names {
+			require.True(t, constants[name], "missing the constant of '%s'", name)
+			require.Equal(t, name, ErrorCode(name).Error())
+		}
+	}
+	require.True(t, ErrRequestFrequencyExceeded.Retryable())
+	require.True(t, ErrRequestFrequencyExceeded.RateLimited())
+	require.False(t, ErrNotEnoughMoney.Retryable())
+	require.True(t, ErrCHAccessTokenInvalid.Auth())
+	require.False(t, ErrNotEnoughMoney.Auth())
+	require.True(t, ErrServerIsUnderMaintenance.Maintenance())
+
+	_, ok := ErrorCodeOf(errors.New("generic"))
+	require.False(t, ok)
+	require.False(t, IsRetryable(errors.New("generic")))
+}
+
+func TestCommandErrors(t *testing.T) {
+	t.Parallel()
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+
+	var resp proto.Message
+	transport.mutex.Lock()
+	transport.respond = func(proto.Message) proto.Message { return resp }
+	transport.mutex.Unlock()
+	send := func() error {
+		_, err := Command[*openapi.ProtoOANewOrderReq, *openapi.ProtoOAExecutionEvent](
+			context.Background(), c, newTestOrder(),
+		)
+		return err
+	}
+
+	t.Run("Should convert ProtoOAErrorRes", func(t *testing.T) {
+		resp = &openapi.ProtoOAErrorRes{
+			CtidTraderAccountId:     lo.ToPtr(int64(1)),
+			ErrorCode:               lo.ToPtr(string(ErrServerIsUnderMaintenance)),
+			Description:             lo.ToPtr("maintenance"),
+			MaintenanceEndTimestamp: lo.ToPtr(int64(1700000000)),
+			RetryAfter:              lo.ToPtr(uint64(2)),
+		}
+		err := send()
+		require.ErrorIs(t, err, ErrServerIsUnderMaintenance)
+		require.True(t, IsMaintenance(err))
+		require.True(t, IsRetryable(err))
+		require.False(t, IsAuthError(err))
+
+		var errOA ProtoOAError
+		require.ErrorAs(t, err, &errOA)
+		require.Equal(t, int64(1), errOA.CtidTraderAccountID)
+		require.Equal(t, 2*time.Second, errOA.RetryAfter)
+		require.True(t, time.Unix(1700000000, 0).Equal(errOA.MaintenanceEnd()))
+		require.True(t, ProtoOAError{}.MaintenanceEnd().IsZero())
+	})
+
+	t.Run("Should convert ProtoErrorRes", func(t *testing.T) {
+		resp = &openapi.ProtoErrorRes{
+			ErrorCode:   lo.ToPtr(openapi.ProtoErrorCode_BLOCKED_PAYLOAD_TYPE.String()),
+			Description: lo.ToPtr("blocked"),
+		}
+		err := send()
+		require.ErrorIs(t, err, ErrBlockedPayloadType)
+		require.True(t, IsRateLimited(err))
+		var errProto ProtoError
+		require.ErrorAs(t, err, &errProto)
+		require.Equal(t, "BLOCKED_PAYLOAD_TYPE: blocked", errProto.Error())
+		require.True(t, errProto.MaintenanceEnd().IsZero())
+
+		resp = &openapi.ProtoErrorRes{
+			ErrorCode:               lo.ToPtr(openapi.ProtoErrorCode_UNKNOWN_ERROR.String()),
+			MaintenanceEndTimestamp: lo.ToPtr(uint64(1700000000123)),
+		}
+		err = send()
+		require.True(t, IsMaintenance(err))
+		require.ErrorAs(t, err, &errProto)
+		require.True(t, time.UnixMilli(1700000000123).Equal(errProto.MaintenanceEnd()))
+	})
+
+	t.Run("Should convert ProtoOAOrderErrorEvent", func(t *testing.T) {
+		resp = &openapi.ProtoOAOrderErrorEvent{
+			CtidTraderAccountId: lo.ToPtr(int64(1)),
+			ErrorCode:           lo.ToPtr(string(ErrNotEnoughMoney)),
+			OrderId:             lo.ToPtr(int64(10)),
+		}
+		err := send()
+		require.ErrorIs(t, err, ErrNotEnoughMoney)
+		var errOrder OrderError
+		require.ErrorAs(t, err, &errOrder)
+		require.Equal(t, int64(10), errOrder.OrderID)
+	})
+}
@@ -0,0 +1,136 @@
+package ctrader
+
+import (
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type eventHandler struct {
+	id uint64
+	fn func(proto.Message)
+}
+
+// eventRegistry keeps the event handlers indexed by the message full name. Handlers registered with an empty name
+// receive every event.
+type eventRegistry struct {
+	mutex    sync.RWMutex
+	sequence uint64
+	handlers map[protoreflect.FullName][]eventHandler
+}
+
+func (r *eventRegistry) add(name protoreflect.FullName, fn func(proto.Message)) (unsubscribe func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.handlers == nil {
+		r.handlers = make(map[protoreflect.FullName][]eventHandler)
+	}
+	r.sequence++
+	id := r.sequence
+	r.handlers[name] = append(r.handlers[name], eventHandler{id: id, fn: fn})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			handlers := r.handlers[name]
+			for i, handler := range handlers {
+				if handler.id == id {
+					r.handlers[name] = append(handlers[:i:i], handlers[i+1:]...)
+					break
+				}
+			}
+			if len(r.handlers[name]) == 0 {
+				delete(r.handlers, name)
+			}
+		})
+	}
+}
+
+func (r *eventRegistry) dispatch(m proto.Message) {
+	r.mutex.RLock()
+	handlers := r.handlers[m.ProtoReflect().Descriptor().FullName()]
+	handlersAll := r.handlers[""]
+	r.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler.fn(m)
+	}
+	for _, handler := range handlersAll {
+		handler.fn(m)
+	}
+}
+
+// On registers a function to be called for every event of type T, like '*openapi.ProtoOASpotEvent'. Events sent as a
+// response to a request, like the '*openapi.ProtoOAExecutionEvent' of a new order, are delivered as well. Using
+// 'proto.Message' as T receives every event.
+//
+// The functions are called synchronously from the goroutine that reads the connection, they should not block. Any
+// number of functions can be registered for the same type, the returned function removes the registration.
+func On[T proto.Message](c *Client, fn func(T)) (unsubscribe func()) {
+	return c.events.add(eventName[T](), func(m proto.Message) {
+		if v, ok := m.(T); ok {
+			fn(v)
+		}
+	})
+}
+
+// Events is the channel based version of On. The channel has the given buffer size and events are dropped, with a
+// warning, when it's full. The returned function removes the registration and closes the channel.
+func Events[T proto.Message](c *Client, size int) (<-chan T, func()) {
+	return eventChannel(c, size, func(fn func(T)) func() { return On(c, fn) })
+}
+
+func eventChannel[T proto.Message](c *Client, size int, register func(func(T)) func()) (<-chan T, func()) {
+	var (
+		ch     = make(chan T, size)
+		mutex  sync.Mutex
+		closed bool
+	)
+	unsubscribeHandler := register(func(v T) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- v:
+		default:
+			c.Logger.Warn("event dropped because the channel is full", "type", eventName[T]())
+		}
+	})
+	unsubscribe := func() {
+		unsubscribeHandler()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+func eventName[T proto.Message]() protoreflect.FullName {
+	var zero T
+	if any(zero) == nil {
+		return ""
+	}
+	return zero.ProtoReflect().Descriptor().FullName()
+}
+
+// dispatchEvent delivers a message received from the server to the handlers.
+func (c *Client) dispatchEvent(m proto.Message) {
+	c.events.dispatch(m)
+	if c.HandlerEvent != nil {
+		c.HandlerEvent(m)
+	}
+}
+
+// isEvent checks if the message is an event, like '*openapi.ProtoOAExecutionEvent', instead of a response.
+func isEvent(m proto.Message) bool {
+	return strings.HasSuffix(string(m.ProtoReflect().Descriptor().Name()), "Event")
+}
@@ -0,0 +1,81 @@
+package ctrader
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestOn(t *testing.T) {
+	t.Parallel()
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+
+	var spotsA, spotsB, executions, all, legacy atomic.Int64
+	unsubscribeA := On(c, func(e *openapi.ProtoOASpotEvent) {
+		require.Equal(t, int64(10), e.GetSymbolId())
+		spotsA.Add(1)
+	})
+	On(c, func(*openapi.ProtoOASpotEvent) { spotsB.Add(1) })
+	On(c, func(*openapi.ProtoOAExecutionEvent) { executions.Add(1) })
+	On(c, func(proto.Message) { all.Add(1) })
+	c.HandlerEvent = func(proto.Message) { legacy.Add(1) }
+
+	spot := &openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: lo.ToPtr(int64(10))}
+	transport.event(spot)
+	require.Equal(t, int64(1), spotsA.Load())
+	require.Equal(t, int64(1), spotsB.Load())
+	require.Equal(t, int64(0), executions.Load())
+	require.Equal(t, int64(1), all.Load())
+	require.Equal(t, int64(1), legacy.Load())
+
+	unsubscribeA()
+	unsubscribeA()
+	transport.event(spot)
+	require.Equal(t, int64(1), spotsA.Load())
+	require.Equal(t, int64(2), spotsB.Load())
+
+	execution := &openapi.ProtoOAExecutionEvent{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		ExecutionType:       openapi.ProtoOAExecutionType_ORDER_ACCEPTED.Enum(),
+	}
+	buf, err := codecProtobuf{}.encode("unknown", fakePayloadType(execution), execution)
+	require.NoError(t, err)
+	transport.handlerMessage(buf)
+	require.Equal(t, int64(1), executions.Load())
+	require.Equal(t, int64(3), all.Load())
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+
+	events, unsubscribe := Events[*openapi.ProtoOASpotEvent](c, 1)
+	transport.event(&openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: lo.ToPtr(int64(10))})
+	transport.event(&openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: lo.ToPtr(int64(11))})
+
+	select {
+	case e := <-events:
+		require.Equal(t, int64(10), e.GetSymbolId())
+	case <-time.After(time.Second):
+		require.FailNow(t, "timeout waiting for the event")
+	}
+
+	unsubscribe()
+	unsubscribe()
+	_, ok := <-events
+	require.False(t, ok)
+}
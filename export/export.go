@@ -0,0 +1,236 @@
+// Package export writes the candles, ticks and quotes downloaded from the cTrader Open API as CSV and Apache Parquet.
+//
+// The times are written as UTC timestamps with millisecond precision and the prices as decimals with the digits of the
+// symbol. At Parquet they're INT64 columns annotated as 'TIMESTAMP(MILLIS, UTC)' and 'DECIMAL(18, digits)', which
+// pandas and Spark read as timezone aware timestamps and decimals.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/diegobernardes/ctrader"
+)
+
+// Format is the file format of the export.
+type Format string
+
+const (
+	// FormatCSV is a CSV file with a header.
+	FormatCSV Format = "csv"
+
+	// FormatParquet is an Apache Parquet file.
+	FormatParquet Format = "parquet"
+)
+
+type columnKind int
+
+const (
+	columnTimestamp columnKind = iota
+	columnDecimal
+	columnInt64
+)
+
+// column of a table. The values are the Unix time in milliseconds for the timestamps and the unscaled value for the
+// decimals.
+type column struct {
+	name  string
+	kind  columnKind
+	scale int32
+}
+
+type tableWriter interface {
+	write(row []int64) error
+	close() error
+}
+
+func newTableWriter(w io.Writer, format Format, columns []column) (tableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns), nil
+	case FormatParquet:
+		return newParquetWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", format)
+	}
+}
+
+// CandleWriter writes candles. Close must be called to finish the file.
+type CandleWriter struct {
+	table  tableWriter
+	digits int32
+	row    []int64
+}
+
+// NewCandleWriter returns a writer of candles with the columns 'time', 'open', 'high', 'low', 'close' and 'volume'.
+// The prices are written with the digits of the symbol.
+func NewCandleWriter(w io.Writer, format Format, digits int32) (*CandleWriter, error) {
+	table, err := newTableWriter(w, format, []column{
+		{name: "time", kind: columnTimestamp},
+		{name: "open", kind: columnDecimal, scale: digits},
+		{name: "high", kind: columnDecimal, scale: digits},
+		{name: "low", kind: columnDecimal, scale: digits},
+		{name: "close", kind: columnDecimal, scale: digits},
+		{name: "volume", kind: columnInt64},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CandleWriter{table: table, digits: digits, row: make([]int64, 6)}, nil
+}
+
+// Write writes a candle.
+func (w *CandleWriter) Write(c ctrader.Candle) error {
+	w.row[0] = c.Time.UnixMilli()
+	if err := scale(w.row[1:5], w.digits, c.Open, c.High, c.Low, c.Close); err != nil {
+		return err
+	}
+	w.row[5] = c.Volume
+	return w.table.write(w.row)
+}
+
+// Close finishes the file, the underlying writer is not closed.
+func (w *CandleWriter) Close() error {
+	return w.table.close()
+}
+
+// TickWriter writes ticks. Close must be called to finish the file.
+type TickWriter struct {
+	table  tableWriter
+	digits int32
+	row    []int64
+}
+
+// NewTickWriter returns a writer of ticks with the columns 'time' and 'price'.
+func NewTickWriter(w io.Writer, format Format, digits int32) (*TickWriter, error) {
+	table, err := newTableWriter(w, format, []column{
+		{name: "time", kind: columnTimestamp},
+		{name: "price", kind: columnDecimal, scale: digits},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TickWriter{table: table, digits: digits, row: make([]int64, 2)}, nil
+}
+
+// Write writes a tick.
+func (w *TickWriter) Write(t ctrader.Tick) error {
+	w.row[0] = t.Time.UnixMilli()
+	if err := scale(w.row[1:], w.digits, t.Price); err != nil {
+		return err
+	}
+	return w.table.write(w.row)
+}
+
+// Close finishes the file, the underlying writer is not closed.
+func (w *TickWriter) Close() error {
+	return w.table.close()
+}
+
+// QuoteWriter writes quotes. Close must be called to finish the file.
+type QuoteWriter struct {
+	table  tableWriter
+	digits int32
+	row    []int64
+}
+
+// NewQuoteWriter returns a writer of quotes with the columns 'time', 'bid' and 'ask'.
+func NewQuoteWriter(w io.Writer, format Format, digits int32) (*QuoteWriter, error) {
+	table, err := newTableWriter(w, format, []column{
+		{name: "time", kind: columnTimestamp},
+		{name: "bid", kind: columnDecimal, scale: digits},
+		{name: "ask", kind: columnDecimal, scale: digits},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &QuoteWriter{table: table, digits: digits, row: make([]int64, 3)}, nil
+}
+
+// Write writes a quote.
+func (w *QuoteWriter) Write(q ctrader.Quote) error {
+	w.row[0] = q.Time.UnixMilli()
+	if err := scale(w.row[1:], w.digits, q.Bid, q.Ask); err != nil {
+		return err
+	}
+	return w.table.write(w.row)
+}
+
+// Close finishes the file, the underlying writer is not closed.
+func (w *QuoteWriter) Close() error {
+	return w.table.close()
+}
+
+// scale sets the unscaled values of the prices with the digits of the symbol.
+func scale(values []int64, digits int32, prices ...ctrader.Price) error {
+	for i, price := range prices {
+		d, err := price.Decimal().Rescale(digits)
+		if err != nil {
+			return fmt.Errorf("failed to scale the price: %w", err)
+		}
+		values[i] = d.Value
+	}
+	return nil
+}
+
+// csvTimeLayout is RFC 3339 with milliseconds, which is parsed by pandas as a timezone aware timestamp.
+const csvTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []column
+	record  []string
+	header  bool
+}
+
+func newCSVWriter(w io.Writer, columns []column) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+}
+
+func (c *csvWriter) write(row []int64) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	for i, value := range row {
+		switch c.columns[i].kind {
+		case columnTimestamp:
+			c.record[i] = time.UnixMilli(value).UTC().Format(csvTimeLayout)
+		case columnDecimal:
+			c.record[i] = ctrader.NewDecimal(value, c.columns[i].scale).String()
+		case columnInt64:
+			c.record[i] = strconv.FormatInt(value, 10)
+		}
+	}
+	if err := c.writer.Write(c.record); err != nil {
+		return fmt.Errorf("failed to write the record: %w", err)
+	}
+	return nil
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	for i, column := range c.columns {
+		c.record[i] = column.name
+	}
+	if err := c.writer.Write(c.record); err != nil {
+		return fmt.Errorf("failed to write the header: %w", err)
+	}
+	c.header = true
+	return nil
+}
+
+func (c *csvWriter) close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader"
+)
+
+// readParquet reads the file with parquet-go and returns the metadata and the values of the columns.
+func readParquet(t *testing.T, data []byte) (*format.FileMetaData, [][]int64) {
+	t.Helper()
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var columns [][]int64
+	for _, rowGroup := range file.RowGroups() {
+		rows := rowGroup.Rows()
+		buf := make([]parquet.Row, 1024)
+		for {
+			n, err := rows.ReadRows(buf)
+			for _, row := range buf[:n] {
+				for i, value := range row {
+					if i == len(columns) {
+						columns = append(columns, nil)
+					}
+					columns[i] = append(columns[i], value.Int64())
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+		}
+		require.NoError(t, rows.Close())
+	}
+	return file.Metadata(), columns
+}
+
+func testCandles() []ctrader.Candle {
+	start := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	return []ctrader.Candle{
+		{Time: start, Period: time.Minute, Open: 15112300, High: 15115000, Low: 15110000, Close: 15112500, Volume: 10},
+		{Time: start.Add(time.Minute), Period: time.Minute, Open: 15112500, High: 15112500, Low: 15100000,
+			Close: 15100100, Volume: 3},
+	}
+}
+
+func TestCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writer, err := NewCandleWriter(&buf, FormatCSV, 3)
+	require.NoError(t, err)
+	for _, candle := range testCandles() {
+		require.NoError(t, writer.Write(candle))
+	}
+	require.NoError(t, writer.Close())
+	require.Equal(t, "time,open,high,low,close,volume\n"+
+		"2024-01-02T03:04:00.000Z,151.123,151.150,151.100,151.125,10\n"+
+		"2024-01-02T03:05:00.000Z,151.125,151.125,151.000,151.001,3\n", buf.String())
+
+	buf.Reset()
+	ticks, err := NewTickWriter(&buf, FormatCSV, 5)
+	require.NoError(t, err)
+	require.NoError(t, ticks.Write(ctrader.Tick{Time: time.UnixMilli(1704164640123), Price: 108123}))
+	require.NoError(t, ticks.Close())
+	require.Equal(t, "time,price\n2024-01-02T03:04:00.123Z,1.08123\n", buf.String())
+
+	buf.Reset()
+	quotes, err := NewQuoteWriter(&buf, FormatCSV, 5)
+	require.NoError(t, err)
+	require.NoError(t, quotes.Close())
+	require.Equal(t, "time,bid,ask\n", buf.String())
+
+	_, err = NewQuoteWriter(&buf, Format("xlsx"), 5)
+	require.EqualError(t, err, "unknown format 'xlsx'")
+}
+
+func TestParquet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should write the candles", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		writer, err := NewCandleWriter(&buf, FormatParquet, 3)
+		require.NoError(t, err)
+		candles := testCandles()
+		for _, candle := range candles {
+			require.NoError(t, writer.Write(candle))
+		}
+		require.NoError(t, writer.Close())
+
+		metadata, columns := readParquet(t, buf.Bytes())
+		require.Equal(t, int64(2), metadata.NumRows)
+		require.Len(t, metadata.Schema, 7)
+		require.Equal(t, int32(6), metadata.Schema[0].NumChildren)
+
+		timeColumn := metadata.Schema[1]
+		require.Equal(t, "time", timeColumn.Name)
+		require.Equal(t, deprecated.TimestampMillis, *timeColumn.ConvertedType)
+		require.True(t, timeColumn.LogicalType.Timestamp.IsAdjustedToUTC)
+		require.NotNil(t, timeColumn.LogicalType.Timestamp.Unit.Millis)
+
+		openColumn := metadata.Schema[2]
+		require.Equal(t, "open", openColumn.Name)
+		require.Equal(t, deprecated.Decimal, *openColumn.ConvertedType)
+		require.Equal(t, int32(3), *openColumn.Scale)
+		require.Equal(t, int32(18), *openColumn.Precision)
+		require.Equal(t, &format.DecimalType{Scale: 3, Precision: 18}, openColumn.LogicalType.Decimal)
+
+		require.Len(t, metadata.RowGroups, 1)
+		statistics := metadata.RowGroups[0].Columns[3].MetaData.Statistics
+		require.Equal(t, binary.LittleEndian.AppendUint64(nil, 151000), statistics.MinValue)
+		require.Equal(t, binary.LittleEndian.AppendUint64(nil, 151100), statistics.MaxValue)
+
+		require.Equal(t, [][]int64{
+			{candles[0].Time.UnixMilli(), candles[1].Time.UnixMilli()},
+			{151123, 151125},
+			{151150, 151125},
+			{151100, 151000},
+			{151125, 151001},
+			{10, 3},
+		}, columns)
+	})
+
+	t.Run("Should split the row groups", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		writer, err := NewTickWriter(&buf, FormatParquet, 5)
+		require.NoError(t, err)
+		count := parquetRowGroupSize + 10
+		for i := 0; i < count; i++ {
+			require.NoError(t, writer.Write(ctrader.Tick{Time: time.UnixMilli(int64(i)), Price: ctrader.Price(i)}))
+		}
+		require.NoError(t, writer.Close())
+
+		metadata, columns := readParquet(t, buf.Bytes())
+		require.Equal(t, int64(count), metadata.NumRows)
+		require.Len(t, metadata.RowGroups, 2)
+		statistics := metadata.RowGroups[1].Columns[0].MetaData.Statistics
+		require.Equal(t, uint64(count-10), binary.LittleEndian.Uint64(statistics.MinValue))
+		require.Equal(t, uint64(count-1), binary.LittleEndian.Uint64(statistics.MaxValue))
+		require.Len(t, columns[0], count)
+		require.Equal(t, int64(count-1), columns[1][count-1])
+	})
+
+	t.Run("Should write an empty file", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		writer, err := NewQuoteWriter(&buf, FormatParquet, 5)
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		metadata, columns := readParquet(t, buf.Bytes())
+		require.Equal(t, int64(0), metadata.NumRows)
+		require.Empty(t, columns)
+	})
+	t.Run("Should reject the invalid digits", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewTickWriter(io.Discard, FormatParquet, -1)
+		require.EqualError(t, err, "invalid scale -1 of the column 'price'")
+	})
+}
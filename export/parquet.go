@@ -0,0 +1,78 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize is the number of rows buffered before they're written as a row group.
+const parquetRowGroupSize = 1 << 17
+
+// parquetDecimalPrecision is the precision of the decimals, the digits of an int64.
+const parquetDecimalPrecision = 18
+
+// parquetWriter writes a Parquet file with required INT64 columns.
+type parquetWriter struct {
+	writer *parquet.Writer
+	row    parquet.Row
+}
+
+func newParquetWriter(w io.Writer, columns []column) (*parquetWriter, error) {
+	schema, err := parquetSchema(columns)
+	if err != nil {
+		return nil, err
+	}
+	writer := parquet.NewWriter(
+		w,
+		schema,
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.CreatedBy("github.com/diegobernardes/ctrader", "", ""),
+	)
+	return &parquetWriter{writer: writer, row: make(parquet.Row, len(columns))}, nil
+}
+
+// parquetSchema returns the schema of the columns. The schema is built from a struct, as it's the way parquet-go keeps
+// the order of the columns.
+func parquetSchema(columns []column) (*parquet.Schema, error) {
+	fields := make([]reflect.StructField, len(columns))
+	for i, c := range columns {
+		tag := c.name
+		switch c.kind {
+		case columnTimestamp:
+			tag += ",timestamp(millisecond)"
+		case columnDecimal:
+			if c.scale < 0 || c.scale > parquetDecimalPrecision {
+				return nil, fmt.Errorf("invalid scale %d of the column '%s'", c.scale, c.name)
+			}
+			tag += fmt.Sprintf(",decimal(%d:%d)", c.scale, parquetDecimalPrecision)
+		case columnInt64:
+		}
+		fields[i] = reflect.StructField{
+			Name: "Column" + strconv.Itoa(i),
+			Type: reflect.TypeOf(int64(0)),
+			Tag:  reflect.StructTag(`parquet:"` + tag + `"`),
+		}
+	}
+	return parquet.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface()), nil
+}
+
+func (p *parquetWriter) write(row []int64) error {
+	for i, value := range row {
+		p.row[i] = parquet.Int64Value(value).Level(0, 0, i)
+	}
+	if _, err := p.writer.WriteRows([]parquet.Row{p.row}); err != nil {
+		return fmt.Errorf("failed to write the row: %w", err)
+	}
+	return nil
+}
+
+func (p *parquetWriter) close() error {
+	if err := p.writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish the file: %w", err)
+	}
+	return nil
+}
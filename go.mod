@@ -3,17 +3,27 @@ module github.com/diegobernardes/ctrader
 go 1.22
 
 require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/parquet-go/parquet-go v0.24.0
 	github.com/samber/lo v1.45.0
 	github.com/satori/uuid v1.2.0
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/exp v0.0.0-20240318143956-a85f2c67cd81
-	google.golang.org/protobuf v1.34.1
+	google.golang.org/protobuf v1.34.2
 )
 
 require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/mattn/go-runewidth v0.0.15 // indirect
+	github.com/olekukonko/tablewriter v0.0.5 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,116 @@
+// Command mapping generates the functions that map the cTrader Open API payload types to the protobuf messages. The
+// payload type of each message is read from the default value of its 'payloadType' field.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+type message struct {
+	name        string
+	payloadType string
+	value       protoreflect.EnumNumber
+}
+
+func main() {
+	output := flag.String("output", "mapping_gen.go", "path of the generated file")
+	flag.Parse()
+
+	messages, err := load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	payload, err := generate(messages)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, payload, 0o600); err != nil {
+		log.Fatal(fmt.Errorf("failed to write the file: %w", err))
+	}
+}
+
+func load() ([]message, error) {
+	files := []protoreflect.FileDescriptor{
+		openapi.File_OpenApiCommonMessages_proto,
+		openapi.File_OpenApiMessages_proto,
+	}
+	var messages []message
+	for _, file := range files {
+		for i := 0; i < file.Messages().Len(); i++ {
+			descriptor := file.Messages().Get(i)
+			field := descriptor.Fields().ByName("payloadType")
+			if field == nil || !field.HasDefault() || field.Kind() != protoreflect.EnumKind {
+				continue
+			}
+			value := field.DefaultEnumValue()
+			messages = append(messages, message{
+				name:        string(descriptor.Name()),
+				payloadType: fmt.Sprintf("%s_%s", value.Parent().Name(), value.Name()),
+				value:       value.Number(),
+			})
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].value < messages[j].value })
+	for i := 1; i < len(messages); i++ {
+		if messages[i].value == messages[i-1].value {
+			return nil, fmt.Errorf(
+				"messages '%s' and '%s' have the same payload type", messages[i-1].name, messages[i].name,
+			)
+		}
+	}
+	return messages, nil
+}
+
+func generate(messages []message) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by internal/cmd/mapping. DO NOT EDIT.\n\n")
+	buf.WriteString("package ctrader\n\n")
+	buf.WriteString("import (\n\"reflect\"\n\n\"google.golang.org/protobuf/proto\"\n\n")
+	buf.WriteString("\"github.com/diegobernardes/ctrader/openapi\"\n)\n\n")
+
+	buf.WriteString("func mappingResponse(payloadType uint32) (proto.Message, error) {\n")
+	buf.WriteString("var response proto.Message\nswitch payloadType {\n")
+	for _, m := range messages {
+		if isRequest(m) {
+			continue
+		}
+		fmt.Fprintf(&buf, "case uint32(openapi.%s):\nresponse = &openapi.%s{}\n", m.payloadType, m.name)
+	}
+	buf.WriteString("default:\nreturn nil, undefinedProtobufResourceError[uint32]{Value: payloadType}\n}\n")
+	buf.WriteString("return response, nil\n}\n\n")
+
+	buf.WriteString("func mappingPayloadType(t proto.Message) (openapi.ProtoOAPayloadType, error) {\n")
+	buf.WriteString("switch t.(type) {\n")
+	for _, m := range messages {
+		if !isRequest(m) {
+			continue
+		}
+		if !strings.HasPrefix(m.payloadType, "ProtoOAPayloadType_") {
+			return nil, fmt.Errorf("request '%s' has an unexpected payload type '%s'", m.name, m.payloadType)
+		}
+		fmt.Fprintf(&buf, "case *openapi.%s:\nreturn openapi.%s, nil\n", m.name, m.payloadType)
+	}
+	buf.WriteString("default:\n")
+	buf.WriteString("return 0, undefinedProtobufResourceError[string]{Value: reflect.TypeOf(t).String()}\n}\n}\n")
+
+	payload, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the source: %w", err)
+	}
+	return payload, nil
+}
+
+func isRequest(m message) bool {
+	return strings.HasSuffix(m.name, "Req")
+}
@@ -0,0 +1,73 @@
+package ctrader
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestMapping(t *testing.T) {
+	t.Parallel()
+
+	enums := []protoreflect.EnumDescriptor{
+		openapi.ProtoPayloadType(0).Descriptor(),
+		openapi.ProtoOAPayloadType(0).Descriptor(),
+	}
+	for _, enum := range enums {
+		for i := 0; i < enum.Values().Len(); i++ {
+			value := enum.Values().Get(i)
+			// PROTO_MESSAGE is the envelope of all the other messages, it's never used as a payload.
+			if value.Number() == protoreflect.EnumNumber(openapi.ProtoPayloadType_PROTO_MESSAGE) {
+				continue
+			}
+			t.Run(fmt.Sprintf("Should round-trip %s", value.Name()), func(t *testing.T) {
+				t.Parallel()
+				payloadType := uint32(value.Number())
+				message := mappingMessageByPayloadType(t, payloadType)
+
+				if strings.HasSuffix(string(value.Name()), "_REQ") {
+					resp, err := mappingPayloadType(message.Interface())
+					require.NoError(t, err)
+					require.Equal(t, payloadType, uint32(resp))
+					return
+				}
+				resp, err := mappingResponse(payloadType)
+				require.NoError(t, err)
+				require.Equal(t, message.Descriptor().FullName(), resp.ProtoReflect().Descriptor().FullName())
+				require.Equal(t, payloadType, fakePayloadType(resp))
+			})
+		}
+	}
+
+	t.Run("Should return an error for unknown payload types", func(t *testing.T) {
+		t.Parallel()
+		_, err := mappingResponse(1)
+		require.EqualError(t, err, "undefined payload type '1'")
+		_, err = mappingPayloadType(&openapi.ProtoOAOrder{})
+		require.EqualError(t, err, "undefined request type '*openapi.ProtoOAOrder'")
+	})
+}
+
+// mappingMessageByPayloadType finds the message that has the payload type as the default of the 'payloadType' field.
+func mappingMessageByPayloadType(t *testing.T, payloadType uint32) protoreflect.Message {
+	t.Helper()
+	var result []protoreflect.MessageType
+	protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
+		if !strings.HasPrefix(mt.Descriptor().ParentFile().Path(), "OpenApi") {
+			return true
+		}
+		field := mt.Descriptor().Fields().ByName("payloadType")
+		if field != nil && field.HasDefault() && uint32(field.Default().Enum()) == payloadType {
+			result = append(result, mt)
+		}
+		return true
+	})
+	require.Len(t, result, 1, "expected a single message with the payload type '%d'", payloadType)
+	return result[0].New()
+}
@@ -0,0 +1,302 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// PriceLevel is the aggregated size of the quotes at a price.
+type PriceLevel struct {
+	Price Price
+	Size  Volume
+}
+
+// OrderBookSnapshot is a copy of the order book. The bids are sorted from the highest to the lowest price and the
+// asks from the lowest to the highest, so the first level of each side is the best one.
+type OrderBookSnapshot struct {
+	SymbolID int64
+	Bids     []PriceLevel
+	Asks     []PriceLevel
+}
+
+// BestBid returns the level with the highest bid.
+func (s OrderBookSnapshot) BestBid() (PriceLevel, bool) {
+	if len(s.Bids) == 0 {
+		return PriceLevel{}, false
+	}
+	return s.Bids[0], true
+}
+
+// BestAsk returns the level with the lowest ask.
+func (s OrderBookSnapshot) BestAsk() (PriceLevel, bool) {
+	if len(s.Asks) == 0 {
+		return PriceLevel{}, false
+	}
+	return s.Asks[0], true
+}
+
+// Spread returns the difference between the best ask and the best bid.
+func (s OrderBookSnapshot) Spread() (Price, bool) {
+	bid, okBid := s.BestBid()
+	ask, okAsk := s.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+// Mid returns the price between the best bid and the best ask.
+func (s OrderBookSnapshot) Mid() (Price, bool) {
+	bid, okBid := s.BestBid()
+	ask, okAsk := s.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return bid.Price + (ask.Price-bid.Price)/2, true
+}
+
+// WeightedMid returns the mid price weighted by the depth of the first levels of each side, zero uses every level.
+// The average price of each side is weighted by the size of the opposite side, which moves the mid towards the side
+// with less liquidity, where the price is more likely to go.
+func (s OrderBookSnapshot) WeightedMid(levels int) (Price, bool) {
+	bidPrice, bidSize := weightedAverage(s.Bids, levels)
+	askPrice, askSize := weightedAverage(s.Asks, levels)
+	if bidSize == 0 || askSize == 0 {
+		return 0, false
+	}
+	mid := (bidPrice*askSize + askPrice*bidSize) / (bidSize + askSize)
+	return Price(math.Round(mid)), true
+}
+
+// weightedAverage returns the average price weighted by the size, together with the total size, of the first levels.
+// Floats are used because the products of prices and sizes overflow int64.
+func weightedAverage(side []PriceLevel, levels int) (float64, float64) {
+	if levels > 0 && levels < len(side) {
+		side = side[:levels]
+	}
+	var total, size float64
+	for _, level := range side {
+		total += float64(level.Price) * float64(level.Size)
+		size += float64(level.Size)
+	}
+	if size == 0 {
+		return 0, 0
+	}
+	return total / size, size
+}
+
+type orderBookQuote struct {
+	price Price
+	size  Volume
+	bid   bool
+}
+
+type orderBookObserver struct {
+	id uint64
+	fn func(OrderBookSnapshot)
+}
+
+// OrderBook is the level 2 order book of a symbol. It's built from the quotes of 'ProtoOADepthEvent', which adds or
+// replaces quotes by ID and removes deleted IDs, and aggregates the quotes with the same price into a level.
+//
+// The order book can be fed manually with Apply or be kept up to date by WatchOrderBook.
+type OrderBook struct {
+	symbolID int64
+
+	mutex     sync.Mutex
+	quotes    map[uint64]orderBookQuote
+	bids      map[Price]Volume
+	asks      map[Price]Volume
+	observers []orderBookObserver
+	sequence  uint64
+
+	account     *Account
+	unsubscribe []func()
+}
+
+// NewOrderBook returns an empty order book of the symbol.
+func NewOrderBook(symbolID int64) *OrderBook {
+	return &OrderBook{
+		symbolID: symbolID,
+		quotes:   make(map[uint64]orderBookQuote),
+		bids:     make(map[Price]Volume),
+		asks:     make(map[Price]Volume),
+	}
+}
+
+// WatchOrderBook subscribes to the depth quotes of the symbol and returns an order book updated by the depth events.
+// The order book is cleared when the connection is lost, the quotes are sent again once the subscription is restored.
+// Close must be called to unsubscribe.
+func WatchOrderBook(ctx context.Context, a *Account, symbolID int64) (*OrderBook, error) {
+	book := NewOrderBook(symbolID)
+	book.account = a
+	book.unsubscribe = []func(){
+		AccountOn(a, func(e *openapi.ProtoOADepthEvent) { book.Apply(e) }),
+		a.client.OnStateChange(func(change StateChange) {
+			if change.To == StateReconnecting {
+				book.Reset()
+			}
+		}),
+	}
+	req := &openapi.ProtoOASubscribeDepthQuotesReq{SymbolId: []int64{symbolID}}
+	_, err := AccountCommand[*openapi.ProtoOASubscribeDepthQuotesReq, *openapi.ProtoOASubscribeDepthQuotesRes](
+		ctx, a, req,
+	)
+	if err != nil {
+		book.stop()
+		return nil, fmt.Errorf("failed to subscribe to the depth quotes: %w", err)
+	}
+	return book, nil
+}
+
+// Close unsubscribes from the depth quotes when the order book was created by WatchOrderBook.
+func (b *OrderBook) Close(ctx context.Context) error {
+	if b.account == nil {
+		return nil
+	}
+	b.stop()
+	req := &openapi.ProtoOAUnsubscribeDepthQuotesReq{SymbolId: []int64{b.symbolID}}
+	_, err := AccountCommand[*openapi.ProtoOAUnsubscribeDepthQuotesReq, *openapi.ProtoOAUnsubscribeDepthQuotesRes](
+		ctx, b.account, req,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from the depth quotes: %w", err)
+	}
+	return nil
+}
+
+func (b *OrderBook) stop() {
+	for _, unsubscribe := range b.unsubscribe {
+		unsubscribe()
+	}
+}
+
+// SymbolID returns the symbol of the order book.
+func (b *OrderBook) SymbolID() int64 {
+	return b.symbolID
+}
+
+// Apply updates the order book with a depth event, the events of other symbols are ignored. The deleted quotes are
+// removed before the new ones are added.
+func (b *OrderBook) Apply(e *openapi.ProtoOADepthEvent) {
+	if int64(e.GetSymbolId()) != b.symbolID {
+		return
+	}
+	b.mutex.Lock()
+	for _, id := range e.GetDeletedQuotes() {
+		b.remove(id)
+	}
+	for _, quote := range e.GetNewQuotes() {
+		b.remove(quote.GetId())
+		q := orderBookQuote{size: Volume(quote.GetSize())}
+		switch {
+		case quote.Bid != nil:
+			q.price, q.bid = Price(quote.GetBid()), true
+			b.bids[q.price] += q.size
+		case quote.Ask != nil:
+			q.price = Price(quote.GetAsk())
+			b.asks[q.price] += q.size
+		default:
+			continue
+		}
+		b.quotes[quote.GetId()] = q
+	}
+	b.notify()
+}
+
+// Reset removes every quote.
+func (b *OrderBook) Reset() {
+	b.mutex.Lock()
+	b.quotes = make(map[uint64]orderBookQuote)
+	b.bids = make(map[Price]Volume)
+	b.asks = make(map[Price]Volume)
+	b.notify()
+}
+
+// notify releases the mutex and calls the observers with a snapshot of the order book.
+func (b *OrderBook) notify() {
+	if len(b.observers) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	snapshot := b.snapshot(0)
+	observers := b.observers
+	b.mutex.Unlock()
+	for _, observer := range observers {
+		observer.fn(snapshot)
+	}
+}
+
+func (b *OrderBook) remove(id uint64) {
+	q, ok := b.quotes[id]
+	if !ok {
+		return
+	}
+	delete(b.quotes, id)
+	side := b.asks
+	if q.bid {
+		side = b.bids
+	}
+	side[q.price] -= q.size
+	if side[q.price] <= 0 {
+		delete(side, q.price)
+	}
+}
+
+// Snapshot returns a copy of the first levels of each side, zero returns every level.
+func (b *OrderBook) Snapshot(levels int) OrderBookSnapshot {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.snapshot(levels)
+}
+
+func (b *OrderBook) snapshot(levels int) OrderBookSnapshot {
+	return OrderBookSnapshot{
+		SymbolID: b.symbolID,
+		Bids:     sortedLevels(b.bids, levels, true),
+		Asks:     sortedLevels(b.asks, levels, false),
+	}
+}
+
+func sortedLevels(side map[Price]Volume, levels int, descending bool) []PriceLevel {
+	result := make([]PriceLevel, 0, len(side))
+	for price, size := range side {
+		result = append(result, PriceLevel{Price: price, Size: size})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].Price > result[j].Price
+		}
+		return result[i].Price < result[j].Price
+	})
+	if levels > 0 && levels < len(result) {
+		result = result[:levels]
+	}
+	return result
+}
+
+// Observe registers a function to be called with a snapshot after every update. The functions are called
+// synchronously, from the goroutine that reads the connection when the order book is watched, so they should not
+// block. The returned function removes the registration.
+func (b *OrderBook) Observe(fn func(OrderBookSnapshot)) (unsubscribe func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sequence++
+	id := b.sequence
+	b.observers = append(b.observers, orderBookObserver{id: id, fn: fn})
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		for i, observer := range b.observers {
+			if observer.id == id {
+				b.observers = append(b.observers[:i:i], b.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newTestDepthQuote(id uint64, size uint64, bid, ask uint64) *openapi.ProtoOADepthQuote {
+	quote := &openapi.ProtoOADepthQuote{Id: lo.ToPtr(id), Size: lo.ToPtr(size)}
+	if bid > 0 {
+		quote.Bid = lo.ToPtr(bid)
+	}
+	if ask > 0 {
+		quote.Ask = lo.ToPtr(ask)
+	}
+	return quote
+}
+
+func newTestDepthEvent(
+	symbolID uint64, quotes []*openapi.ProtoOADepthQuote, deleted ...uint64,
+) *openapi.ProtoOADepthEvent {
+	return &openapi.ProtoOADepthEvent{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		SymbolId:            lo.ToPtr(symbolID),
+		NewQuotes:           quotes,
+		DeletedQuotes:       deleted,
+	}
+}
+
+func TestOrderBook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should aggregate the quotes by price", func(t *testing.T) {
+		t.Parallel()
+		book := NewOrderBook(10)
+		book.Apply(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{
+			newTestDepthQuote(1, 100, 108120, 0),
+			newTestDepthQuote(2, 200, 108120, 0),
+			newTestDepthQuote(3, 300, 108110, 0),
+			newTestDepthQuote(4, 100, 0, 108130),
+			newTestDepthQuote(5, 400, 0, 108150),
+		}))
+		book.Apply(newTestDepthEvent(11, []*openapi.ProtoOADepthQuote{newTestDepthQuote(6, 100, 108200, 0)}))
+
+		snapshot := book.Snapshot(0)
+		require.Equal(t, []PriceLevel{{Price: 108120, Size: 300}, {Price: 108110, Size: 300}}, snapshot.Bids)
+		require.Equal(t, []PriceLevel{{Price: 108130, Size: 100}, {Price: 108150, Size: 400}}, snapshot.Asks)
+		require.Equal(t, []PriceLevel{{Price: 108120, Size: 300}}, book.Snapshot(1).Bids)
+
+		spread, ok := snapshot.Spread()
+		require.True(t, ok)
+		require.Equal(t, Price(10), spread)
+		mid, ok := snapshot.Mid()
+		require.True(t, ok)
+		require.Equal(t, Price(108125), mid)
+
+		// The best bid has more size than the best ask, so the mid moves towards the ask.
+		mid, ok = snapshot.WeightedMid(1)
+		require.True(t, ok)
+		require.Equal(t, Price(108128), mid)
+		mid, ok = snapshot.WeightedMid(0)
+		require.True(t, ok)
+		require.Equal(t, Price(108132), mid)
+	})
+
+	t.Run("Should update and delete the quotes", func(t *testing.T) {
+		t.Parallel()
+		book := NewOrderBook(10)
+		book.Apply(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{
+			newTestDepthQuote(1, 100, 108120, 0),
+			newTestDepthQuote(2, 200, 108120, 0),
+			newTestDepthQuote(3, 100, 0, 108130),
+		}))
+		book.Apply(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{
+			newTestDepthQuote(2, 500, 108115, 0),
+		}, 3))
+
+		snapshot := book.Snapshot(0)
+		require.Equal(t, []PriceLevel{{Price: 108120, Size: 100}, {Price: 108115, Size: 500}}, snapshot.Bids)
+		require.Empty(t, snapshot.Asks)
+		_, ok := snapshot.BestAsk()
+		require.False(t, ok)
+		_, ok = snapshot.Spread()
+		require.False(t, ok)
+		_, ok = snapshot.WeightedMid(0)
+		require.False(t, ok)
+
+		book.Apply(newTestDepthEvent(10, nil, 1, 2, 99))
+		require.Empty(t, book.Snapshot(0).Bids)
+	})
+
+	t.Run("Should notify the observers", func(t *testing.T) {
+		t.Parallel()
+		book := NewOrderBook(10)
+		var snapshots []OrderBookSnapshot
+		unsubscribe := book.Observe(func(s OrderBookSnapshot) { snapshots = append(snapshots, s) })
+		book.Apply(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{newTestDepthQuote(1, 100, 108120, 0)}))
+		book.Reset()
+		unsubscribe()
+		book.Apply(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{newTestDepthQuote(1, 100, 108120, 0)}))
+
+		require.Len(t, snapshots, 2)
+		bid, ok := snapshots[0].BestBid()
+		require.True(t, ok)
+		require.Equal(t, PriceLevel{Price: 108120, Size: 100}, bid)
+		require.Empty(t, snapshots[1].Bids)
+	})
+
+	t.Run("Should watch the depth events", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+
+		ctx := context.Background()
+		book, err := WatchOrderBook(ctx, c.Account(1), 10)
+		require.NoError(t, err)
+		require.Equal(t, []int64{10}, transport.sent()[1].(*openapi.ProtoOASubscribeDepthQuotesReq).GetSymbolId())
+
+		transport.event(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{newTestDepthQuote(1, 100, 0, 108130)}))
+		ask, ok := book.Snapshot(0).BestAsk()
+		require.True(t, ok)
+		require.Equal(t, Price(108130), ask.Price)
+
+		transport.drop()
+		require.Eventually(t, func() bool { return len(book.Snapshot(0).Asks) == 0 }, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return c.State() == StateReady }, time.Second, time.Millisecond)
+
+		require.NoError(t, book.Close(ctx))
+		sent := transport.sent()
+		require.Equal(t, []int64{10}, sent[len(sent)-1].(*openapi.ProtoOAUnsubscribeDepthQuotesReq).GetSymbolId())
+		transport.event(newTestDepthEvent(10, []*openapi.ProtoOADepthQuote{newTestDepthQuote(1, 100, 0, 108130)}))
+		require.Empty(t, book.Snapshot(0).Asks)
+	})
+}
@@ -0,0 +1,513 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// defaultPnLBuffer is the default size of the channels of the spot subscriptions of a PnLCalculator.
+const defaultPnLBuffer = 1024
+
+// PnLOptions configures a PnLCalculator.
+type PnLOptions struct {
+	// ReconcileInterval is the interval between the reconciliations with the unrealized P&L of the server. Zero
+	// disables the periodic reconciliation, Reconcile can still be called.
+	ReconcileInterval time.Duration
+
+	// Buffer is the size of the channel of each spot subscription, 1024 by default.
+	Buffer int
+}
+
+// PositionPnL is the unrealized P&L of a position in the deposit asset.
+type PositionPnL struct {
+	PositionID int64
+	SymbolID   int64
+
+	// ClosePrice is the price the position would be closed at, the bid for buys and the ask for sells.
+	ClosePrice Price
+
+	// Gross is the P&L of the price change. Net has the swap and the commission of the position as well.
+	Gross Money
+	Net   Money
+
+	// Drift is the gross P&L of the server minus the calculated one at the last reconciliation.
+	Drift Money
+}
+
+// AccountPnL is the unrealized P&L of the account in the deposit asset.
+type AccountPnL struct {
+	Balance Money
+	Gross   Money
+	Net     Money
+
+	// Equity is the balance plus the net unrealized P&L.
+	Equity Money
+
+	// Positions are ordered by ID.
+	Positions []PositionPnL
+
+	// Missing has the positions without the prices needed to calculate the P&L yet. They're not part of the totals.
+	Missing []int64
+}
+
+type pnlObserver struct {
+	id uint64
+	fn func(AccountPnL)
+}
+
+// PnLCalculator calculates the unrealized P&L and the equity of an account on every price change, instead of polling
+// 'ProtoOAGetPositionUnrealizedPnLReq'. The positions come from a Portfolio and the prices from spot subscriptions of
+// the position symbols and of the symbols that convert their quote asset to the deposit asset, found with
+// 'ProtoOASymbolsForConversionReq'. The conversions use the bid of the symbols.
+//
+// The server calculates the commission and the swap with rules that are not available to the client, so the
+// reconciliation measures the difference between the costs of the server and the calculated ones and applies it to
+// the following calculations of the position.
+type PnLCalculator struct {
+	account   *Account
+	portfolio *Portfolio
+	catalog   *SymbolCatalog
+	spots     *SpotManager
+	options   PnLOptions
+
+	unsubscribe []func()
+	cancel      context.CancelFunc
+	changed     chan struct{}
+	wg          sync.WaitGroup
+
+	// syncMutex serializes the changes of the subscriptions.
+	syncMutex     sync.Mutex
+	subscriptions map[int64]*SpotSubscription
+	closed        bool
+
+	mutex     sync.RWMutex
+	prices    map[int64]Quote
+	chains    map[int64][]*openapi.ProtoOALightSymbol
+	costs     map[int64]Money
+	drifts    map[int64]Money
+	observers []pnlObserver
+	sequence  uint64
+
+	// observeMutex keeps the observers from being called concurrently by the subscriptions.
+	observeMutex sync.Mutex
+}
+
+// NewPnLCalculator returns a calculator of the positions of the portfolio. The catalog is used to find the quote asset
+// of the symbols and the spot manager to subscribe to their prices. Close must be called to stop the updates.
+func NewPnLCalculator(
+	ctx context.Context, portfolio *Portfolio, catalog *SymbolCatalog, spots *SpotManager, options PnLOptions,
+) (*PnLCalculator, error) {
+	if options.Buffer == 0 {
+		options.Buffer = defaultPnLBuffer
+	}
+	c := &PnLCalculator{
+		account:       portfolio.account,
+		portfolio:     portfolio,
+		catalog:       catalog,
+		spots:         spots,
+		options:       options,
+		changed:       make(chan struct{}, 1),
+		subscriptions: make(map[int64]*SpotSubscription),
+		prices:        make(map[int64]Quote),
+		chains:        make(map[int64][]*openapi.ProtoOALightSymbol),
+		costs:         make(map[int64]Money),
+		drifts:        make(map[int64]Money),
+	}
+	if err := c.sync(ctx); err != nil {
+		_ = c.Close(ctx)
+		return nil, err
+	}
+
+	// The handlers are called after the ones of the portfolio, so the positions are already updated. The positions
+	// may also change by the reconciliation of the portfolio after a reconnection.
+	c.unsubscribe = []func(){
+		AccountOn(c.account, func(*openapi.ProtoOAExecutionEvent) { c.signal() }),
+		c.account.client.OnStateChange(func(change StateChange) {
+			if change.To == StateReady {
+				c.signal()
+			}
+		}),
+	}
+	loopCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go c.loop(loopCtx)
+	return c, nil
+}
+
+// Close stops the updates and closes the spot subscriptions.
+func (c *PnLCalculator) Close(ctx context.Context) error {
+	for _, unsubscribe := range c.unsubscribe {
+		unsubscribe()
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.syncMutex.Lock()
+	var errs error
+	if !c.closed {
+		c.closed = true
+		for _, subscription := range c.subscriptions {
+			if err := subscription.Close(ctx); err != nil {
+				errs = errors.Join(errs, err)
+			}
+		}
+		c.subscriptions = nil
+	}
+	c.syncMutex.Unlock()
+	c.wg.Wait()
+	if errs != nil {
+		return fmt.Errorf("failed to close the spot subscriptions: %w", errs)
+	}
+	return nil
+}
+
+// Observe registers a function to be called with the P&L of the account after every price change and
+// reconciliation. The functions are called one at a time, from the goroutines that receive the prices, so they should
+// not block. The returned function removes the registration.
+func (c *PnLCalculator) Observe(fn func(AccountPnL)) (unsubscribe func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sequence++
+	id := c.sequence
+	c.observers = append(c.observers, pnlObserver{id: id, fn: fn})
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for i, observer := range c.observers {
+			if observer.id == id {
+				c.observers = append(c.observers[:i:i], c.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Account returns the unrealized P&L of the account with the latest prices.
+func (c *PnLCalculator) Account() AccountPnL {
+	balance := c.portfolio.Balance()
+	depositAssetID := c.portfolio.DepositAssetID()
+	positions := c.portfolio.Positions()
+
+	result := AccountPnL{Balance: balance, Gross: NewMoney(0, balance.Digits), Net: NewMoney(0, balance.Digits)}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, position := range positions {
+		pnl, ok := c.position(position, depositAssetID, balance.Digits)
+		if !ok {
+			result.Missing = append(result.Missing, position.GetPositionId())
+			continue
+		}
+		result.Positions = append(result.Positions, pnl)
+		result.Gross.Value += pnl.Gross.Value
+		result.Net.Value += pnl.Net.Value
+	}
+	result.Equity = NewMoney(balance.Value+result.Net.Value, balance.Digits)
+	return result
+}
+
+// Position returns the unrealized P&L of a position. It's false when the position is not open or its prices are not
+// known yet.
+func (c *PnLCalculator) Position(id int64) (PositionPnL, bool) {
+	position, ok := c.portfolio.Position(id)
+	if !ok {
+		return PositionPnL{}, false
+	}
+	depositAssetID := c.portfolio.DepositAssetID()
+	digits := c.portfolio.Balance().Digits
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.position(position, depositAssetID, digits)
+}
+
+// Reconcile compares the calculated P&L with 'ProtoOAGetPositionUnrealizedPnLReq'. The difference of the gross P&L
+// is kept as the drift of the positions, and the difference of the costs is added to the following calculations.
+// It's done periodically when the ReconcileInterval is set.
+func (c *PnLCalculator) Reconcile(ctx context.Context) error {
+	res, err := AccountCommand[*openapi.ProtoOAGetPositionUnrealizedPnLReq, *openapi.ProtoOAGetPositionUnrealizedPnLRes](
+		ctx, c.account, &openapi.ProtoOAGetPositionUnrealizedPnLReq{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get the unrealized P&L: %w", err)
+	}
+	depositAssetID := c.portfolio.DepositAssetID()
+	digits := c.portfolio.Balance().Digits
+
+	c.mutex.Lock()
+	costs := make(map[int64]Money, len(res.GetPositionUnrealizedPnL()))
+	drifts := make(map[int64]Money, len(res.GetPositionUnrealizedPnL()))
+	for _, server := range res.GetPositionUnrealizedPnL() {
+		id := server.GetPositionId()
+		position, ok := c.portfolio.Position(id)
+		if !ok {
+			continue
+		}
+		local, ok := c.position(position, depositAssetID, digits)
+		if !ok {
+			continue
+		}
+		gross, err := NewMoney(server.GetGrossUnrealizedPnL(), res.GetMoneyDigits()).Round(digits)
+		if err != nil {
+			c.mutex.Unlock()
+			return fmt.Errorf("failed to convert the gross P&L of the position %d: %w", id, err)
+		}
+		net, err := NewMoney(server.GetNetUnrealizedPnL(), res.GetMoneyDigits()).Round(digits)
+		if err != nil {
+			c.mutex.Unlock()
+			return fmt.Errorf("failed to convert the net P&L of the position %d: %w", id, err)
+		}
+		previous, err := c.costs[id].Round(digits)
+		if err != nil {
+			c.mutex.Unlock()
+			return fmt.Errorf("failed to convert the costs of the position %d: %w", id, err)
+		}
+		costs[id] = NewMoney(previous.Value+(net.Value-gross.Value)-(local.Net.Value-local.Gross.Value), digits)
+		drifts[id] = NewMoney(gross.Value-local.Gross.Value, digits)
+	}
+	c.costs = costs
+	c.drifts = drifts
+	c.mutex.Unlock()
+	c.notify()
+	return nil
+}
+
+// position calculates the P&L of a position in the digits of the balance. The mutex should be held.
+func (c *PnLCalculator) position(
+	position *openapi.ProtoOAPosition, depositAssetID int64, digits uint32,
+) (PositionPnL, bool) {
+	tradeData := position.GetTradeData()
+	symbolID := tradeData.GetSymbolId()
+	quote := c.prices[symbolID]
+	closePrice, direction := quote.Bid, int64(1)
+	if tradeData.GetTradeSide() == openapi.ProtoOATradeSide_SELL {
+		closePrice, direction = quote.Ask, -1
+	}
+	if closePrice == 0 {
+		return PositionPnL{}, false
+	}
+	symbol, ok := c.catalog.CachedSymbol(symbolID)
+	if !ok {
+		return PositionPnL{}, false
+	}
+	rate, ok := c.rate(symbol.QuoteAsset.GetAssetId(), depositAssetID)
+	if !ok {
+		return PositionPnL{}, false
+	}
+
+	// The P&L is calculated exactly and rounded once to the digits of the balance.
+	difference := Price(direction * int64(closePrice-PriceFromFloat64(position.GetPrice())))
+	gross := new(big.Rat).Mul(difference.Decimal().rat(), Volume(tradeData.GetVolume()).Units().rat())
+	gross.Mul(gross, rate)
+	grossValue, ok := roundRat(gross.Mul(gross, NewDecimal(1, -int32(digits)).rat()))
+	if !ok {
+		return PositionPnL{}, false
+	}
+	costs, err := NewMoney(position.GetSwap()+position.GetCommission(), position.GetMoneyDigits()).Round(digits)
+	if err != nil {
+		return PositionPnL{}, false
+	}
+	reconciled, err := c.costs[position.GetPositionId()].Round(digits)
+	if err != nil {
+		return PositionPnL{}, false
+	}
+	drift, err := c.drifts[position.GetPositionId()].Round(digits)
+	if err != nil {
+		return PositionPnL{}, false
+	}
+	return PositionPnL{
+		PositionID: position.GetPositionId(),
+		SymbolID:   symbolID,
+		ClosePrice: closePrice,
+		Gross:      NewMoney(grossValue, digits),
+		Net:        NewMoney(grossValue+costs.Value+reconciled.Value, digits),
+		Drift:      NewMoney(drift.Value, digits),
+	}, true
+}
+
+// rate returns the price of one unit of the asset in the deposit asset. The mutex should be held.
+func (c *PnLCalculator) rate(assetID, depositAssetID int64) (*big.Rat, bool) {
+	rate := big.NewRat(1, 1)
+	if assetID == depositAssetID {
+		return rate, true
+	}
+	chain, ok := c.chains[assetID]
+	if !ok {
+		return nil, false
+	}
+	for _, symbol := range chain {
+		bid := c.prices[symbol.GetSymbolId()].Bid
+		if bid <= 0 {
+			return nil, false
+		}
+		switch assetID {
+		case symbol.GetBaseAssetId():
+			rate.Mul(rate, bid.Decimal().rat())
+			assetID = symbol.GetQuoteAssetId()
+		case symbol.GetQuoteAssetId():
+			rate.Quo(rate, bid.Decimal().rat())
+			assetID = symbol.GetBaseAssetId()
+		default:
+			return nil, false
+		}
+	}
+	return rate, assetID == depositAssetID
+}
+
+// sync subscribes to the symbols of the open positions and of their conversions, and unsubscribes from the ones not
+// needed anymore.
+func (c *PnLCalculator) sync(ctx context.Context) error {
+	c.syncMutex.Lock()
+	defer c.syncMutex.Unlock()
+	if c.closed {
+		return nil
+	}
+
+	var errs error
+	depositAssetID := c.portfolio.DepositAssetID()
+	needed := make(map[int64]bool)
+	for _, position := range c.portfolio.Positions() {
+		symbolID := position.GetTradeData().GetSymbolId()
+		needed[symbolID] = true
+		// Only the quote asset is needed, so the details of the symbol are not loaded.
+		symbol, ok := c.catalog.CachedSymbol(symbolID)
+		if !ok {
+			errs = errors.Join(errs, fmt.Errorf("symbol '%d': %w", symbolID, ErrSymbolNotFound))
+			continue
+		}
+		chain, err := c.chain(ctx, symbol.QuoteAsset.GetAssetId(), depositAssetID)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for _, conversion := range chain {
+			needed[conversion.GetSymbolId()] = true
+		}
+	}
+
+	for symbolID := range needed {
+		if _, ok := c.subscriptions[symbolID]; ok {
+			continue
+		}
+		subscription, err := c.spots.Subscribe(ctx, symbolID, SpotOptions{Buffer: c.options.Buffer})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to subscribe to the symbol '%d': %w", symbolID, err))
+			continue
+		}
+		c.subscriptions[symbolID] = subscription
+		c.wg.Add(1)
+		go c.receive(subscription)
+	}
+	for symbolID, subscription := range c.subscriptions {
+		if needed[symbolID] {
+			continue
+		}
+		delete(c.subscriptions, symbolID)
+		if err := subscription.Close(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to unsubscribe from the symbol '%d': %w", symbolID, err))
+		}
+	}
+	return errs
+}
+
+// chain returns the symbols that convert the asset to the deposit asset. The chains are cached as they don't change.
+func (c *PnLCalculator) chain(
+	ctx context.Context, assetID, depositAssetID int64,
+) ([]*openapi.ProtoOALightSymbol, error) {
+	if assetID == depositAssetID {
+		return nil, nil
+	}
+	c.mutex.RLock()
+	chain, ok := c.chains[assetID]
+	c.mutex.RUnlock()
+	if ok {
+		return chain, nil
+	}
+
+	res, err := AccountCommand[*openapi.ProtoOASymbolsForConversionReq, *openapi.ProtoOASymbolsForConversionRes](
+		ctx, c.account, &openapi.ProtoOASymbolsForConversionReq{
+			FirstAssetId: &assetID,
+			LastAssetId:  &depositAssetID,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the symbols to convert the asset '%d': %w", assetID, err)
+	}
+	c.mutex.Lock()
+	c.chains[assetID] = res.GetSymbol()
+	c.mutex.Unlock()
+	return res.GetSymbol(), nil
+}
+
+// receive updates the prices with the spot events of a subscription until it's closed.
+func (c *PnLCalculator) receive(subscription *SpotSubscription) {
+	defer c.wg.Done()
+	for e := range subscription.Events() {
+		c.mutex.Lock()
+		quote := c.prices[e.GetSymbolId()]
+		if e.Bid != nil {
+			quote.Bid = Price(e.GetBid())
+		}
+		if e.Ask != nil {
+			quote.Ask = Price(e.GetAsk())
+		}
+		c.prices[e.GetSymbolId()] = quote
+		c.mutex.Unlock()
+		c.notify()
+	}
+	c.mutex.Lock()
+	delete(c.prices, subscription.SymbolID())
+	c.mutex.Unlock()
+}
+
+func (c *PnLCalculator) notify() {
+	c.mutex.RLock()
+	observers := c.observers
+	c.mutex.RUnlock()
+	if len(observers) == 0 {
+		return
+	}
+	c.observeMutex.Lock()
+	defer c.observeMutex.Unlock()
+	account := c.Account()
+	for _, observer := range observers {
+		observer.fn(account)
+	}
+}
+
+func (c *PnLCalculator) signal() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (c *PnLCalculator) loop(ctx context.Context) {
+	defer c.wg.Done()
+	var reconcile <-chan time.Time
+	if c.options.ReconcileInterval > 0 {
+		ticker := time.NewTicker(c.options.ReconcileInterval)
+		defer ticker.Stop()
+		reconcile = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.changed:
+			if err := c.sync(ctx); err != nil {
+				c.account.client.Logger.Warn("failed to update the subscriptions of the P&L", "error", err.Error())
+			}
+		case <-reconcile:
+			if err := c.Reconcile(ctx); err != nil {
+				c.account.client.Logger.Warn("failed to reconcile the P&L", "error", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// newTestPnLPortfolio returns a portfolio with the position 10 of the symbol 2, which is quoted in the asset 1.
+func newTestPnLPortfolio(depositAssetID int64) *fakePortfolio {
+	position := newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1)
+	position.TradeData.SymbolId = lo.ToPtr(int64(2))
+	position.Price = lo.ToPtr(1.1)
+	position.Swap = lo.ToPtr(int64(-100))
+	position.Commission = lo.ToPtr(int64(-50))
+	position.MoneyDigits = lo.ToPtr(uint32(2))
+	f := &fakePortfolio{depositAssetID: depositAssetID}
+	f.positions.Store(&[]*openapi.ProtoOAPosition{position})
+	f.balance.Store(100000)
+	return f
+}
+
+func TestPnLCalculator(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// The symbol 3 converts the asset 1 to the asset 3.
+	directConversion := []*openapi.ProtoOALightSymbol{
+		{SymbolId: lo.ToPtr(int64(3)), BaseAssetId: lo.ToPtr(int64(3)), QuoteAssetId: lo.ToPtr(int64(1))},
+	}
+
+	// respond answers as the symbols and portfolio fakes, and with the conversion symbols and the unrealized P&L of the
+	// position 10, in thousandths.
+	respond := func(
+		portfolio *fakePortfolio, conversion []*openapi.ProtoOALightSymbol, gross, net int64,
+	) func(proto.Message) proto.Message {
+		symbols := &fakeSymbols{names: map[int64]string{2: "EURUSD", 3: "GBPUSD"}, assets: []int64{1, 2, 3}}
+		return func(req proto.Message) proto.Message {
+			switch v := req.(type) {
+			case *openapi.ProtoOAReconcileReq, *openapi.ProtoOATraderReq:
+				return portfolio.respond(req)
+			case *openapi.ProtoOASymbolsForConversionReq:
+				return &openapi.ProtoOASymbolsForConversionRes{CtidTraderAccountId: v.CtidTraderAccountId, Symbol: conversion}
+			case *openapi.ProtoOAGetPositionUnrealizedPnLReq:
+				return &openapi.ProtoOAGetPositionUnrealizedPnLRes{
+					CtidTraderAccountId: v.CtidTraderAccountId,
+					PositionUnrealizedPnL: []*openapi.ProtoOAPositionUnrealizedPnL{
+						{PositionId: lo.ToPtr(int64(10)), GrossUnrealizedPnL: lo.ToPtr(gross), NetUnrealizedPnL: lo.ToPtr(net)},
+					},
+					MoneyDigits: lo.ToPtr(uint32(3)),
+				}
+			default:
+				return symbols.respond(req)
+			}
+		}
+	}
+
+	start := func(
+		t *testing.T, handler func(proto.Message) proto.Message, options PnLOptions,
+	) (*fakeTransport, *SpotManager, *PnLCalculator) {
+		t.Helper()
+		transport := &fakeTransport{respond: handler}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		a := c.Account(1)
+		portfolio, err := NewPortfolio(ctx, a)
+		require.NoError(t, err)
+		t.Cleanup(portfolio.Close)
+		catalog, err := NewSymbolCatalog(ctx, a)
+		require.NoError(t, err)
+		t.Cleanup(catalog.Close)
+		spots := NewSpotManager(a)
+		calculator, err := NewPnLCalculator(ctx, portfolio, catalog, spots, options)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, calculator.Close(ctx)) })
+		return transport, spots, calculator
+	}
+
+	t.Run("Should calculate the P&L in the deposit asset", func(t *testing.T) {
+		t.Parallel()
+		portfolio := newTestPnLPortfolio(3)
+		transport, spots, calculator := start(t, respond(portfolio, directConversion, 0, 0), PnLOptions{})
+		require.Equal(t, 1, spots.Subscribers(2))
+		require.Equal(t, 1, spots.Subscribers(3))
+
+		transport.event(newTestSpotEvent(2, 112000, 112010))
+		require.Eventually(t, func() bool { return len(calculator.Account().Missing) == 1 }, time.Second, time.Millisecond)
+		_, ok := calculator.Position(10)
+		require.False(t, ok)
+
+		updates := make(chan AccountPnL, 10)
+		unsubscribe := calculator.Observe(func(pnl AccountPnL) {
+			select {
+			case updates <- pnl:
+			default:
+			}
+		})
+		defer unsubscribe()
+		transport.event(newTestSpotEvent(3, 125000, 125010))
+
+		// The position gains 0.02 USD for each of the 1000 units, 20 USD are 16 GBP.
+		var account AccountPnL
+		for len(account.Positions) == 0 {
+			select {
+			case account = <-updates:
+			case <-time.After(time.Second):
+				require.FailNow(t, "timeout waiting for the P&L")
+			}
+		}
+		require.Empty(t, account.Missing)
+		require.Equal(t, "16.00", account.Gross.String())
+		require.Equal(t, "14.50", account.Net.String())
+		require.Equal(t, "1014.50", account.Equity.String())
+		require.Equal(t, []PositionPnL{{
+			PositionID: 10,
+			SymbolID:   2,
+			ClosePrice: 112000,
+			Gross:      NewMoney(1600, 2),
+			Net:        NewMoney(1450, 2),
+			Drift:      NewMoney(0, 2),
+		}}, account.Positions)
+
+		// The server has one cent more of gross P&L and counts the commission of the closing as well.
+		transport.mutex.Lock()
+		transport.respond = respond(portfolio, directConversion, 16010, 14010)
+		transport.mutex.Unlock()
+		require.NoError(t, calculator.Reconcile(ctx))
+		position, ok := calculator.Position(10)
+		require.True(t, ok)
+		require.Equal(t, "0.01", position.Drift.String())
+		require.Equal(t, "16.00", position.Gross.String())
+		require.Equal(t, "14.00", position.Net.String())
+	})
+
+	t.Run("Should unsubscribe from the closed positions", func(t *testing.T) {
+		t.Parallel()
+		transport, spots, _ := start(t, respond(newTestPnLPortfolio(3), directConversion, 0, 0), PnLOptions{})
+		closed := newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED, 2)
+		closed.TradeData.SymbolId = lo.ToPtr(int64(2))
+		transport.event(&openapi.ProtoOAExecutionEvent{
+			CtidTraderAccountId: lo.ToPtr(int64(1)),
+			ExecutionType:       openapi.ProtoOAExecutionType_ORDER_FILLED.Enum(),
+			Position:            closed,
+		})
+		require.Eventually(t, func() bool {
+			return spots.Subscribers(2) == 0 && spots.Subscribers(3) == 0
+		}, time.Second, time.Millisecond)
+	})
+	t.Run("Should report the positions without prices as missing", func(t *testing.T) {
+		t.Parallel()
+		transport, _, calculator := start(t, respond(newTestPnLPortfolio(3), directConversion, 0, 0), PnLOptions{})
+		account := calculator.Account()
+		require.Equal(t, []int64{10}, account.Missing)
+		require.Empty(t, account.Positions)
+		require.Equal(t, "0.00", account.Net.String())
+		require.Equal(t, "1000.00", account.Equity.String())
+
+		// The conversion price alone is not enough.
+		transport.event(newTestSpotEvent(3, 125000, 125010))
+		require.Eventually(t, func() bool {
+			_, ok := calculator.Position(10)
+			return !ok && len(calculator.Account().Missing) == 1
+		}, time.Second, time.Millisecond)
+
+		transport.event(newTestSpotEvent(2, 112000, 112010))
+		require.Eventually(t, func() bool { return len(calculator.Account().Missing) == 0 }, time.Second, time.Millisecond)
+		require.Equal(t, "14.50", calculator.Account().Net.String())
+	})
+
+	t.Run("Should convert through many symbols", func(t *testing.T) {
+		t.Parallel()
+		// The asset 1 is converted to the asset 3 by the symbol 3, and the asset 3 to the deposit asset 5 by the
+		// symbol 4.
+		chainedConversion := []*openapi.ProtoOALightSymbol{
+			directConversion[0],
+			{SymbolId: lo.ToPtr(int64(4)), BaseAssetId: lo.ToPtr(int64(3)), QuoteAssetId: lo.ToPtr(int64(5))},
+		}
+		transport, spots, calculator := start(t, respond(newTestPnLPortfolio(5), chainedConversion, 0, 0), PnLOptions{})
+		require.Equal(t, 1, spots.Subscribers(4))
+
+		transport.event(newTestSpotEvent(2, 112000, 112010))
+		transport.event(newTestSpotEvent(3, 125000, 125010))
+		transport.event(newTestSpotEvent(4, 150000, 150010))
+
+		// The 20 USD are 16 GBP, which are 24 in the deposit asset.
+		require.Eventually(t, func() bool { return len(calculator.Account().Positions) == 1 }, time.Second, time.Millisecond)
+		position, ok := calculator.Position(10)
+		require.True(t, ok)
+		require.Equal(t, "24.00", position.Gross.String())
+		require.Equal(t, "22.50", position.Net.String())
+		require.Equal(t, "1022.50", calculator.Account().Equity.String())
+	})
+
+	t.Run("Should reconcile periodically", func(t *testing.T) {
+		t.Parallel()
+		handler := respond(newTestPnLPortfolio(3), directConversion, 16010, 14510)
+		transport, _, calculator := start(t, handler, PnLOptions{ReconcileInterval: 10 * time.Millisecond})
+		transport.event(newTestSpotEvent(2, 112000, 112010))
+		transport.event(newTestSpotEvent(3, 125000, 125010))
+
+		require.Eventually(t, func() bool {
+			position, ok := calculator.Position(10)
+			return ok && position.Drift.String() == "0.01"
+		}, time.Second, time.Millisecond)
+		position, ok := calculator.Position(10)
+		require.True(t, ok)
+		require.Equal(t, "16.00", position.Gross.String())
+		require.Equal(t, "14.50", position.Net.String())
+	})
+}
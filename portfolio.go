@@ -0,0 +1,296 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Portfolio keeps the open positions, the pending orders and the balance of an account. It starts from
+// 'ProtoOAReconcileReq' and 'ProtoOATraderReq' and is updated by the execution events. The events lost while the
+// connection was down are healed by reconciling again once the client is ready.
+//
+// The positions and orders returned are copies of the protobuf messages of the events, so they can be modified.
+type Portfolio struct {
+	account     *Account
+	unsubscribe []func()
+	wg          sync.WaitGroup
+
+	// ctx is used by the reconciliations after reconnections, it's cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// closeMutex guards the start of the reconciliations after reconnections, which don't start once it's closed.
+	closeMutex sync.Mutex
+	closed     bool
+
+	// reconcileMutex serializes the reconciliations. While one is running, the execution events are recorded to be
+	// applied again on top of the snapshot, which may be older than them.
+	reconcileMutex sync.Mutex
+
+	mutex          sync.RWMutex
+	positions      map[int64]*openapi.ProtoOAPosition
+	orders         map[int64]*openapi.ProtoOAOrder
+	balance        Money
+	balanceVersion int64
+	depositAssetID int64
+	reconciling    bool
+	pending        []*openapi.ProtoOAExecutionEvent
+}
+
+// NewPortfolio returns the portfolio of an authorized account. Close must be called to stop the updates.
+func NewPortfolio(ctx context.Context, a *Account) (*Portfolio, error) {
+	p := &Portfolio{
+		account:   a,
+		positions: make(map[int64]*openapi.ProtoOAPosition),
+		orders:    make(map[int64]*openapi.ProtoOAOrder),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	// The client goes through other states before being ready again, so the lost connection is remembered.
+	var reconnecting atomic.Bool
+	p.unsubscribe = []func(){
+		AccountOn(a, p.Apply),
+		a.client.OnStateChange(func(change StateChange) {
+			switch change.To {
+			case StateReconnecting:
+				reconnecting.Store(true)
+			case StateReady:
+				if reconnecting.Swap(false) {
+					p.startReconcile()
+				}
+			case StateDisconnected, StateConnecting, StateAppAuthorized, StateClosed:
+			}
+		}),
+	}
+	if err := p.Reconcile(ctx); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close stops the updates of the portfolio.
+func (p *Portfolio) Close() {
+	for _, unsubscribe := range p.unsubscribe {
+		unsubscribe()
+	}
+	p.closeMutex.Lock()
+	p.closed = true
+	p.closeMutex.Unlock()
+	p.cancel()
+	p.wg.Wait()
+}
+
+// Reconcile replaces the positions, orders and balance with the ones of the server. It's done automatically after
+// reconnections.
+func (p *Portfolio) Reconcile(ctx context.Context) error {
+	p.reconcileMutex.Lock()
+	defer p.reconcileMutex.Unlock()
+
+	p.mutex.Lock()
+	p.reconciling = true
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		p.reconciling = false
+		p.pending = nil
+		p.mutex.Unlock()
+	}()
+
+	reconcile, err := AccountCommand[*openapi.ProtoOAReconcileReq, *openapi.ProtoOAReconcileRes](
+		ctx, p.account, &openapi.ProtoOAReconcileReq{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile: %w", err)
+	}
+	trader, err := AccountCommand[*openapi.ProtoOATraderReq, *openapi.ProtoOATraderRes](
+		ctx, p.account, &openapi.ProtoOATraderReq{},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get the trader: %w", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.positions = make(map[int64]*openapi.ProtoOAPosition, len(reconcile.GetPosition()))
+	for _, position := range reconcile.GetPosition() {
+		p.positions[position.GetPositionId()] = position
+	}
+	p.orders = make(map[int64]*openapi.ProtoOAOrder, len(reconcile.GetOrder()))
+	for _, order := range reconcile.GetOrder() {
+		p.orders[order.GetOrderId()] = order
+	}
+	p.balanceVersion = 0
+	p.depositAssetID = trader.GetTrader().GetDepositAssetId()
+	p.setBalance(trader.GetTrader().GetBalance(), trader.GetTrader().GetMoneyDigits(),
+		trader.GetTrader().GetBalanceVersion())
+	for _, e := range p.pending {
+		p.apply(e)
+	}
+	return nil
+}
+
+// startReconcile reconciles at another goroutine, as it's called from the goroutine that reads the connection.
+func (p *Portfolio) startReconcile() {
+	p.closeMutex.Lock()
+	defer p.closeMutex.Unlock()
+	if p.closed {
+		return
+	}
+	p.wg.Add(1)
+	go p.reconcileAfterReconnect()
+}
+
+func (p *Portfolio) reconcileAfterReconnect() {
+	defer p.wg.Done()
+	if err := p.Reconcile(p.ctx); err != nil {
+		p.account.client.Logger.Warn("failed to reconcile the portfolio after the reconnection", "error", err.Error())
+	}
+}
+
+// Apply updates the portfolio with an execution event. It's called automatically for the events of the account.
+func (p *Portfolio) Apply(e *openapi.ProtoOAExecutionEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.reconciling {
+		p.pending = append(p.pending, e)
+	}
+	p.apply(e)
+}
+
+func (p *Portfolio) apply(e *openapi.ProtoOAExecutionEvent) {
+	switch e.GetExecutionType() {
+	case openapi.ProtoOAExecutionType_ORDER_REJECTED, openapi.ProtoOAExecutionType_ORDER_CANCEL_REJECTED:
+		// The rejections don't change the order or the position.
+		return
+	case openapi.ProtoOAExecutionType_DEPOSIT_WITHDRAW:
+		operation := e.GetDepositWithdraw()
+		p.setBalance(operation.GetBalance(), operation.GetMoneyDigits(), operation.GetBalanceVersion())
+		return
+	default:
+	}
+
+	if order := e.GetOrder(); order != nil {
+		p.applyOrder(order)
+	}
+	if position := e.GetPosition(); position != nil {
+		p.applyPosition(position)
+	}
+	if detail := e.GetDeal().GetClosePositionDetail(); detail != nil {
+		p.setBalance(detail.GetBalance(), detail.GetMoneyDigits(), detail.GetBalanceVersion())
+	}
+}
+
+// applyOrder keeps the order while it's pending. The market orders and the closing orders are executed right away,
+// so they're never kept.
+func (p *Portfolio) applyOrder(order *openapi.ProtoOAOrder) {
+	id := order.GetOrderId()
+	if current, ok := p.orders[id]; ok && order.GetUtcLastUpdateTimestamp() < current.GetUtcLastUpdateTimestamp() {
+		return
+	}
+	pending := order.GetOrderStatus() == openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED && !order.GetClosingOrder()
+	switch order.GetOrderType() {
+	case openapi.ProtoOAOrderType_LIMIT, openapi.ProtoOAOrderType_STOP, openapi.ProtoOAOrderType_STOP_LIMIT:
+	case openapi.ProtoOAOrderType_MARKET,
+		openapi.ProtoOAOrderType_MARKET_RANGE,
+		openapi.ProtoOAOrderType_STOP_LOSS_TAKE_PROFIT:
+		pending = false
+	}
+	if pending {
+		p.orders[id] = order
+	} else {
+		delete(p.orders, id)
+	}
+}
+
+// applyPosition keeps the position while it's open. The positions created for pending orders are empty, so they're
+// ignored until the order is filled.
+func (p *Portfolio) applyPosition(position *openapi.ProtoOAPosition) {
+	id := position.GetPositionId()
+	current, ok := p.positions[id]
+	if ok && position.GetUtcLastUpdateTimestamp() < current.GetUtcLastUpdateTimestamp() {
+		return
+	}
+	switch position.GetPositionStatus() {
+	case openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN:
+		p.positions[id] = position
+	case openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED, openapi.ProtoOAPositionStatus_POSITION_STATUS_ERROR:
+		delete(p.positions, id)
+	case openapi.ProtoOAPositionStatus_POSITION_STATUS_CREATED:
+	}
+}
+
+// setBalance changes the balance unless the version is older than the current one.
+func (p *Portfolio) setBalance(balance int64, digits uint32, version int64) {
+	if version != 0 && version < p.balanceVersion {
+		return
+	}
+	p.balance = NewMoney(balance, digits)
+	p.balanceVersion = version
+}
+
+// Positions returns the open positions ordered by ID.
+func (p *Portfolio) Positions() []*openapi.ProtoOAPosition {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	positions := make([]*openapi.ProtoOAPosition, 0, len(p.positions))
+	for _, position := range p.positions {
+		positions = append(positions, proto.Clone(position).(*openapi.ProtoOAPosition)) //nolint:forcetypeassert
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].GetPositionId() < positions[j].GetPositionId() })
+	return positions
+}
+
+// Position returns an open position.
+func (p *Portfolio) Position(id int64) (*openapi.ProtoOAPosition, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	position, ok := p.positions[id]
+	if !ok {
+		return nil, false
+	}
+	return proto.Clone(position).(*openapi.ProtoOAPosition), true //nolint:forcetypeassert
+}
+
+// Orders returns the pending orders ordered by ID.
+func (p *Portfolio) Orders() []*openapi.ProtoOAOrder {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	orders := make([]*openapi.ProtoOAOrder, 0, len(p.orders))
+	for _, order := range p.orders {
+		orders = append(orders, proto.Clone(order).(*openapi.ProtoOAOrder)) //nolint:forcetypeassert
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].GetOrderId() < orders[j].GetOrderId() })
+	return orders
+}
+
+// Order returns a pending order.
+func (p *Portfolio) Order(id int64) (*openapi.ProtoOAOrder, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	order, ok := p.orders[id]
+	if !ok {
+		return nil, false
+	}
+	return proto.Clone(order).(*openapi.ProtoOAOrder), true //nolint:forcetypeassert
+}
+
+// Balance returns the balance of the account.
+func (p *Portfolio) Balance() Money {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.balance
+}
+
+// DepositAssetID returns the asset of the balance.
+func (p *Portfolio) DepositAssetID() int64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.depositAssetID
+}
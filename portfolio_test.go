@@ -0,0 +1,278 @@
+package ctrader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newTestPosition(id int64, status openapi.ProtoOAPositionStatus, updated int64) *openapi.ProtoOAPosition {
+	return &openapi.ProtoOAPosition{
+		PositionId: lo.ToPtr(id),
+		TradeData: &openapi.ProtoOATradeData{
+			SymbolId:  lo.ToPtr(int64(1)),
+			Volume:    lo.ToPtr(int64(100000)),
+			TradeSide: openapi.ProtoOATradeSide_BUY.Enum(),
+		},
+		PositionStatus:         status.Enum(),
+		Swap:                   lo.ToPtr(int64(0)),
+		UtcLastUpdateTimestamp: lo.ToPtr(updated),
+	}
+}
+
+func newTestPendingOrder(id int64, status openapi.ProtoOAOrderStatus, updated int64) *openapi.ProtoOAOrder {
+	return &openapi.ProtoOAOrder{
+		OrderId: lo.ToPtr(id),
+		TradeData: &openapi.ProtoOATradeData{
+			SymbolId:  lo.ToPtr(int64(1)),
+			Volume:    lo.ToPtr(int64(100000)),
+			TradeSide: openapi.ProtoOATradeSide_BUY.Enum(),
+		},
+		OrderType:              openapi.ProtoOAOrderType_LIMIT.Enum(),
+		OrderStatus:            status.Enum(),
+		UtcLastUpdateTimestamp: lo.ToPtr(updated),
+	}
+}
+
+// fakePortfolio answers the reconcile and trader requests with the positions, orders and balance set by the test.
+type fakePortfolio struct {
+	positions   atomic.Pointer[[]*openapi.ProtoOAPosition]
+	balance     atomic.Int64
+	reconciles  atomic.Int64
+	onReconcile func()
+
+	// depositAssetID is the deposit asset of the trader, the asset 1 when it's not set.
+	depositAssetID int64
+}
+
+func (f *fakePortfolio) respond(req proto.Message) proto.Message {
+	switch v := req.(type) {
+	case *openapi.ProtoOAReconcileReq:
+		f.reconciles.Add(1)
+		if f.onReconcile != nil {
+			f.onReconcile()
+		}
+		res := &openapi.ProtoOAReconcileRes{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			Order: []*openapi.ProtoOAOrder{
+				newTestPendingOrder(20, openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED, 1),
+			},
+		}
+		if positions := f.positions.Load(); positions != nil {
+			res.Position = *positions
+		}
+		return res
+	case *openapi.ProtoOATraderReq:
+		depositAssetID := f.depositAssetID
+		if depositAssetID == 0 {
+			depositAssetID = 1
+		}
+		return &openapi.ProtoOATraderRes{
+			CtidTraderAccountId: v.CtidTraderAccountId,
+			Trader: &openapi.ProtoOATrader{
+				CtidTraderAccountId: v.CtidTraderAccountId,
+				Balance:             lo.ToPtr(f.balance.Load()),
+				BalanceVersion:      lo.ToPtr(int64(1)),
+				DepositAssetId:      lo.ToPtr(depositAssetID),
+				MoneyDigits:         lo.ToPtr(uint32(2)),
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+func positionIDs(p *Portfolio) []int64 {
+	return lo.Map(p.Positions(), func(p *openapi.ProtoOAPosition, _ int) int64 { return p.GetPositionId() })
+}
+
+func orderIDs(p *Portfolio) []int64 {
+	return lo.Map(p.Orders(), func(o *openapi.ProtoOAOrder, _ int) int64 { return o.GetOrderId() })
+}
+
+func TestPortfolio(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	start := func(t *testing.T, f *fakePortfolio) (*fakeTransport, *Portfolio) {
+		t.Helper()
+		transport := &fakeTransport{respond: f.respond}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		p, err := NewPortfolio(ctx, c.Account(1))
+		require.NoError(t, err)
+		t.Cleanup(p.Close)
+		return transport, p
+	}
+
+	t.Run("Should apply the execution events", func(t *testing.T) {
+		t.Parallel()
+		f := &fakePortfolio{}
+		f.positions.Store(&[]*openapi.ProtoOAPosition{
+			newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1),
+		})
+		f.balance.Store(100000)
+		transport, p := start(t, f)
+		require.Equal(t, []int64{10}, positionIDs(p))
+		require.Equal(t, []int64{20}, orderIDs(p))
+		require.Equal(t, "1000.00", p.Balance().String())
+
+		event := func(
+			executionType openapi.ProtoOAExecutionType, order *openapi.ProtoOAOrder, position *openapi.ProtoOAPosition,
+		) *openapi.ProtoOAExecutionEvent {
+			return &openapi.ProtoOAExecutionEvent{
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+				ExecutionType:       executionType.Enum(),
+				Order:               order,
+				Position:            position,
+			}
+		}
+
+		transport.event(event(
+			openapi.ProtoOAExecutionType_ORDER_ACCEPTED,
+			newTestPendingOrder(21, openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED, 2),
+			newTestPosition(11, openapi.ProtoOAPositionStatus_POSITION_STATUS_CREATED, 2),
+		))
+		require.Equal(t, []int64{20, 21}, orderIDs(p))
+		require.Equal(t, []int64{10}, positionIDs(p))
+
+		transport.event(event(
+			openapi.ProtoOAExecutionType_ORDER_FILLED,
+			newTestPendingOrder(21, openapi.ProtoOAOrderStatus_ORDER_STATUS_FILLED, 3),
+			newTestPosition(11, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 3),
+		))
+		transport.event(event(
+			openapi.ProtoOAExecutionType_ORDER_CANCELLED,
+			newTestPendingOrder(20, openapi.ProtoOAOrderStatus_ORDER_STATUS_CANCELLED, 3),
+			nil,
+		))
+		require.Equal(t, []int64{10, 11}, positionIDs(p))
+		require.Empty(t, orderIDs(p))
+
+		swap := newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 4)
+		swap.Swap = lo.ToPtr(int64(-15))
+		transport.event(event(openapi.ProtoOAExecutionType_SWAP, nil, swap))
+		transport.event(event(
+			openapi.ProtoOAExecutionType_ORDER_REJECTED,
+			nil,
+			newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED, 5),
+		))
+		position, ok := p.Position(10)
+		require.True(t, ok)
+		require.Equal(t, int64(-15), position.GetSwap())
+
+		closing := event(
+			openapi.ProtoOAExecutionType_ORDER_FILLED,
+			nil,
+			newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_CLOSED, 6),
+		)
+		closing.Deal = &openapi.ProtoOADeal{ClosePositionDetail: &openapi.ProtoOAClosePositionDetail{
+			Balance: lo.ToPtr(int64(120000)), BalanceVersion: lo.ToPtr(int64(5)), MoneyDigits: lo.ToPtr(uint32(2)),
+		}}
+		// The deal and the deposit miss required fields, so they're applied directly instead of sent by the server.
+		p.Apply(closing)
+		require.Equal(t, []int64{11}, positionIDs(p))
+		require.Equal(t, "1200.00", p.Balance().String())
+
+		deposit := func(balance, version int64) *openapi.ProtoOAExecutionEvent {
+			e := event(openapi.ProtoOAExecutionType_DEPOSIT_WITHDRAW, nil, nil)
+			e.DepositWithdraw = &openapi.ProtoOADepositWithdraw{
+				Balance: lo.ToPtr(balance), BalanceVersion: lo.ToPtr(version), MoneyDigits: lo.ToPtr(uint32(2)),
+			}
+			return e
+		}
+		p.Apply(deposit(130000, 7))
+		p.Apply(deposit(90000, 6))
+		require.Equal(t, "1300.00", p.Balance().String())
+
+		// An event older than the current state of the position is ignored.
+		transport.event(event(
+			openapi.ProtoOAExecutionType_SWAP,
+			nil,
+			newTestPosition(11, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 2),
+		))
+		position, ok = p.Position(11)
+		require.True(t, ok)
+		require.Equal(t, int64(3), position.GetUtcLastUpdateTimestamp())
+	})
+
+	t.Run("Should reconcile after the reconnection", func(t *testing.T) {
+		t.Parallel()
+		f := &fakePortfolio{}
+		transport, p := start(t, f)
+		require.Empty(t, positionIDs(p))
+
+		f.positions.Store(&[]*openapi.ProtoOAPosition{
+			newTestPosition(12, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1),
+		})
+		f.balance.Store(500)
+		transport.drop()
+		require.Eventually(t, func() bool { return len(positionIDs(p)) == 1 }, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return p.Balance().String() == "5.00" }, time.Second, time.Millisecond)
+		require.Equal(t, int64(2), f.reconciles.Load())
+	})
+
+	t.Run("Should apply the events received while reconciling", func(t *testing.T) {
+		t.Parallel()
+		var transport *fakeTransport
+		f := &fakePortfolio{}
+		f.onReconcile = func() {
+			if transport != nil {
+				transport.event(&openapi.ProtoOAExecutionEvent{
+					CtidTraderAccountId: lo.ToPtr(int64(1)),
+					ExecutionType:       openapi.ProtoOAExecutionType_ORDER_FILLED.Enum(),
+					Position:            newTestPosition(13, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 2),
+				})
+			}
+		}
+		transport, p := start(t, f)
+		require.Empty(t, positionIDs(p))
+		require.NoError(t, p.Reconcile(ctx))
+		require.Equal(t, []int64{13}, positionIDs(p))
+	})
+	t.Run("Should return copies of the positions and orders", func(t *testing.T) {
+		t.Parallel()
+		f := &fakePortfolio{}
+		f.positions.Store(&[]*openapi.ProtoOAPosition{
+			newTestPosition(10, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1),
+		})
+		_, p := start(t, f)
+
+		position, ok := p.Position(10)
+		require.True(t, ok)
+		position.Swap = lo.ToPtr(int64(100))
+		p.Positions()[0].PositionId = lo.ToPtr(int64(11))
+		order, ok := p.Order(20)
+		require.True(t, ok)
+		order.OrderStatus = openapi.ProtoOAOrderStatus_ORDER_STATUS_CANCELLED.Enum()
+		p.Orders()[0].OrderId = lo.ToPtr(int64(21))
+
+		position, ok = p.Position(10)
+		require.True(t, ok)
+		require.Equal(t, int64(0), position.GetSwap())
+		require.Equal(t, []int64{10}, positionIDs(p))
+		order, ok = p.Order(20)
+		require.True(t, ok)
+		require.Equal(t, openapi.ProtoOAOrderStatus_ORDER_STATUS_ACCEPTED, order.GetOrderStatus())
+		require.Equal(t, []int64{20}, orderIDs(p))
+	})
+
+	t.Run("Should not reconcile after being closed", func(t *testing.T) {
+		t.Parallel()
+		f := &fakePortfolio{}
+		_, p := start(t, f)
+		p.Close()
+		require.ErrorIs(t, p.ctx.Err(), context.Canceled)
+		p.startReconcile()
+		p.wg.Wait()
+		require.Equal(t, int64(1), f.reconciles.Load())
+	})
+}
@@ -0,0 +1,174 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// RequestClass groups the requests that share the same rate limit at the cTrader Open API.
+type RequestClass int
+
+const (
+	// RequestClassRegular is used by every request that is not historical.
+	RequestClassRegular RequestClass = iota
+
+	// RequestClassHistorical is used by the requests that load historical data, like trendbars and ticks.
+	RequestClassHistorical
+)
+
+// RateLimit controls how many requests per second are sent to the server. The zero value is valid and uses the limits
+// published by cTrader, exceeding them results at 'REQUEST_FREQUENCY_EXCEEDED' errors. Requests are evenly spaced
+// instead of being sent in bursts.
+type RateLimit struct {
+	// Regular is the limit of regular requests per second, defaults to 50.
+	Regular float64
+
+	// Historical is the limit of historical requests per second, defaults to 5.
+	Historical float64
+
+	// Disabled turns off the rate limit.
+	Disabled bool
+}
+
+// RateLimitStats has the metrics of a request class.
+type RateLimitStats struct {
+	// Requests is the total of requests that went through the limiter.
+	Requests int64
+
+	// Throttled is the total of requests that had to wait.
+	Throttled int64
+
+	// ThrottledTime is the total time spent waiting.
+	ThrottledTime time.Duration
+}
+
+// RateLimitStats returns the rate limit metrics of the request class.
+func (c *Client) RateLimitStats(class RequestClass) RateLimitStats {
+	bucket := c.rateLimiter.bucket(class)
+	if bucket == nil {
+		return RateLimitStats{}
+	}
+	return RateLimitStats{
+		Requests:      bucket.requests.Load(),
+		Throttled:     bucket.throttled.Load(),
+		ThrottledTime: time.Duration(bucket.throttledTime.Load()),
+	}
+}
+
+type rateLimiter struct {
+	regular    *tokenBucket
+	historical *tokenBucket
+}
+
+func newRateLimiter(config RateLimit) rateLimiter {
+	if config.Disabled {
+		return rateLimiter{}
+	}
+	regular := config.Regular
+	if regular <= 0 {
+		regular = 50
+	}
+	historical := config.Historical
+	if historical <= 0 {
+		historical = 5
+	}
+	return rateLimiter{
+		regular:    newTokenBucket(regular),
+		historical: newTokenBucket(historical),
+	}
+}
+
+func (r rateLimiter) bucket(class RequestClass) *tokenBucket {
+	if class == RequestClassHistorical {
+		return r.historical
+	}
+	return r.regular
+}
+
+// wait blocks until the request can be sent.
+func (r rateLimiter) wait(ctx context.Context, req proto.Message) error {
+	bucket := r.bucket(requestClass(req))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.wait(ctx)
+}
+
+func requestClass(req proto.Message) RequestClass {
+	switch req.(type) {
+	case *openapi.ProtoOAGetTrendbarsReq,
+		*openapi.ProtoOAGetTickDataReq,
+		*openapi.ProtoOADealListReq,
+		*openapi.ProtoOACashFlowHistoryListReq:
+		return RequestClassHistorical
+	default:
+		return RequestClassRegular
+	}
+}
+
+// tokenBucket has a capacity of a single token. The tokens can go negative, which represents the requests that are
+// already waiting for their turn.
+type tokenBucket struct {
+	mutex         sync.Mutex
+	rate          float64
+	tokens        float64
+	last          time.Time
+	requests      atomic.Int64
+	throttled     atomic.Int64
+	throttledTime atomic.Int64
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: 1}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.requests.Add(1)
+	delay := b.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	b.throttled.Add(1)
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		b.throttledTime.Add(int64(time.Since(start)))
+		return fmt.Errorf("context error while waiting for the rate limit: %w", ctx.Err())
+	case <-timer.C:
+		b.throttledTime.Add(int64(time.Since(start)))
+		return nil
+	}
+}
+
+// reserve takes a token and return how long the caller needs to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(1, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a token that was reserved but not used.
+func (b *tokenBucket) cancel() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens++
+}
@@ -0,0 +1,79 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should space the requests", func(t *testing.T) {
+		t.Parallel()
+		bucket := newTokenBucket(100)
+		start := time.Now()
+		for i := 0; i < 6; i++ {
+			require.NoError(t, bucket.wait(context.Background()))
+		}
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		require.Equal(t, int64(6), bucket.requests.Load())
+		require.Equal(t, int64(5), bucket.throttled.Load())
+		require.Positive(t, bucket.throttledTime.Load())
+	})
+
+	t.Run("Should reserve tokens ahead", func(t *testing.T) {
+		t.Parallel()
+		bucket := newTokenBucket(10)
+		now := time.Now()
+		require.Equal(t, time.Duration(0), bucket.reserve(now))
+		require.Equal(t, 100*time.Millisecond, bucket.reserve(now))
+		require.Equal(t, 200*time.Millisecond, bucket.reserve(now))
+		require.Equal(t, 150*time.Millisecond, bucket.reserve(now.Add(150*time.Millisecond)))
+		require.Equal(t, time.Duration(0), bucket.reserve(now.Add(time.Hour)))
+		require.Equal(t, 100*time.Millisecond, bucket.reserve(now.Add(time.Hour)))
+	})
+
+	t.Run("Should return the token when the context is canceled", func(t *testing.T) {
+		t.Parallel()
+		bucket := newTokenBucket(1)
+		require.NoError(t, bucket.wait(context.Background()))
+		ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer ctxCancel()
+		require.ErrorIs(t, bucket.wait(ctx), context.DeadlineExceeded)
+		require.InDelta(t, 1, bucket.reserve(bucket.last).Seconds(), 0.001)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, RequestClassHistorical, requestClass(&openapi.ProtoOAGetTrendbarsReq{}))
+	require.Equal(t, RequestClassHistorical, requestClass(&openapi.ProtoOAGetTickDataReq{}))
+	require.Equal(t, RequestClassHistorical, requestClass(&openapi.ProtoOADealListReq{}))
+	require.Equal(t, RequestClassHistorical, requestClass(&openapi.ProtoOACashFlowHistoryListReq{}))
+	require.Equal(t, RequestClassRegular, requestClass(&openapi.ProtoOASymbolsListReq{}))
+
+	limiter := newRateLimiter(RateLimit{})
+	require.InDelta(t, 50, limiter.regular.rate, 0)
+	require.InDelta(t, 5, limiter.historical.rate, 0)
+	require.NoError(t, newRateLimiter(RateLimit{Disabled: true}).wait(context.Background(), &openapi.ProtoOAVersionReq{}))
+
+	transport := &fakeTransport{}
+	c := newTestClient(transport)
+	c.RateLimit = RateLimit{Regular: 20}
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Account(1).Authorize(context.Background(), "token"))
+	}
+	stats := c.RateLimitStats(RequestClassRegular)
+	require.Equal(t, int64(4), stats.Requests)
+	require.Equal(t, int64(3), stats.Throttled)
+	require.GreaterOrEqual(t, stats.ThrottledTime, 100*time.Millisecond)
+	require.Equal(t, RateLimitStats{}, c.RateLimitStats(RequestClassHistorical))
+}
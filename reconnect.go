@@ -0,0 +1,173 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Backoff controls the delay between reconnection attempts. The zero value is valid and uses the defaults described
+// at each field.
+type Backoff struct {
+	// InitialInterval is the delay before the first attempt, defaults to 1 second.
+	InitialInterval time.Duration
+
+	// MaxInterval is the upper bound of the delay between attempts, defaults to 1 minute.
+	MaxInterval time.Duration
+
+	// Multiplier is applied to the delay after each failed attempt, defaults to 2.
+	Multiplier float64
+
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1, defaults to 0.2. A negative value
+	// disables it.
+	Jitter float64
+
+	// MaxAttempts is the number of attempts before the client gives up, zero means it never gives up.
+	MaxAttempts int
+}
+
+func (b Backoff) delay(attempt int) time.Duration {
+	initial := b.InitialInterval
+	if initial <= 0 {
+		initial = time.Second
+	}
+	maxInterval := b.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = time.Minute
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	jitter := b.Jitter
+	if jitter == 0 {
+		jitter = 0.2
+	}
+	jitter = math.Min(jitter, 1)
+
+	delay := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(maxInterval))
+	if jitter > 0 {
+		// The jitter is used to spread the reconnections, it does not need a secure source of randomness.
+		delay += delay * jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+	return time.Duration(delay)
+}
+
+// handlerError is called by the transport and the keepalive when the connection is not usable anymore. It must not
+// block because it's executed from the transport receive goroutine, the reconnection happens at the supervisor.
+func (c *Client) handlerError(err error) {
+	if c.stopSignal.Load() {
+		return
+	}
+	c.cancelRequests(err)
+
+	// Errors raised while reconnecting are handled by the reconnection itself.
+	if c.reconnecting.Load() {
+		return
+	}
+	select {
+	case c.errorSignal <- err:
+	default:
+	}
+}
+
+// supervisor waits for asynchronous errors and reconnects the client.
+func (c *Client) supervisor() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case <-c.done:
+				return
+			case err := <-c.errorSignal:
+				c.Logger.Error("Asynchronous error", "error", err.Error())
+				c.setState(StateReconnecting, err)
+				c.reconnect()
+			}
+		}
+	}()
+}
+
+func (c *Client) reconnect() {
+	c.reconnecting.Store(true)
+	for attempt := 1; ; attempt++ {
+		if c.Backoff.MaxAttempts > 0 && attempt > c.Backoff.MaxAttempts {
+			c.Logger.Error("giving up on reconnecting", "attempts", c.Backoff.MaxAttempts)
+			c.reconnecting.Store(false)
+			c.setState(StateDisconnected, errors.New("maximum reconnection attempts reached"))
+			return
+		}
+
+		timer := time.NewTimer(c.Backoff.delay(attempt))
+		select {
+		case <-c.done:
+			timer.Stop()
+			c.reconnecting.Store(false)
+			return
+		case <-timer.C:
+		}
+
+		if err := c.transport.stop(); err != nil {
+			c.Logger.Debug("failed to stop the transport", "error", err.Error())
+		}
+
+		if err := c.connect(); err != nil {
+			c.Logger.Error("failed to reconnect", "attempt", attempt, "error", err.Error())
+			c.setState(StateReconnecting, err)
+			continue
+		}
+		if err := c.restore(); err != nil {
+			c.Logger.Error("failed to restore the session", "attempt", attempt, "error", err.Error())
+			c.setState(StateReconnecting, err)
+			continue
+		}
+		c.Logger.Info("reconnected", "attempt", attempt)
+
+		// The flag is cleared before the state changes, otherwise the errors raised while the observers of the state
+		// change use the connection would be ignored.
+		c.reconnecting.Store(false)
+		c.setState(StateReady, nil)
+		return
+	}
+}
+
+// restore authorizes the accounts and subscribes to everything that was active before the connection was lost.
+// Accounts rejected by the server are removed from the session, as they would fail on every reconnection, and their
+// subscriptions are skipped.
+func (c *Client) restore() error {
+	rejected := make(map[int64]struct{})
+	for _, req := range c.session.requests() {
+		accountID := req.(interface{ GetCtidTraderAccountId() int64 }).GetCtidTraderAccountId() //nolint:forcetypeassert
+		if _, ok := rejected[accountID]; ok {
+			continue
+		}
+
+		ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := c.sendRequest(ctx, req)
+		ctxCancel()
+		if err != nil {
+			return fmt.Errorf("failed to send the request: %w", err)
+		}
+		errResp, ok := resp.(*openapi.ProtoOAErrorRes)
+		if !ok {
+			continue
+		}
+		c.Logger.Error(
+			"request rejected while restoring the session",
+			"ctidTraderAccountId", accountID,
+			"errorCode", errResp.GetErrorCode(),
+			"description", errResp.GetDescription(),
+		)
+		if _, isAuth := req.(*openapi.ProtoOAAccountAuthReq); isAuth {
+			rejected[accountID] = struct{}{}
+			c.session.forget(accountID)
+		}
+	}
+	return nil
+}
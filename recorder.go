@@ -0,0 +1,232 @@
+package ctrader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// recordingMagic identifies the recording files and the version of the format.
+const recordingMagic = "CTRREC1\n"
+
+// Direction tells if a recorded message was sent or received by the client.
+type Direction byte
+
+const (
+	// DirectionInbound is a message received from the server.
+	DirectionInbound Direction = iota + 1
+
+	// DirectionOutbound is a message sent by the client.
+	DirectionOutbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionInbound:
+		return "Inbound"
+	case DirectionOutbound:
+		return "Outbound"
+	default:
+		return fmt.Sprintf("Direction(%d)", byte(d))
+	}
+}
+
+// Record is a message seen by the client. Messages are always recorded with the protobuf encoding, even when the
+// client uses JSON.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Message   *openapi.ProtoMessage
+}
+
+// Recorder writes the messages seen by a client in a compact binary format. Each record has the direction, the time
+// as a delta from the previous record and the length-prefixed 'openapi.ProtoMessage'. The recorder is safe for
+// concurrent use, and the records can be read back with RecordReader.
+type Recorder struct {
+	mutex  sync.Mutex
+	writer *bufio.Writer
+	header bool
+	last   int64
+	buffer []byte
+}
+
+// NewRecorder returns a recorder that writes to w. Flush must be called to write the buffered records.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{writer: bufio.NewWriter(w)}
+}
+
+// Record writes the message.
+func (r *Recorder) Record(t time.Time, direction Direction, message *openapi.ProtoMessage) error {
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the message: %w", err)
+	}
+	return r.write(t, direction, payload)
+}
+
+// write stores a message that is already encoded as 'openapi.ProtoMessage'.
+func (r *Recorder) write(t time.Time, direction Direction, payload []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.header {
+		if _, err := r.writer.WriteString(recordingMagic); err != nil {
+			return fmt.Errorf("failed to write the header: %w", err)
+		}
+		r.header = true
+	}
+
+	timestamp := t.UnixNano()
+	r.buffer = append(r.buffer[:0], byte(direction))
+	r.buffer = binary.AppendVarint(r.buffer, timestamp-r.last)
+	r.buffer = binary.AppendUvarint(r.buffer, uint64(len(payload)))
+	r.last = timestamp
+	if _, err := r.writer.Write(r.buffer); err != nil {
+		return fmt.Errorf("failed to write the record: %w", err)
+	}
+	if _, err := r.writer.Write(payload); err != nil {
+		return fmt.Errorf("failed to write the record: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the buffered records.
+func (r *Recorder) Flush() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush: %w", err)
+	}
+	return nil
+}
+
+// RecordReader reads the records written by a Recorder. It's used like 'bufio.Scanner':
+//
+//	reader := ctrader.NewRecordReader(f)
+//	for reader.Next() {
+//		record := reader.Record()
+//	}
+//	if err := reader.Err(); err != nil {
+//		return err
+//	}
+type RecordReader struct {
+	reader *bufio.Reader
+	header bool
+	last   int64
+	record Record
+	err    error
+}
+
+// NewRecordReader returns a reader of the records at r.
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{reader: bufio.NewReader(r)}
+}
+
+// Next reads the next record, it returns false at the end of the records or when an error happens.
+func (r *RecordReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	raw, err := r.next()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			r.err = err
+		}
+		return false
+	}
+	var message openapi.ProtoMessage
+	if err := proto.Unmarshal(raw, &message); err != nil {
+		r.err = fmt.Errorf("failed to unmarshal the message: %w", err)
+		return false
+	}
+	r.record.Message = &message
+	return true
+}
+
+// Record returns the record read by Next.
+func (r *RecordReader) Record() Record {
+	return r.record
+}
+
+// Err returns the first error found while reading.
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// next reads the next record and returns the encoded message, io.EOF is only returned between records.
+func (r *RecordReader) next() ([]byte, error) {
+	if !r.header {
+		header := make([]byte, len(recordingMagic))
+		if _, err := io.ReadFull(r.reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read the header: %w", err)
+		}
+		if string(header) != recordingMagic {
+			return nil, errors.New("invalid recording header")
+		}
+		r.header = true
+	}
+
+	direction, err := r.reader.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read the direction: %w", err)
+	}
+	delta, err := binary.ReadVarint(r.reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the time: %w", unexpectedEOF(err))
+	}
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the length: %w", unexpectedEOF(err))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read the message: %w", unexpectedEOF(err))
+	}
+
+	r.last += delta
+	r.record = Record{Time: time.Unix(0, r.last), Direction: Direction(direction)}
+	return payload, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// record stores a message seen by the client, if a recorder is set. The payload is the message as it was sent or
+// received, it's converted to protobuf when the client uses another encoding.
+func (c *Client) record(direction Direction, payload []byte, e envelope) {
+	if c.Recorder == nil {
+		return
+	}
+	if _, ok := c.codec.(codecProtobuf); !ok {
+		if e.payload == nil {
+			c.Logger.Warn("message not recorded because it could not be decoded", "payloadType", e.payloadType)
+			return
+		}
+		var err error
+		payload, err = codecProtobuf{}.encode(e.clientMsgID, e.payloadType, e.payload)
+		if err != nil {
+			c.Logger.Error("failed to encode the message to record", "error", err.Error())
+			return
+		}
+	}
+	if err := c.Recorder.write(time.Now(), direction, payload); err != nil {
+		c.Logger.Error("failed to record the message", "error", err.Error())
+	}
+}
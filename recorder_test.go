@@ -0,0 +1,200 @@
+package ctrader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newTestRecord(t *testing.T, clientMsgID string, m proto.Message) *openapi.ProtoMessage {
+	t.Helper()
+	payload, err := proto.Marshal(m)
+	require.NoError(t, err)
+	message := &openapi.ProtoMessage{PayloadType: lo.ToPtr(fakePayloadType(m)), Payload: payload}
+	if clientMsgID != "" {
+		message.ClientMsgId = &clientMsgID
+	}
+	return message
+}
+
+func readRecords(t *testing.T, r io.Reader) []Record {
+	t.Helper()
+	var records []Record
+	reader := NewRecordReader(r)
+	for reader.Next() {
+		records = append(records, reader.Record())
+	}
+	require.NoError(t, reader.Err())
+	return records
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should read the records back", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		recorder := NewRecorder(&buf)
+		start := time.Unix(1700000000, 123)
+		messages := []*openapi.ProtoMessage{
+			newTestRecord(t, "id", &openapi.ProtoOAVersionReq{}),
+			newTestRecord(t, "id", &openapi.ProtoOAVersionRes{Version: lo.ToPtr("1")}),
+			newTestRecord(t, "", &openapi.ProtoHeartbeatEvent{}),
+		}
+		require.NoError(t, recorder.Record(start, DirectionOutbound, messages[0]))
+		require.NoError(t, recorder.Record(start.Add(time.Second), DirectionInbound, messages[1]))
+		require.NoError(t, recorder.Record(start.Add(-time.Millisecond), DirectionInbound, messages[2]))
+		require.NoError(t, recorder.Flush())
+
+		records := readRecords(t, bytes.NewReader(buf.Bytes()))
+		require.Len(t, records, 3)
+		require.True(t, start.Equal(records[0].Time))
+		require.True(t, start.Add(time.Second).Equal(records[1].Time))
+		require.True(t, start.Add(-time.Millisecond).Equal(records[2].Time))
+		require.Equal(t, DirectionOutbound, records[0].Direction)
+		require.Equal(t, DirectionInbound, records[1].Direction)
+		for i, record := range records {
+			require.True(t, proto.Equal(messages[i], record.Message))
+		}
+
+		reader := NewRecordReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+		for reader.Next() {
+		}
+		require.ErrorIs(t, reader.Err(), io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Should handle empty and invalid files", func(t *testing.T) {
+		t.Parallel()
+		require.Empty(t, readRecords(t, bytes.NewReader(nil)))
+
+		reader := NewRecordReader(bytes.NewReader([]byte("invalid header")))
+		require.False(t, reader.Next())
+		require.EqualError(t, reader.Err(), "invalid recording header")
+	})
+
+	t.Run("Should record the client messages", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		c.Recorder = NewRecorder(&buf)
+		require.NoError(t, c.Start())
+		_, err := c.NewOrder(context.Background(), newTestOrder(), WaitResponse)
+		require.NoError(t, err)
+		transport.event(&openapi.ProtoHeartbeatEvent{})
+		require.NoError(t, c.Stop())
+
+		records := readRecords(t, &buf)
+		require.Len(t, records, 5)
+		expected := []struct {
+			direction   Direction
+			payloadType openapi.ProtoOAPayloadType
+		}{
+			{DirectionOutbound, openapi.ProtoOAPayloadType_PROTO_OA_APPLICATION_AUTH_REQ},
+			{DirectionInbound, openapi.ProtoOAPayloadType_PROTO_OA_APPLICATION_AUTH_RES},
+			{DirectionOutbound, openapi.ProtoOAPayloadType_PROTO_OA_NEW_ORDER_REQ},
+			{DirectionInbound, openapi.ProtoOAPayloadType_PROTO_OA_EXECUTION_EVENT},
+			{DirectionInbound, openapi.ProtoOAPayloadType(openapi.ProtoPayloadType_HEARTBEAT_EVENT)},
+		}
+		for i, record := range records {
+			require.Equal(t, expected[i].direction, record.Direction)
+			require.Equal(t, uint32(expected[i].payloadType), record.Message.GetPayloadType())
+		}
+		require.Equal(t, records[2].Message.GetClientMsgId(), records[3].Message.GetClientMsgId())
+	})
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	start := time.Now()
+	spot := &openapi.ProtoOASpotEvent{CtidTraderAccountId: lo.ToPtr(int64(1)), SymbolId: lo.ToPtr(int64(1))}
+	execution := newTestExecution(openapi.ProtoOAExecutionType_ORDER_FILLED, 10, 5)
+	require.NoError(t, recorder.Record(start, DirectionInbound, newTestRecord(t, "", spot)))
+	require.NoError(t, recorder.Record(start, DirectionOutbound, newTestRecord(t, "id", newTestOrder())))
+	require.NoError(
+		t, recorder.Record(start.Add(200*time.Millisecond), DirectionInbound, newTestRecord(t, "id", execution)),
+	)
+	require.NoError(t, recorder.Flush())
+	recording := buf.Bytes()
+
+	replay := func(t *testing.T, speed float64) ([]proto.Message, time.Duration) {
+		t.Helper()
+		c := &Client{Logger: newTestClient(nil).Logger, Replay: &Replay{Reader: bytes.NewReader(recording), Speed: speed}}
+		var (
+			mutex  sync.Mutex
+			states []State
+			early  bool
+		)
+		finished := make(chan error, 1)
+		c.OnStateChange(func(change StateChange) {
+			mutex.Lock()
+			states = append(states, change.To)
+			mutex.Unlock()
+			if change.To == StateDisconnected {
+				finished <- change.Err
+			}
+		})
+		events := make(chan proto.Message, 10)
+		On(c, func(m proto.Message) {
+			mutex.Lock()
+			early = early || len(states) != 1
+			mutex.Unlock()
+			events <- m
+		})
+
+		now := time.Now()
+		require.NoError(t, c.Start())
+		_, err := Command[*openapi.ProtoOAVersionReq, *openapi.ProtoOAVersionRes](
+			context.Background(), c, &openapi.ProtoOAVersionReq{},
+		)
+		require.ErrorIs(t, err, ErrReplay)
+		select {
+		case err := <-finished:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "replay did not finish")
+		}
+		elapsed := time.Since(now)
+		mutex.Lock()
+		require.Equal(t, []State{StateReady, StateDisconnected}, states)
+		require.False(t, early)
+		mutex.Unlock()
+		require.NoError(t, c.Stop())
+		close(events)
+
+		var result []proto.Message
+		for event := range events {
+			result = append(result, event)
+		}
+		return result, elapsed
+	}
+
+	t.Run("Should replay the inbound messages", func(t *testing.T) {
+		t.Parallel()
+		events, elapsed := replay(t, 2)
+		require.Len(t, events, 2)
+		require.True(t, proto.Equal(spot, events[0]))
+		require.True(t, proto.Equal(execution, events[1]))
+		require.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	})
+
+	t.Run("Should replay without waiting", func(t *testing.T) {
+		t.Parallel()
+		events, elapsed := replay(t, math.Inf(1))
+		require.Len(t, events, 2)
+		require.Less(t, elapsed, 100*time.Millisecond)
+	})
+}
@@ -0,0 +1,166 @@
+package ctrader
+
+import (
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+type sessionTrendbar struct {
+	symbolID int64
+	period   openapi.ProtoOATrendbarPeriod
+}
+
+// session keeps track of the account authorizations and subscriptions accepted by the server, this way they can be
+// restored after a reconnection. Requests should only be tracked after the server has accepted them.
+type session struct {
+	mutex     sync.Mutex
+	accounts  map[int64]*openapi.ProtoOAAccountAuthReq
+	spots     map[int64]map[int64]bool
+	depth     map[int64]map[int64]struct{}
+	trendbars map[int64]map[sessionTrendbar]struct{}
+}
+
+func (s *session) track(req proto.Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.accounts == nil {
+		s.accounts = make(map[int64]*openapi.ProtoOAAccountAuthReq)
+		s.spots = make(map[int64]map[int64]bool)
+		s.depth = make(map[int64]map[int64]struct{})
+		s.trendbars = make(map[int64]map[sessionTrendbar]struct{})
+	}
+
+	switch v := req.(type) {
+	case *openapi.ProtoOAAccountAuthReq:
+		s.accounts[v.GetCtidTraderAccountId()] = proto.Clone(v).(*openapi.ProtoOAAccountAuthReq)
+	case *openapi.ProtoOAAccountLogoutReq:
+		s.forgetAccount(v.GetCtidTraderAccountId())
+	case *openapi.ProtoOASubscribeSpotsReq:
+		spots := sessionEntry(s.spots, v.GetCtidTraderAccountId())
+		for _, symbolID := range v.GetSymbolId() {
+			spots[symbolID] = v.GetSubscribeToSpotTimestamp()
+		}
+	case *openapi.ProtoOAUnsubscribeSpotsReq:
+		for _, symbolID := range v.GetSymbolId() {
+			delete(s.spots[v.GetCtidTraderAccountId()], symbolID)
+		}
+	case *openapi.ProtoOASubscribeDepthQuotesReq:
+		depth := sessionEntry(s.depth, v.GetCtidTraderAccountId())
+		for _, symbolID := range v.GetSymbolId() {
+			depth[symbolID] = struct{}{}
+		}
+	case *openapi.ProtoOAUnsubscribeDepthQuotesReq:
+		for _, symbolID := range v.GetSymbolId() {
+			delete(s.depth[v.GetCtidTraderAccountId()], symbolID)
+		}
+	case *openapi.ProtoOASubscribeLiveTrendbarReq:
+		trendbars := sessionEntry(s.trendbars, v.GetCtidTraderAccountId())
+		trendbars[sessionTrendbar{symbolID: v.GetSymbolId(), period: v.GetPeriod()}] = struct{}{}
+	case *openapi.ProtoOAUnsubscribeLiveTrendbarReq:
+		key := sessionTrendbar{symbolID: v.GetSymbolId(), period: v.GetPeriod()}
+		delete(s.trendbars[v.GetCtidTraderAccountId()], key)
+	}
+}
+
+// forget removes the accounts, and their subscriptions, from the session.
+func (s *session) forget(accountIDs ...int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, accountID := range accountIDs {
+		s.forgetAccount(accountID)
+	}
+}
+
+func (s *session) forgetAccount(accountID int64) {
+	delete(s.accounts, accountID)
+	delete(s.spots, accountID)
+	delete(s.depth, accountID)
+	delete(s.trendbars, accountID)
+}
+
+// requests return the requests required to restore the session. The account authorizations come first, followed by
+// the spot subscriptions, because live trendbars depend on them, and finally the depth and trendbar subscriptions.
+func (s *session) requests() []proto.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	accountIDs := make([]int64, 0, len(s.accounts))
+	for accountID := range s.accounts {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i] < accountIDs[j] })
+
+	var result []proto.Message
+	for _, accountID := range accountIDs {
+		result = append(result, proto.Clone(s.accounts[accountID]))
+	}
+	for _, accountID := range accountIDs {
+		result = append(result, s.requestsAccount(accountID)...)
+	}
+	return result
+}
+
+func (s *session) requestsAccount(accountID int64) []proto.Message {
+	var result []proto.Message
+	for _, timestamp := range []bool{false, true} {
+		var symbolIDs []int64
+		for symbolID, symbolTimestamp := range s.spots[accountID] {
+			if symbolTimestamp == timestamp {
+				symbolIDs = append(symbolIDs, symbolID)
+			}
+		}
+		if len(symbolIDs) == 0 {
+			continue
+		}
+		sort.Slice(symbolIDs, func(i, j int) bool { return symbolIDs[i] < symbolIDs[j] })
+		result = append(result, &openapi.ProtoOASubscribeSpotsReq{
+			CtidTraderAccountId:      &accountID,
+			SymbolId:                 symbolIDs,
+			SubscribeToSpotTimestamp: &timestamp,
+		})
+	}
+
+	if len(s.depth[accountID]) > 0 {
+		symbolIDs := make([]int64, 0, len(s.depth[accountID]))
+		for symbolID := range s.depth[accountID] {
+			symbolIDs = append(symbolIDs, symbolID)
+		}
+		sort.Slice(symbolIDs, func(i, j int) bool { return symbolIDs[i] < symbolIDs[j] })
+		result = append(result, &openapi.ProtoOASubscribeDepthQuotesReq{
+			CtidTraderAccountId: &accountID,
+			SymbolId:            symbolIDs,
+		})
+	}
+
+	trendbars := make([]sessionTrendbar, 0, len(s.trendbars[accountID]))
+	for trendbar := range s.trendbars[accountID] {
+		trendbars = append(trendbars, trendbar)
+	}
+	sort.Slice(trendbars, func(i, j int) bool {
+		if trendbars[i].symbolID == trendbars[j].symbolID {
+			return trendbars[i].period < trendbars[j].period
+		}
+		return trendbars[i].symbolID < trendbars[j].symbolID
+	})
+	for _, trendbar := range trendbars {
+		result = append(result, &openapi.ProtoOASubscribeLiveTrendbarReq{
+			CtidTraderAccountId: &accountID,
+			SymbolId:            &trendbar.symbolID,
+			Period:              &trendbar.period,
+		})
+	}
+	return result
+}
+
+func sessionEntry[K comparable, V any](entries map[int64]map[K]V, accountID int64) map[K]V {
+	entry, ok := entries[accountID]
+	if !ok {
+		entry = make(map[K]V)
+		entries[accountID] = entry
+	}
+	return entry
+}
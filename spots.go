@@ -0,0 +1,298 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// SpotOptions configures a spot subscription.
+type SpotOptions struct {
+	// Timestamp requests the 'timestamp' field at the spot events. When a subscriber asks for it, the symbol is
+	// resubscribed with it and every subscriber of the symbol receives the timestamps.
+	Timestamp bool
+
+	// Buffer is the size of the channel of events. The events are dropped, with a warning, when it's full.
+	Buffer int
+}
+
+// SpotManager shares the spot subscriptions of an account between many subscribers. The server accepts a single
+// subscription per symbol, so the manager counts the subscribers of each symbol and only sends
+// 'ProtoOASubscribeSpotsReq' for the first one and 'ProtoOAUnsubscribeSpotsReq' after the last one is gone. The spot
+// events are delivered to the channel of every subscriber of the symbol.
+type SpotManager struct {
+	account     *Account
+	unsubscribe func()
+
+	mutex   sync.Mutex
+	symbols map[int64]*spotSymbol
+	closed  bool
+}
+
+// spotSymbol has the state of a symbol. The mutex serializes the requests of the symbol, while the other fields are
+// protected by the manager mutex. The subscription state is only changed with both mutexes held, so it can be read
+// with either of them.
+type spotSymbol struct {
+	mutex       sync.Mutex
+	subscribed  bool
+	timestamp   bool
+	users       int
+	subscribers map[*SpotSubscription]struct{}
+}
+
+// SpotSubscription is a subscriber of the spot events of a symbol.
+type SpotSubscription struct {
+	manager  *SpotManager
+	symbolID int64
+	events   chan *openapi.ProtoOASpotEvent
+	closed   bool
+	err      error
+	dropped  int64
+}
+
+// NewSpotManager returns a spot manager of the account, which must be authorized before subscribing.
+func NewSpotManager(a *Account) *SpotManager {
+	m := &SpotManager{account: a, symbols: make(map[int64]*spotSymbol)}
+	m.unsubscribe = AccountOn(a, m.dispatch)
+	return m
+}
+
+// Subscribe adds a subscriber to the spot events of the symbol.
+func (m *SpotManager) Subscribe(ctx context.Context, symbolID int64, options SpotOptions) (*SpotSubscription, error) {
+	sub := &SpotSubscription{
+		manager:  m,
+		symbolID: symbolID,
+		events:   make(chan *openapi.ProtoOASpotEvent, options.Buffer),
+	}
+	symbol, err := m.acquire(symbolID)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release(symbolID, symbol)
+	symbol.mutex.Lock()
+	defer symbol.mutex.Unlock()
+
+	// The subscriber is added before the request, this way it gets the spot event that the server sends right after
+	// the subscription.
+	m.mutex.Lock()
+	symbol.subscribers[sub] = struct{}{}
+	m.mutex.Unlock()
+
+	switch {
+	case !symbol.subscribed:
+		err = m.subscribe(ctx, symbolID, symbol, options.Timestamp)
+	case options.Timestamp && !symbol.timestamp:
+		err = m.resubscribe(ctx, symbolID, symbol)
+	}
+	if err != nil {
+		m.remove(symbol, sub)
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close removes every subscriber and unsubscribes from the symbols.
+func (m *SpotManager) Close(ctx context.Context) error {
+	m.mutex.Lock()
+	m.closed = true
+	var subs []*SpotSubscription
+	for _, symbol := range m.symbols {
+		for sub := range symbol.subscribers {
+			subs = append(subs, sub)
+		}
+	}
+	m.mutex.Unlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.unsubscribe()
+	return errors.Join(errs...)
+}
+
+// Subscribers returns the number of subscribers of the symbol.
+func (m *SpotManager) Subscribers(symbolID int64) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if symbol, ok := m.symbols[symbolID]; ok {
+		return len(symbol.subscribers)
+	}
+	return 0
+}
+
+// Events returns the channel of spot events of the symbol, it's closed when the subscription is closed.
+func (s *SpotSubscription) Events() <-chan *openapi.ProtoOASpotEvent {
+	return s.events
+}
+
+// Err returns the reason the subscription was closed by the manager, which happens when the symbol is left without
+// a subscription at the server. It's nil when the subscription is open or it was closed by the subscriber.
+func (s *SpotSubscription) Err() error {
+	s.manager.mutex.Lock()
+	defer s.manager.mutex.Unlock()
+	return s.err
+}
+
+// Dropped returns the number of spot events dropped because the channel was full.
+func (s *SpotSubscription) Dropped() int64 {
+	s.manager.mutex.Lock()
+	defer s.manager.mutex.Unlock()
+	return s.dropped
+}
+
+// SymbolID returns the symbol of the subscription.
+func (s *SpotSubscription) SymbolID() int64 {
+	return s.symbolID
+}
+
+// Close removes the subscriber. The symbol is unsubscribed when this is the last subscriber. The channel is closed
+// even when the unsubscription fails.
+func (s *SpotSubscription) Close(ctx context.Context) error {
+	m := s.manager
+	m.mutex.Lock()
+	symbol, ok := m.symbols[s.symbolID]
+	if !ok || s.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	symbol.users++
+	m.mutex.Unlock()
+	defer m.release(s.symbolID, symbol)
+
+	m.remove(symbol, s)
+	symbol.mutex.Lock()
+	defer symbol.mutex.Unlock()
+	if !symbol.subscribed || m.subscribers(symbol) > 0 {
+		return nil
+	}
+	return m.unsubscribeSymbol(ctx, s.symbolID, symbol)
+}
+
+func (m *SpotManager) dispatch(e *openapi.ProtoOASpotEvent) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	symbol, ok := m.symbols[e.GetSymbolId()]
+	if !ok {
+		return
+	}
+	for sub := range symbol.subscribers {
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped++
+			m.account.client.Logger.Warn(
+				"spot event dropped because the channel is full", "symbolID", e.GetSymbolId(),
+			)
+		}
+	}
+}
+
+// acquire returns the state of the symbol, which is kept until it's released.
+func (m *SpotManager) acquire(symbolID int64) (*spotSymbol, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return nil, errors.New("spot manager is closed")
+	}
+	symbol, ok := m.symbols[symbolID]
+	if !ok {
+		symbol = &spotSymbol{subscribers: make(map[*SpotSubscription]struct{})}
+		m.symbols[symbolID] = symbol
+	}
+	symbol.users++
+	return symbol, nil
+}
+
+// release removes the state of the symbol when it's not used anymore.
+func (m *SpotManager) release(symbolID int64, symbol *spotSymbol) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	symbol.users--
+	if symbol.users == 0 && len(symbol.subscribers) == 0 && !symbol.subscribed {
+		delete(m.symbols, symbolID)
+	}
+}
+
+func (m *SpotManager) remove(symbol *spotSymbol, sub *SpotSubscription) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(symbol.subscribers, sub)
+	close(sub.events)
+}
+
+// removeAll removes every subscriber of the symbol, which get the error at Err.
+func (m *SpotManager) removeAll(symbol *spotSymbol, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for sub := range symbol.subscribers {
+		sub.closed, sub.err = true, err
+		delete(symbol.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (m *SpotManager) subscribers(symbol *spotSymbol) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(symbol.subscribers)
+}
+
+func (m *SpotManager) subscribe(ctx context.Context, symbolID int64, symbol *spotSymbol, timestamp bool) error {
+	req := &openapi.ProtoOASubscribeSpotsReq{
+		SymbolId:                 []int64{symbolID},
+		SubscribeToSpotTimestamp: proto.Bool(timestamp),
+	}
+	_, err := AccountCommand[*openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOASubscribeSpotsRes](ctx, m.account, req)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to the spots: %w", err)
+	}
+	m.mutex.Lock()
+	symbol.subscribed, symbol.timestamp = true, timestamp
+	m.mutex.Unlock()
+	return nil
+}
+
+func (m *SpotManager) unsubscribeSymbol(ctx context.Context, symbolID int64, symbol *spotSymbol) error {
+	req := &openapi.ProtoOAUnsubscribeSpotsReq{SymbolId: []int64{symbolID}}
+	_, err := AccountCommand[*openapi.ProtoOAUnsubscribeSpotsReq, *openapi.ProtoOAUnsubscribeSpotsRes](
+		ctx, m.account, req,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from the spots: %w", err)
+	}
+	m.mutex.Lock()
+	symbol.subscribed, symbol.timestamp = false, false
+	m.mutex.Unlock()
+	return nil
+}
+
+// resubscribe subscribes again to receive the timestamps. When it fails, the subscription without timestamps is
+// restored for the other subscribers, and when even that fails the subscribers are closed with the error, as they
+// won't receive events anymore.
+func (m *SpotManager) resubscribe(ctx context.Context, symbolID int64, symbol *spotSymbol) error {
+	if err := m.unsubscribeSymbol(ctx, symbolID, symbol); err != nil {
+		return err
+	}
+	err := m.subscribe(ctx, symbolID, symbol, true)
+	if err == nil {
+		return nil
+	}
+	if errRestore := m.subscribe(ctx, symbolID, symbol, false); errRestore != nil {
+		err = errors.Join(err, errRestore)
+		m.removeAll(symbol, err)
+		return err
+	}
+	return err
+}
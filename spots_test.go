@@ -0,0 +1,201 @@
+package ctrader
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// spotRequests returns the spot subscription requests sent to the server.
+func spotRequests(transport *fakeTransport) []proto.Message {
+	var requests []proto.Message
+	for _, req := range transport.sent() {
+		switch req.(type) {
+		case *openapi.ProtoOASubscribeSpotsReq, *openapi.ProtoOAUnsubscribeSpotsReq:
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+// newTestSpotEvent returns a spot event of the account 1. The ask is not set when it's zero, as the spot events only
+// have the prices that changed.
+func newTestSpotEvent(symbolID int64, bid, ask uint64) *openapi.ProtoOASpotEvent {
+	e := &openapi.ProtoOASpotEvent{
+		CtidTraderAccountId: lo.ToPtr(int64(1)),
+		SymbolId:            lo.ToPtr(symbolID),
+		Bid:                 lo.ToPtr(bid),
+	}
+	if ask != 0 {
+		e.Ask = lo.ToPtr(ask)
+	}
+	return e
+}
+
+func TestSpotManager(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Should subscribe only once per symbol", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+		m := NewSpotManager(c.Account(1))
+
+		first, err := m.Subscribe(ctx, 10, SpotOptions{Buffer: 1})
+		require.NoError(t, err)
+		second, err := m.Subscribe(ctx, 10, SpotOptions{Buffer: 1})
+		require.NoError(t, err)
+		require.Equal(t, 2, m.Subscribers(10))
+		require.Len(t, spotRequests(transport), 1)
+
+		transport.event(newTestSpotEvent(10, 108123, 0))
+		transport.event(newTestSpotEvent(20, 1, 0))
+		require.Equal(t, uint64(108123), (<-first.Events()).GetBid())
+		require.Equal(t, uint64(108123), (<-second.Events()).GetBid())
+
+		require.NoError(t, first.Close(ctx))
+		require.NoError(t, first.Close(ctx))
+		_, ok := <-first.Events()
+		require.False(t, ok)
+		require.Len(t, spotRequests(transport), 1)
+
+		require.NoError(t, second.Close(ctx))
+		requests := spotRequests(transport)
+		require.Len(t, requests, 2)
+		require.IsType(t, &openapi.ProtoOAUnsubscribeSpotsReq{}, requests[1])
+		require.Equal(t, []int64{10}, requests[1].(*openapi.ProtoOAUnsubscribeSpotsReq).GetSymbolId())
+		require.Equal(t, 0, m.Subscribers(10))
+		require.NoError(t, m.Close(ctx))
+	})
+
+	t.Run("Should resubscribe to receive the timestamps", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+		m := NewSpotManager(c.Account(1))
+
+		_, err := m.Subscribe(ctx, 10, SpotOptions{})
+		require.NoError(t, err)
+		_, err = m.Subscribe(ctx, 10, SpotOptions{Timestamp: true})
+		require.NoError(t, err)
+		_, err = m.Subscribe(ctx, 10, SpotOptions{})
+		require.NoError(t, err)
+
+		requests := spotRequests(transport)
+		require.Len(t, requests, 3)
+		require.False(t, requests[0].(*openapi.ProtoOASubscribeSpotsReq).GetSubscribeToSpotTimestamp())
+		require.IsType(t, &openapi.ProtoOAUnsubscribeSpotsReq{}, requests[1])
+		require.True(t, requests[2].(*openapi.ProtoOASubscribeSpotsReq).GetSubscribeToSpotTimestamp())
+
+		require.NoError(t, m.Close(ctx))
+		requests = spotRequests(transport)
+		require.Len(t, requests, 4)
+		require.IsType(t, &openapi.ProtoOAUnsubscribeSpotsReq{}, requests[3])
+		_, err = m.Subscribe(ctx, 10, SpotOptions{})
+		require.EqualError(t, err, "spot manager is closed")
+	})
+
+	t.Run("Should not keep the subscriber when the subscription fails", func(t *testing.T) {
+		t.Parallel()
+		var fail atomic.Bool
+		fail.Store(true)
+		transport := &fakeTransport{respond: func(req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOASubscribeSpotsReq); ok && fail.Load() {
+				return &openapi.ProtoOAErrorRes{ErrorCode: lo.ToPtr("SYMBOL_NOT_FOUND")}
+			}
+			return nil
+		}}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+		m := NewSpotManager(c.Account(1))
+
+		_, err := m.Subscribe(ctx, 10, SpotOptions{})
+		require.ErrorContains(t, err, "failed to subscribe to the spots")
+		require.Equal(t, 0, m.Subscribers(10))
+
+		fail.Store(false)
+		sub, err := m.Subscribe(ctx, 10, SpotOptions{})
+		require.NoError(t, err)
+		require.Len(t, spotRequests(transport), 2)
+		require.NoError(t, sub.Close(ctx))
+		require.Len(t, spotRequests(transport), 3)
+	})
+
+	t.Run("Should close the subscribers when the subscription can't be restored", func(t *testing.T) {
+		t.Parallel()
+		var fail atomic.Bool
+		transport := &fakeTransport{respond: func(req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOASubscribeSpotsReq); ok && fail.Load() {
+				return &openapi.ProtoOAErrorRes{ErrorCode: lo.ToPtr("SYMBOL_NOT_FOUND")}
+			}
+			return nil
+		}}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+		m := NewSpotManager(c.Account(1))
+
+		first, err := m.Subscribe(ctx, 10, SpotOptions{})
+		require.NoError(t, err)
+		require.NoError(t, first.Err())
+		fail.Store(true)
+		_, err = m.Subscribe(ctx, 10, SpotOptions{Timestamp: true})
+		require.ErrorIs(t, err, ErrSymbolNotFound)
+
+		_, ok := <-first.Events()
+		require.False(t, ok)
+		require.ErrorIs(t, first.Err(), ErrSymbolNotFound)
+		require.Equal(t, 0, m.Subscribers(10))
+		require.NoError(t, first.Close(ctx))
+		require.Len(t, spotRequests(transport), 4)
+	})
+
+	t.Run("Should subscribe and close in parallel", func(t *testing.T) {
+		t.Parallel()
+		transport := &fakeTransport{}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		defer func() { require.NoError(t, c.Stop()) }()
+		m := NewSpotManager(c.Account(1))
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					sub, err := m.Subscribe(ctx, int64(10+i%3), SpotOptions{Timestamp: j%2 == 0})
+					if err != nil {
+						errs <- err
+						return
+					}
+					transport.event(newTestSpotEvent(int64(10+i%3), 1, 0))
+					errs <- sub.Close(ctx)
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		for symbolID := int64(10); symbolID < 13; symbolID++ {
+			require.Equal(t, 0, m.Subscribers(symbolID))
+		}
+		require.NoError(t, m.Close(ctx))
+	})
+}
@@ -0,0 +1,119 @@
+package ctrader
+
+import (
+	"strconv"
+	"sync"
+)
+
+// State is the lifecycle state of the client connection.
+type State int
+
+const (
+	// StateDisconnected is the state before the client is started, or after all reconnection attempts have failed.
+	StateDisconnected State = iota
+
+	// StateConnecting is used while the transport is being opened.
+	StateConnecting
+
+	// StateAppAuthorized means the application was authorized, but the session is not restored yet.
+	StateAppAuthorized
+
+	// StateReady means the client is connected and the session is restored, requests can be sent.
+	StateReady
+
+	// StateReconnecting is used while the client waits for the next reconnection attempt.
+	StateReconnecting
+
+	// StateClosed is the state after the client is stopped.
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateAppAuthorized:
+		return "AppAuthorized"
+	case StateReady:
+		return "Ready"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateClosed:
+		return "Closed"
+	default:
+		return "State(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// StateChange describes a transition between two states. Err holds the reason of the transition, when there is one,
+// like the connection error that caused a reconnection.
+type StateChange struct {
+	From State
+	To   State
+	Err  error
+}
+
+type stateListener struct {
+	id uint64
+	fn func(StateChange)
+}
+
+type stateMachine struct {
+	mutex     sync.Mutex
+	state     State
+	listeners []stateListener
+	sequence  uint64
+}
+
+// State returns the current state of the client.
+func (c *Client) State() State {
+	c.stateMachine.mutex.Lock()
+	defer c.stateMachine.mutex.Unlock()
+	return c.stateMachine.state
+}
+
+// OnStateChange registers a function to be called on every state transition. The functions are called synchronously,
+// in the order they were registered, so they should not block. The returned function removes the registration.
+func (c *Client) OnStateChange(fn func(StateChange)) (unsubscribe func()) {
+	sm := &c.stateMachine
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.sequence++
+	id := sm.sequence
+	sm.listeners = append(sm.listeners, stateListener{id: id, fn: fn})
+	return func() {
+		sm.mutex.Lock()
+		defer sm.mutex.Unlock()
+		for i, listener := range sm.listeners {
+			if listener.id == id {
+				sm.listeners = append(sm.listeners[:i:i], sm.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) setState(to State, err error) {
+	sm := &c.stateMachine
+	sm.mutex.Lock()
+	from := sm.state
+	if from == to {
+		sm.mutex.Unlock()
+		return
+	}
+	sm.state = to
+	listeners := sm.listeners
+	sm.mutex.Unlock()
+
+	if err != nil {
+		c.Logger.Debug("state changed", "from", from.String(), "to", to.String(), "error", err.Error())
+	} else {
+		c.Logger.Debug("state changed", "from", from.String(), "to", to.String())
+	}
+	change := StateChange{From: from, To: to, Err: err}
+	for _, listener := range listeners {
+		listener.fn(change)
+	}
+}
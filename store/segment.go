@@ -0,0 +1,194 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// segmentMagic identifies the segment files and the version of the format.
+const segmentMagic = "CTRSEG1\n"
+
+// segmentExtension is the extension of the segment files, the temporary files don't have it.
+const segmentExtension = ".seg"
+
+// Range is a half-open time range, [From, To).
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// segment is a file with the records of a time range. The name of the file has the range, in Unix milliseconds, as
+// '<from>-<to>.seg'. Segments are written once, to a temporary file that is renamed when complete, and never changed.
+type segment struct {
+	path string
+	rng  Range
+}
+
+// listSegments returns the segments of a directory sorted by the start of the range.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list the segments: %w", err)
+	}
+	var segments []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		rawFrom, rawTo, ok := strings.Cut(strings.TrimSuffix(name, segmentExtension), "-")
+		if !ok {
+			continue
+		}
+		from, errFrom := strconv.ParseInt(rawFrom, 10, 64)
+		to, errTo := strconv.ParseInt(rawTo, 10, 64)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		segments = append(segments, segment{
+			path: filepath.Join(dir, name),
+			rng:  Range{From: time.UnixMilli(from).UTC(), To: time.UnixMilli(to).UTC()},
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].rng.From.Before(segments[j].rng.From) })
+	return segments, nil
+}
+
+// writeSegment writes the records of the range. The records are encoded by fn, which is called for each one of them.
+func writeSegment(dir string, rng Range, count int, fn func(w *bufio.Writer, i int) error) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create the directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create the segment: %w", err)
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	w := bufio.NewWriter(f)
+	if err := writeRecords(w, count, fn); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return fmt.Errorf("failed to sync the segment: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close the segment: %w", err)
+	}
+	name := fmt.Sprintf("%d-%d%s", rng.From.UnixMilli(), rng.To.UnixMilli(), segmentExtension)
+	if err := os.Rename(f.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to rename the segment: %w", err)
+	}
+	return nil
+}
+
+func writeRecords(w *bufio.Writer, count int, fn func(w *bufio.Writer, i int) error) error {
+	if _, err := w.WriteString(segmentMagic); err != nil {
+		return fmt.Errorf("failed to write the header: %w", err)
+	}
+	for i := 0; i < count; i++ {
+		if err := fn(w, i); err != nil {
+			return fmt.Errorf("failed to write the record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush the segment: %w", err)
+	}
+	return nil
+}
+
+// readSegment reads the records of a segment. Each record has size fields of 8 bytes, which are passed to
+// fn.
+func readSegment(path string, size int, fn func(fields []int64)) error {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to open the segment: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(segmentMagic))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("failed to read the header of '%s': %w", path, err)
+	}
+	if string(header) != segmentMagic {
+		return fmt.Errorf("invalid segment header at '%s'", path)
+	}
+	buf := make([]byte, size*8)
+	fields := make([]int64, size)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read the record of '%s': %w", path, err)
+		}
+		for i := range fields {
+			fields[i] = int64(binary.LittleEndian.Uint64(buf[i*8:]))
+		}
+		fn(fields)
+	}
+}
+
+// writeFields writes the fields of a record.
+func writeFields(w *bufio.Writer, fields ...int64) error {
+	var buf [8]byte
+	for _, field := range fields {
+		binary.LittleEndian.PutUint64(buf[:], uint64(field))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+	return nil
+}
+
+// gaps returns the parts of the range that are not covered by the segments.
+func gaps(segments []segment, rng Range) []Range {
+	var result []Range
+	cursor := rng.From
+	for _, s := range segments {
+		if !s.rng.To.After(cursor) {
+			continue
+		}
+		if !s.rng.From.Before(rng.To) {
+			break
+		}
+		if s.rng.From.After(cursor) {
+			result = append(result, Range{From: cursor, To: s.rng.From})
+		}
+		cursor = s.rng.To
+	}
+	if cursor.Before(rng.To) {
+		result = append(result, Range{From: cursor, To: rng.To})
+	}
+	return result
+}
+
+// covers tells if any of the segments has the records of the time.
+func covers(segments []segment, t time.Time) bool {
+	for _, s := range segments {
+		if !t.Before(s.rng.From) && t.Before(s.rng.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps tells if the segment has records of the range.
+func (s segment) overlaps(rng Range) bool {
+	return s.rng.From.Before(rng.To) && rng.From.Before(s.rng.To)
+}
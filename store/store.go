@@ -0,0 +1,311 @@
+// Package store keeps the market data downloaded from the cTrader Open API at the local disk, this way the same history
+// is downloaded only once.
+//
+// The data is organized by symbol, kind and period or quote type, at directories like '<dir>/<symbolID>/trendbars/M1'
+// and '<dir>/<symbolID>/ticks/BID'. Each directory has append-only segment files with the records of a time range, and
+// the ranges of the segments are what the store knows about, even when they don't have records, like the weekends.
+package store
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diegobernardes/ctrader"
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// Store is a file based store of candles and ticks. It's safe for concurrent use.
+type Store struct {
+	dir   string
+	mutex sync.Mutex
+
+	// downloads has a mutex per directory, held from the gap check until the gaps are stored, so concurrent downloads
+	// of the same data don't download and store it twice.
+	downloads map[string]*sync.Mutex
+
+	// now is used to avoid storing the data that may still change.
+	now func() time.Time
+}
+
+// Open returns a store at the directory, which is created if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the directory: %w", err)
+	}
+	return &Store{dir: dir, downloads: make(map[string]*sync.Mutex), now: time.Now}, nil
+}
+
+// WriteCandles stores the candles of the range. The range is marked as known, even if there are no candles, so the
+// candles must be all the ones the server has for the range.
+func (s *Store) WriteCandles(
+	symbolID int64, period openapi.ProtoOATrendbarPeriod, rng Range, candles []ctrader.Candle,
+) error {
+	if !rng.From.Before(rng.To) {
+		return errors.New("invalid time range")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return writeSegment(s.candlesDir(symbolID, period), rng, len(candles), func(w *bufio.Writer, i int) error {
+		c := candles[i]
+		return writeFields(
+			w, c.Time.UnixMilli(), int64(c.Open), int64(c.High), int64(c.Low), int64(c.Close), c.Volume,
+		)
+	})
+}
+
+// Candles returns the stored candles of the range, sorted by time.
+func (s *Store) Candles(symbolID int64, period openapi.ProtoOATrendbarPeriod, rng Range) ([]ctrader.Candle, error) {
+	duration, err := ctrader.PeriodDuration(period)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := listSegments(s.candlesDir(symbolID, period))
+	if err != nil {
+		return nil, err
+	}
+	var candles []ctrader.Candle
+	for _, segment := range segments {
+		if !segment.overlaps(rng) {
+			continue
+		}
+		err := readSegment(segment.path, 6, func(fields []int64) {
+			candle := ctrader.Candle{
+				Time:   time.UnixMilli(fields[0]).UTC(),
+				Period: duration,
+				Open:   ctrader.Price(fields[1]),
+				High:   ctrader.Price(fields[2]),
+				Low:    ctrader.Price(fields[3]),
+				Close:  ctrader.Price(fields[4]),
+				Volume: fields[5],
+			}
+			if !candle.Time.Before(rng.From) && candle.Time.Before(rng.To) {
+				candles = append(candles, candle)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Segments written concurrently for the same range may overlap, the duplicates are removed.
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].Time.Before(candles[j].Time) })
+	result := candles[:0]
+	for _, candle := range candles {
+		if n := len(result); n > 0 && result[n-1].Time.Equal(candle.Time) {
+			continue
+		}
+		result = append(result, candle)
+	}
+	return result, nil
+}
+
+// CandleGaps returns the parts of the range that are not stored.
+func (s *Store) CandleGaps(symbolID int64, period openapi.ProtoOATrendbarPeriod, rng Range) ([]Range, error) {
+	segments, err := listSegments(s.candlesDir(symbolID, period))
+	if err != nil {
+		return nil, err
+	}
+	return gaps(segments, rng), nil
+}
+
+// WriteTicks stores the ticks of the range. Like WriteCandles, the ticks must be all the ones the server has for the
+// range.
+func (s *Store) WriteTicks(symbolID int64, quoteType openapi.ProtoOAQuoteType, rng Range, ticks []ctrader.Tick) error {
+	if !rng.From.Before(rng.To) {
+		return errors.New("invalid time range")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return writeSegment(s.ticksDir(symbolID, quoteType), rng, len(ticks), func(w *bufio.Writer, i int) error {
+		return writeFields(w, ticks[i].Time.UnixMilli(), int64(ticks[i].Price))
+	})
+}
+
+// Ticks returns the stored ticks of the range in chronological order.
+func (s *Store) Ticks(symbolID int64, quoteType openapi.ProtoOAQuoteType, rng Range) ([]ctrader.Tick, error) {
+	segments, err := listSegments(s.ticksDir(symbolID, quoteType))
+	if err != nil {
+		return nil, err
+	}
+
+	// Segments may overlap and there may be many ticks at the same millisecond, so the ticks can't be deduplicated by
+	// time. Instead, each instant is read from the first segment that covers it.
+	var ticks []ctrader.Tick
+	for i, segment := range segments {
+		if !segment.overlaps(rng) {
+			continue
+		}
+		previous := segments[:i]
+		err := readSegment(segment.path, 2, func(fields []int64) {
+			tick := ctrader.Tick{Time: time.UnixMilli(fields[0]).UTC(), Price: ctrader.Price(fields[1])}
+			if !tick.Time.Before(rng.From) && tick.Time.Before(rng.To) && !covers(previous, tick.Time) {
+				ticks = append(ticks, tick)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks, nil
+}
+
+// TickGaps returns the parts of the range that are not stored.
+func (s *Store) TickGaps(symbolID int64, quoteType openapi.ProtoOAQuoteType, rng Range) ([]Range, error) {
+	segments, err := listSegments(s.ticksDir(symbolID, quoteType))
+	if err != nil {
+		return nil, err
+	}
+	return gaps(segments, rng), nil
+}
+
+// DownloadTrendbars returns the candles of the query. Only the gaps are downloaded, and they're stored for the next
+// time. The candles that may still change, the ones that are not closed, are returned but not stored.
+func (s *Store) DownloadTrendbars(
+	ctx context.Context, a *ctrader.Account, q ctrader.TrendbarQuery,
+) ([]ctrader.Candle, error) {
+	duration, err := ctrader.PeriodDuration(q.Period)
+	if err != nil {
+		return nil, err
+	}
+	rng := Range{From: q.From, To: q.To}
+	unlock := s.lockDownload(s.candlesDir(q.SymbolID, q.Period))
+	defer unlock()
+	missing, err := s.CandleGaps(q.SymbolID, q.Period, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	// The candles that open after the limit could be still open.
+	limit := s.now().Add(-duration)
+	var fresh []ctrader.Candle
+	for _, gap := range missing {
+		gapQuery := q
+		gapQuery.From, gapQuery.To = gap.From, gap.To
+		candles, err := collectCandles(a.DownloadTrendbars(ctx, gapQuery))
+		if err != nil {
+			return nil, err
+		}
+
+		stored := Range{From: gap.From, To: minTime(gap.To, limit)}
+		index := sort.Search(len(candles), func(i int) bool { return !candles[i].Time.Before(stored.To) })
+		fresh = append(fresh, candles[index:]...)
+		if !stored.From.Before(stored.To) {
+			continue
+		}
+		if err := s.WriteCandles(q.SymbolID, q.Period, stored, candles[:index]); err != nil {
+			return nil, err
+		}
+	}
+
+	candles, err := s.Candles(q.SymbolID, q.Period, rng)
+	if err != nil {
+		return nil, err
+	}
+	candles = append(candles, fresh...)
+	sort.SliceStable(candles, func(i, j int) bool { return candles[i].Time.Before(candles[j].Time) })
+	return candles, nil
+}
+
+// DownloadTicks returns the ticks of the query. Only the gaps are downloaded, and they're stored for the next time,
+// except the ones that are too recent.
+func (s *Store) DownloadTicks(
+	ctx context.Context, a *ctrader.Account, q ctrader.TickQuery, quoteType openapi.ProtoOAQuoteType,
+) ([]ctrader.Tick, error) {
+	rng := Range{From: q.From, To: q.To}
+	unlock := s.lockDownload(s.ticksDir(q.SymbolID, quoteType))
+	defer unlock()
+	missing, err := s.TickGaps(q.SymbolID, quoteType, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := s.now()
+	var fresh []ctrader.Tick
+	for _, gap := range missing {
+		gapQuery := q
+		gapQuery.From, gapQuery.To = gap.From, gap.To
+		ticks, err := collectTicks(a.DownloadTicks(ctx, gapQuery, quoteType))
+		if err != nil {
+			return nil, err
+		}
+
+		stored := Range{From: gap.From, To: minTime(gap.To, limit)}
+		index := sort.Search(len(ticks), func(i int) bool { return !ticks[i].Time.Before(stored.To) })
+		fresh = append(fresh, ticks[index:]...)
+		if !stored.From.Before(stored.To) {
+			continue
+		}
+		if err := s.WriteTicks(q.SymbolID, quoteType, stored, ticks[:index]); err != nil {
+			return nil, err
+		}
+	}
+
+	ticks, err := s.Ticks(q.SymbolID, quoteType, rng)
+	if err != nil {
+		return nil, err
+	}
+	ticks = append(ticks, fresh...)
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks, nil
+}
+
+// lockDownload locks the downloads of the directory and returns the function that unlocks them.
+func (s *Store) lockDownload(dir string) func() {
+	s.mutex.Lock()
+	mutex, ok := s.downloads[dir]
+	if !ok {
+		mutex = &sync.Mutex{}
+		s.downloads[dir] = mutex
+	}
+	s.mutex.Unlock()
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+func (s *Store) candlesDir(symbolID int64, period openapi.ProtoOATrendbarPeriod) string {
+	return filepath.Join(s.dir, strconv.FormatInt(symbolID, 10), "trendbars", period.String())
+}
+
+func (s *Store) ticksDir(symbolID int64, quoteType openapi.ProtoOAQuoteType) string {
+	return filepath.Join(s.dir, strconv.FormatInt(symbolID, 10), "ticks", quoteType.String())
+}
+
+func collectCandles(iterator *ctrader.CandleIterator) ([]ctrader.Candle, error) {
+	defer iterator.Close()
+	var candles []ctrader.Candle
+	for iterator.Next() {
+		candles = append(candles, iterator.Candle())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, fmt.Errorf("failed to download the trendbars: %w", err)
+	}
+	return candles, nil
+}
+
+func collectTicks(iterator *ctrader.TickIterator) ([]ctrader.Tick, error) {
+	defer iterator.Close()
+	var ticks []ctrader.Tick
+	for iterator.Next() {
+		ticks = append(ticks, iterator.Tick())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, fmt.Errorf("failed to download the ticks: %w", err)
+	}
+	return ticks, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
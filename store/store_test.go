@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader"
+	"github.com/diegobernardes/ctrader/ctradertest"
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// history serves a M1 trendbar and a bid tick per minute, and records the requests.
+type history struct {
+	mutex    sync.Mutex
+	requests []proto.Message
+}
+
+func (h *history) trendbars(req *openapi.ProtoOAGetTrendbarsReq) []proto.Message {
+	h.mutex.Lock()
+	h.requests = append(h.requests, req)
+	h.mutex.Unlock()
+	resp := &openapi.ProtoOAGetTrendbarsRes{
+		CtidTraderAccountId: req.CtidTraderAccountId,
+		Period:              req.Period,
+		HasMore:             lo.ToPtr(false),
+	}
+	minute := time.Minute.Milliseconds()
+	for at := (req.GetFromTimestamp() + minute - 1) / minute; at <= req.GetToTimestamp()/minute; at++ {
+		resp.Trendbar = append(resp.Trendbar, &openapi.ProtoOATrendbar{
+			Volume:                lo.ToPtr(int64(1)),
+			Low:                   lo.ToPtr(at % 100000),
+			DeltaClose:            lo.ToPtr(uint64(1)),
+			UtcTimestampInMinutes: lo.ToPtr(uint32(at)),
+		})
+	}
+	return []proto.Message{resp}
+}
+
+func (h *history) ticks(req *openapi.ProtoOAGetTickDataReq) []proto.Message {
+	h.mutex.Lock()
+	h.requests = append(h.requests, req)
+	h.mutex.Unlock()
+	resp := &openapi.ProtoOAGetTickDataRes{CtidTraderAccountId: req.CtidTraderAccountId, HasMore: lo.ToPtr(false)}
+	minute := time.Minute.Milliseconds()
+	var timestamp, price int64
+	for at := req.GetToTimestamp() / minute; at*minute >= req.GetFromTimestamp(); at-- {
+		resp.TickData = append(resp.TickData, &openapi.ProtoOATickData{
+			Timestamp: lo.ToPtr(at*minute - timestamp),
+			Tick:      lo.ToPtr(at%100000 - price),
+		})
+		timestamp, price = at*minute, at%100000
+	}
+	return []proto.Message{resp}
+}
+
+func (h *history) sent() []proto.Message {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]proto.Message(nil), h.requests...)
+}
+
+func newTestAccount(t *testing.T) (*ctrader.Account, *history) {
+	t.Helper()
+	server := ctradertest.NewServer()
+	t.Cleanup(server.Close)
+	server.AddAccount(ctradertest.Account{ID: 1, AccessToken: "token"})
+	h := &history{}
+	ctradertest.Handle(server, h.trendbars)
+	ctradertest.Handle(server, h.ticks)
+
+	client := server.Client()
+	client.RateLimit.Disabled = true
+	require.NoError(t, client.Start())
+	t.Cleanup(func() { require.NoError(t, client.Stop()) })
+	account := client.Account(1)
+	require.NoError(t, account.Authorize(context.Background(), "token"))
+	return account, h
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Should write and read the records", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		s, err := Open(dir)
+		require.NoError(t, err)
+
+		rng := Range{From: start, To: start.Add(time.Hour)}
+		candles := []ctrader.Candle{
+			{Time: start, Period: time.Minute, Open: 1, High: 4, Low: 0, Close: 2, Volume: 10},
+			{Time: start.Add(time.Minute), Period: time.Minute, Open: 2, High: 5, Low: 1, Close: 3, Volume: 11},
+		}
+		require.NoError(t, s.WriteCandles(1, openapi.ProtoOATrendbarPeriod_M1, rng, candles))
+		ticks := []ctrader.Tick{{Time: start, Price: 1}, {Time: start, Price: 2}, {Time: start.Add(time.Second), Price: 3}}
+		require.NoError(t, s.WriteTicks(1, openapi.ProtoOAQuoteType_ASK, rng, ticks))
+		require.Error(t, s.WriteTicks(1, openapi.ProtoOAQuoteType_ASK, Range{From: start, To: start}, nil))
+
+		s, err = Open(dir)
+		require.NoError(t, err)
+		result, err := s.Candles(1, openapi.ProtoOATrendbarPeriod_M1, Range{From: start, To: start.Add(24 * time.Hour)})
+		require.NoError(t, err)
+		require.Equal(t, candles, result)
+		result, err = s.Candles(1, openapi.ProtoOATrendbarPeriod_M1, Range{From: start.Add(time.Minute), To: rng.To})
+		require.NoError(t, err)
+		require.Equal(t, candles[1:], result)
+		resultTicks, err := s.Ticks(1, openapi.ProtoOAQuoteType_ASK, rng)
+		require.NoError(t, err)
+		require.Equal(t, ticks, resultTicks)
+
+		missing, err := s.CandleGaps(
+			1, openapi.ProtoOATrendbarPeriod_M1, Range{From: start.Add(-time.Hour), To: start.Add(2 * time.Hour)},
+		)
+		require.NoError(t, err)
+		require.Equal(t, []Range{
+			{From: start.Add(-time.Hour), To: start},
+			{From: start.Add(time.Hour), To: start.Add(2 * time.Hour)},
+		}, missing)
+		missing, err = s.TickGaps(1, openapi.ProtoOAQuoteType_BID, rng)
+		require.NoError(t, err)
+		require.Equal(t, []Range{rng}, missing)
+	})
+
+	t.Run("Should read the overlapping ticks once", func(t *testing.T) {
+		t.Parallel()
+		s, err := Open(t.TempDir())
+		require.NoError(t, err)
+
+		ticks := []ctrader.Tick{
+			{Time: start, Price: 1},
+			{Time: start.Add(time.Second), Price: 2},
+			{Time: start.Add(time.Second), Price: 2},
+			{Time: start.Add(2 * time.Second), Price: 3},
+		}
+		first := Range{From: start, To: start.Add(2 * time.Second)}
+		require.NoError(t, s.WriteTicks(1, openapi.ProtoOAQuoteType_BID, first, ticks[:3]))
+		second := Range{From: start.Add(time.Second), To: start.Add(time.Minute)}
+		require.NoError(t, s.WriteTicks(1, openapi.ProtoOAQuoteType_BID, second, ticks[1:]))
+
+		result, err := s.Ticks(1, openapi.ProtoOAQuoteType_BID, Range{From: start, To: start.Add(time.Hour)})
+		require.NoError(t, err)
+		require.Equal(t, ticks, result)
+	})
+
+	t.Run("Should download the gaps once when concurrent", func(t *testing.T) {
+		t.Parallel()
+		account, h := newTestAccount(t)
+		s, err := Open(t.TempDir())
+		require.NoError(t, err)
+		query := ctrader.TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: start, To: start.Add(time.Hour),
+		}
+		tickQuery := ctrader.TickQuery{SymbolID: 1, From: start, To: start.Add(time.Hour)}
+
+		var (
+			wg      sync.WaitGroup
+			candles [5][]ctrader.Candle
+			ticks   [5][]ctrader.Tick
+			errs    [10]error
+		)
+		for i := range candles {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				candles[i], errs[i] = s.DownloadTrendbars(context.Background(), account, query)
+			}()
+			go func() {
+				defer wg.Done()
+				ticks[i], errs[5+i] = s.DownloadTicks(context.Background(), account, tickQuery, openapi.ProtoOAQuoteType_BID)
+			}()
+		}
+		wg.Wait()
+		for i := range candles {
+			require.NoError(t, errs[i])
+			require.NoError(t, errs[5+i])
+			require.Len(t, candles[i], 60)
+			require.Len(t, ticks[i], 60)
+		}
+		require.Len(t, h.sent(), 2)
+		segments, err := listSegments(s.ticksDir(1, openapi.ProtoOAQuoteType_BID))
+		require.NoError(t, err)
+		require.Len(t, segments, 1)
+	})
+
+	t.Run("Should download only the gaps", func(t *testing.T) {
+		t.Parallel()
+		account, h := newTestAccount(t)
+		s, err := Open(t.TempDir())
+		require.NoError(t, err)
+		query := ctrader.TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: start, To: start.Add(2 * time.Hour),
+		}
+
+		candles, err := s.DownloadTrendbars(context.Background(), account, query)
+		require.NoError(t, err)
+		require.Len(t, candles, 120)
+		require.Len(t, h.sent(), 1)
+
+		candles, err = s.DownloadTrendbars(context.Background(), account, query)
+		require.NoError(t, err)
+		require.Len(t, candles, 120)
+		require.Len(t, h.sent(), 1)
+
+		query.From, query.To = start.Add(time.Hour), start.Add(3*time.Hour)
+		candles, err = s.DownloadTrendbars(context.Background(), account, query)
+		require.NoError(t, err)
+		require.Len(t, candles, 120)
+		require.Equal(t, start.Add(time.Hour), candles[0].Time)
+		require.Equal(t, start.Add(3*time.Hour-time.Minute), candles[119].Time)
+		requests := h.sent()
+		require.Len(t, requests, 2)
+		req, ok := requests[1].(*openapi.ProtoOAGetTrendbarsReq)
+		require.True(t, ok)
+		require.Equal(t, start.Add(2*time.Hour).UnixMilli(), req.GetFromTimestamp())
+
+		tickQuery := ctrader.TickQuery{SymbolID: 1, From: start, To: start.Add(time.Hour)}
+		for i := 0; i < 2; i++ {
+			ticks, err := s.DownloadTicks(context.Background(), account, tickQuery, openapi.ProtoOAQuoteType_BID)
+			require.NoError(t, err)
+			require.Len(t, ticks, 60)
+			require.Equal(t, start, ticks[0].Time)
+		}
+		require.Len(t, h.sent(), 3)
+	})
+
+	t.Run("Should not store the recent data", func(t *testing.T) {
+		t.Parallel()
+		account, h := newTestAccount(t)
+		s, err := Open(t.TempDir())
+		require.NoError(t, err)
+		s.now = func() time.Time { return start.Add(90 * time.Minute) }
+		query := ctrader.TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: start, To: start.Add(2 * time.Hour),
+		}
+
+		candles, err := s.DownloadTrendbars(context.Background(), account, query)
+		require.NoError(t, err)
+		require.Len(t, candles, 120)
+		missing, err := s.CandleGaps(1, openapi.ProtoOATrendbarPeriod_M1, Range{From: query.From, To: query.To})
+		require.NoError(t, err)
+		require.Equal(t, []Range{{From: start.Add(89 * time.Minute), To: query.To}}, missing)
+
+		candles, err = s.DownloadTrendbars(context.Background(), account, query)
+		require.NoError(t, err)
+		require.Len(t, candles, 120)
+		require.Len(t, h.sent(), 2)
+	})
+}
@@ -0,0 +1,458 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// symbolBatchSize is the maximum number of symbols requested by a single 'ProtoOASymbolByIdReq'.
+const symbolBatchSize = 100
+
+// Symbol has the symbol details together with its assets and category. The protobuf messages are shared with the
+// catalog and should not be modified. The fields that come from the details, from Digits to StepVolume, are zero
+// until the details are loaded.
+type Symbol struct {
+	ID          int64
+	Name        string
+	Description string
+	Enabled     bool
+	Digits      int32
+	PipPosition int32
+
+	// LotSize, MinVolume, MaxVolume and StepVolume are in cents, like the volume of the orders.
+	LotSize    int64
+	MinVolume  int64
+	MaxVolume  int64
+	StepVolume int64
+
+	BaseAsset  *openapi.ProtoOAAsset
+	QuoteAsset *openapi.ProtoOAAsset
+	Category   *openapi.ProtoOASymbolCategory
+
+	// Details has the remaining information, like the trading schedule, swaps and commissions.
+	Details *openapi.ProtoOASymbol
+}
+
+// FormatPrice returns the price with the digits of the symbol.
+func (s Symbol) FormatPrice(p Price) string {
+	return p.Format(s.Digits)
+}
+
+// Pips returns the number of pips of a price difference at the symbol.
+func (s Symbol) Pips(p Price) Decimal {
+	return p.Pips(s.PipPosition)
+}
+
+// PriceFromPips returns the price difference of a number of pips at the symbol.
+func (s Symbol) PriceFromPips(pips Decimal) (Price, error) {
+	return PriceFromPips(pips, s.PipPosition)
+}
+
+// Lots returns the volume in lots of the symbol.
+func (s Symbol) Lots(v Volume) Decimal {
+	return v.Lots(s.LotSize)
+}
+
+// VolumeFromLots returns the volume of a number of lots of the symbol.
+func (s Symbol) VolumeFromLots(lots Decimal) (Volume, error) {
+	return VolumeFromLots(lots, s.LotSize)
+}
+
+// SymbolCatalog caches the symbols, assets and categories of an account. The symbols, assets and categories are
+// loaded up front, while the symbol details, from 'ProtoOASymbolByIdReq', are loaded at the first lookup of each symbol
+// and then cached. It's kept current by reloading the symbols listed at 'ProtoOASymbolChangedEvent'. The catalog is
+// safe for concurrent use and must be closed after use.
+type SymbolCatalog struct {
+	account     *Account
+	unsubscribe func()
+
+	mutex      sync.RWMutex
+	symbols    map[int64]Symbol
+	names      map[string]int64
+	assets     map[int64]*openapi.ProtoOAAsset
+	categories map[int64]*openapi.ProtoOASymbolCategory
+
+	// loading has the symbols with details being requested, the channel is closed when the request finishes.
+	loading map[int64]chan struct{}
+
+	refreshMutex sync.Mutex
+	refreshing   bool
+	closed       bool
+	pending      map[int64]struct{}
+	wg           sync.WaitGroup
+}
+
+// NewSymbolCatalog loads the symbols of the account, which must be already authorized.
+func NewSymbolCatalog(ctx context.Context, a *Account) (*SymbolCatalog, error) {
+	c := &SymbolCatalog{
+		account:    a,
+		symbols:    make(map[int64]Symbol),
+		names:      make(map[string]int64),
+		assets:     make(map[int64]*openapi.ProtoOAAsset),
+		categories: make(map[int64]*openapi.ProtoOASymbolCategory),
+		loading:    make(map[int64]chan struct{}),
+		pending:    make(map[int64]struct{}),
+		refreshing: true,
+	}
+
+	// The subscription starts before the load, this way the changes that happen in the meantime are not lost. They're
+	// kept pending, as if a refresh was running, until the load finishes.
+	c.unsubscribe = AccountOn(a, func(e *openapi.ProtoOASymbolChangedEvent) {
+		c.enqueue(e.GetSymbolId())
+	})
+	if err := c.load(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.refreshMutex.Lock()
+	c.refreshing = false
+	c.refreshMutex.Unlock()
+	c.enqueue(nil)
+	return c, nil
+}
+
+// load loads the symbols, assets and categories. It's only called before the refreshes start.
+func (c *SymbolCatalog) load(ctx context.Context) error {
+	assets, categories, err := c.loadAssetsAndCategories(ctx)
+	if err != nil {
+		return err
+	}
+	lightSymbols, err := c.loadLightSymbols(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.assets = assets
+	c.categories = categories
+	c.symbols = make(map[int64]Symbol, len(lightSymbols))
+	c.names = make(map[string]int64, len(lightSymbols))
+	for _, lightSymbol := range lightSymbols {
+		c.store(lightSymbol, nil)
+	}
+	return nil
+}
+
+// Symbol returns the symbol by ID, with its details. The error wraps ErrSymbolNotFound when the account doesn't have
+// the symbol.
+func (c *SymbolCatalog) Symbol(ctx context.Context, id int64) (Symbol, error) {
+	if err := c.LoadDetails(ctx, id); err != nil {
+		return Symbol{}, err
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	symbol, ok := c.symbols[id]
+	if !ok {
+		return Symbol{}, fmt.Errorf("symbol '%d': %w", id, ErrSymbolNotFound)
+	}
+	if symbol.Details == nil {
+		return Symbol{}, fmt.Errorf("the details of the symbol '%d' are not available", id)
+	}
+	return symbol, nil
+}
+
+// SymbolByName returns the symbol by name, like 'EURUSD', with its details. The name is case insensitive.
+func (c *SymbolCatalog) SymbolByName(ctx context.Context, name string) (Symbol, error) {
+	c.mutex.RLock()
+	id, ok := c.names[strings.ToUpper(name)]
+	c.mutex.RUnlock()
+	if !ok {
+		return Symbol{}, fmt.Errorf("symbol '%s': %w", name, ErrSymbolNotFound)
+	}
+	return c.Symbol(ctx, id)
+}
+
+// CachedSymbol returns the symbol by ID without requesting its details. The details, and the fields that come from
+// them, are only set when the symbol was already loaded.
+func (c *SymbolCatalog) CachedSymbol(id int64) (Symbol, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	symbol, ok := c.symbols[id]
+	return symbol, ok
+}
+
+// Symbols returns all the symbols sorted by name. Like CachedSymbol, the details are only set for the symbols already
+// loaded.
+func (c *SymbolCatalog) Symbols() []Symbol {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	symbols := make([]Symbol, 0, len(c.symbols))
+	for _, symbol := range c.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Name < symbols[j].Name })
+	return symbols
+}
+
+// LoadDetails loads the details of the symbols that don't have them yet, in batches of 100 symbols. Symbol calls it
+// for a single symbol, so it's only needed to load many symbols with fewer requests. The symbols being loaded by other
+// calls are not requested again, their loads are awaited instead.
+func (c *SymbolCatalog) LoadDetails(ctx context.Context, ids ...int64) error {
+	var (
+		missing []int64
+		waits   []chan struct{}
+		done    = make(chan struct{})
+	)
+	c.mutex.Lock()
+	for _, id := range ids {
+		symbol, ok := c.symbols[id]
+		if !ok || symbol.Details != nil {
+			continue
+		}
+		if wait, ok := c.loading[id]; ok {
+			waits = append(waits, wait)
+			continue
+		}
+		c.loading[id] = done
+		missing = append(missing, id)
+	}
+	c.mutex.Unlock()
+
+	var err error
+	if len(missing) > 0 {
+		var details map[int64]*openapi.ProtoOASymbol
+		details, err = c.loadDetails(ctx, missing)
+		c.mutex.Lock()
+		for _, id := range missing {
+			delete(c.loading, id)
+			if symbol, ok := c.symbols[id]; ok && details[id] != nil {
+				c.symbols[id] = symbol.withDetails(details[id])
+			}
+		}
+		c.mutex.Unlock()
+		close(done)
+	}
+	for _, wait := range waits {
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return fmt.Errorf("context error while waiting for the symbol details: %w", ctx.Err())
+		}
+	}
+	return err
+}
+
+// Asset returns the asset by ID.
+func (c *SymbolCatalog) Asset(id int64) (*openapi.ProtoOAAsset, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	asset, ok := c.assets[id]
+	return asset, ok
+}
+
+// Category returns the symbol category by ID.
+func (c *SymbolCatalog) Category(id int64) (*openapi.ProtoOASymbolCategory, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	category, ok := c.categories[id]
+	return category, ok
+}
+
+// Close stops the updates of the catalog, the cached symbols are still available.
+func (c *SymbolCatalog) Close() {
+	c.unsubscribe()
+	c.refreshMutex.Lock()
+	c.closed = true
+	c.refreshMutex.Unlock()
+	c.wg.Wait()
+}
+
+// enqueue schedules the reload of the symbols. It's called from the goroutine that reads the connection, so the
+// reload happens at another goroutine, and the symbols changed while a reload is running are batched together.
+func (c *SymbolCatalog) enqueue(ids []int64) {
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+	if c.closed {
+		return
+	}
+	for _, id := range ids {
+		c.pending[id] = struct{}{}
+	}
+	if c.refreshing {
+		return
+	}
+	c.refreshing = true
+	c.wg.Add(1)
+	go c.refreshLoop()
+}
+
+func (c *SymbolCatalog) refreshLoop() {
+	defer c.wg.Done()
+	for {
+		c.refreshMutex.Lock()
+		if len(c.pending) == 0 || c.closed {
+			c.refreshing = false
+			c.refreshMutex.Unlock()
+			return
+		}
+		ids := make([]int64, 0, len(c.pending))
+		for id := range c.pending {
+			ids = append(ids, id)
+		}
+		c.pending = make(map[int64]struct{})
+		c.refreshMutex.Unlock()
+
+		ctx, ctxCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := c.refresh(ctx, ids); err != nil {
+			c.account.client.Logger.Error("failed to refresh the symbols", "error", err.Error())
+		}
+		ctxCancel()
+	}
+}
+
+// refresh reloads the symbols, the assets and categories are reloaded only if the symbols reference unknown ones.
+func (c *SymbolCatalog) refresh(ctx context.Context, ids []int64) error {
+	lightSymbols, err := c.loadLightSymbols(ctx)
+	if err != nil {
+		return err
+	}
+	// Only the details already loaded are requested again, the others keep being loaded at the first lookup.
+	c.mutex.RLock()
+	loaded := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if c.symbols[id].Details != nil {
+			loaded = append(loaded, id)
+		}
+	}
+	c.mutex.RUnlock()
+	details, err := c.loadDetails(ctx, loaded)
+	if err != nil {
+		return err
+	}
+
+	lightSymbolsByID := make(map[int64]*openapi.ProtoOALightSymbol, len(lightSymbols))
+	for _, lightSymbol := range lightSymbols {
+		lightSymbolsByID[lightSymbol.GetSymbolId()] = lightSymbol
+	}
+	c.mutex.RLock()
+	missing := false
+	for _, id := range ids {
+		lightSymbol, ok := lightSymbolsByID[id]
+		if !ok {
+			continue
+		}
+		_, baseAsset := c.assets[lightSymbol.GetBaseAssetId()]
+		_, quoteAsset := c.assets[lightSymbol.GetQuoteAssetId()]
+		_, category := c.categories[lightSymbol.GetSymbolCategoryId()]
+		missing = missing || !baseAsset || !quoteAsset || !category
+	}
+	c.mutex.RUnlock()
+
+	var (
+		assets     map[int64]*openapi.ProtoOAAsset
+		categories map[int64]*openapi.ProtoOASymbolCategory
+	)
+	if missing {
+		if assets, categories, err = c.loadAssetsAndCategories(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if missing {
+		c.assets = assets
+		c.categories = categories
+	}
+	for _, id := range ids {
+		if symbol, ok := c.symbols[id]; ok {
+			delete(c.names, strings.ToUpper(symbol.Name))
+			delete(c.symbols, id)
+		}
+		if lightSymbol, ok := lightSymbolsByID[id]; ok {
+			c.store(lightSymbol, details[id])
+		}
+	}
+	return nil
+}
+
+// store must be called with the mutex locked. The details may be nil when they're not loaded yet.
+func (c *SymbolCatalog) store(lightSymbol *openapi.ProtoOALightSymbol, details *openapi.ProtoOASymbol) {
+	symbol := Symbol{
+		ID:          lightSymbol.GetSymbolId(),
+		Name:        lightSymbol.GetSymbolName(),
+		Description: lightSymbol.GetDescription(),
+		Enabled:     lightSymbol.GetEnabled(),
+		BaseAsset:   c.assets[lightSymbol.GetBaseAssetId()],
+		QuoteAsset:  c.assets[lightSymbol.GetQuoteAssetId()],
+		Category:    c.categories[lightSymbol.GetSymbolCategoryId()],
+	}
+	if details != nil {
+		symbol = symbol.withDetails(details)
+	}
+	c.symbols[symbol.ID] = symbol
+	c.names[strings.ToUpper(symbol.Name)] = symbol.ID
+}
+
+func (s Symbol) withDetails(details *openapi.ProtoOASymbol) Symbol {
+	s.Digits = details.GetDigits()
+	s.PipPosition = details.GetPipPosition()
+	s.LotSize = details.GetLotSize()
+	s.MinVolume = details.GetMinVolume()
+	s.MaxVolume = details.GetMaxVolume()
+	s.StepVolume = details.GetStepVolume()
+	s.Details = details
+	return s
+}
+
+func (c *SymbolCatalog) loadAssetsAndCategories(
+	ctx context.Context,
+) (map[int64]*openapi.ProtoOAAsset, map[int64]*openapi.ProtoOASymbolCategory, error) {
+	respAssets, err := AccountCommand[*openapi.ProtoOAAssetListReq, *openapi.ProtoOAAssetListRes](
+		ctx, c.account, &openapi.ProtoOAAssetListReq{},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the assets: %w", err)
+	}
+	respCategories, err := AccountCommand[*openapi.ProtoOASymbolCategoryListReq, *openapi.ProtoOASymbolCategoryListRes](
+		ctx, c.account, &openapi.ProtoOASymbolCategoryListReq{},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the symbol categories: %w", err)
+	}
+
+	assets := make(map[int64]*openapi.ProtoOAAsset, len(respAssets.GetAsset()))
+	for _, asset := range respAssets.GetAsset() {
+		assets[asset.GetAssetId()] = asset
+	}
+	categories := make(map[int64]*openapi.ProtoOASymbolCategory, len(respCategories.GetSymbolCategory()))
+	for _, category := range respCategories.GetSymbolCategory() {
+		categories[category.GetId()] = category
+	}
+	return assets, categories, nil
+}
+
+func (c *SymbolCatalog) loadLightSymbols(ctx context.Context) ([]*openapi.ProtoOALightSymbol, error) {
+	resp, err := AccountCommand[*openapi.ProtoOASymbolsListReq, *openapi.ProtoOASymbolsListRes](
+		ctx, c.account, &openapi.ProtoOASymbolsListReq{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the symbols: %w", err)
+	}
+	return resp.GetSymbol(), nil
+}
+
+// loadDetails requests the symbols in batches of symbolBatchSize.
+func (c *SymbolCatalog) loadDetails(ctx context.Context, ids []int64) (map[int64]*openapi.ProtoOASymbol, error) {
+	details := make(map[int64]*openapi.ProtoOASymbol, len(ids))
+	for start := 0; start < len(ids); start += symbolBatchSize {
+		batch := ids[start:min(start+symbolBatchSize, len(ids))]
+		resp, err := AccountCommand[*openapi.ProtoOASymbolByIdReq, *openapi.ProtoOASymbolByIdRes](
+			ctx, c.account, &openapi.ProtoOASymbolByIdReq{SymbolId: batch},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the symbol details: %w", err)
+		}
+		for _, symbol := range resp.GetSymbol() {
+			details[symbol.GetSymbolId()] = symbol
+		}
+	}
+	return details, nil
+}
@@ -0,0 +1,187 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// fakeSymbols answers the symbol requests of the account 1.
+type fakeSymbols struct {
+	mutex      sync.Mutex
+	names      map[int64]string
+	assets     []int64
+	byIDCalls  int
+	byIDLength []int
+}
+
+func (f *fakeSymbols) respond(req proto.Message) proto.Message {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	accountID := lo.ToPtr(int64(1))
+	switch v := req.(type) {
+	case *openapi.ProtoOAAssetListReq:
+		resp := &openapi.ProtoOAAssetListRes{CtidTraderAccountId: accountID}
+		for _, id := range f.assets {
+			resp.Asset = append(resp.Asset, &openapi.ProtoOAAsset{
+				AssetId: lo.ToPtr(id), Name: lo.ToPtr(fmt.Sprintf("asset-%d", id)),
+			})
+		}
+		return resp
+	case *openapi.ProtoOASymbolCategoryListReq:
+		return &openapi.ProtoOASymbolCategoryListRes{
+			CtidTraderAccountId: accountID,
+			SymbolCategory: []*openapi.ProtoOASymbolCategory{
+				{Id: lo.ToPtr(int64(1)), AssetClassId: lo.ToPtr(int64(1)), Name: lo.ToPtr("Forex")},
+			},
+		}
+	case *openapi.ProtoOASymbolsListReq:
+		resp := &openapi.ProtoOASymbolsListRes{CtidTraderAccountId: accountID}
+		for id, name := range f.names {
+			resp.Symbol = append(resp.Symbol, &openapi.ProtoOALightSymbol{
+				SymbolId:         lo.ToPtr(id),
+				SymbolName:       lo.ToPtr(name),
+				BaseAssetId:      lo.ToPtr(id),
+				QuoteAssetId:     lo.ToPtr(int64(1)),
+				SymbolCategoryId: lo.ToPtr(int64(1)),
+			})
+		}
+		return resp
+	case *openapi.ProtoOASymbolByIdReq:
+		f.byIDCalls++
+		f.byIDLength = append(f.byIDLength, len(v.GetSymbolId()))
+		resp := &openapi.ProtoOASymbolByIdRes{CtidTraderAccountId: accountID}
+		for _, id := range v.GetSymbolId() {
+			resp.Symbol = append(resp.Symbol, &openapi.ProtoOASymbol{
+				SymbolId: lo.ToPtr(id), Digits: lo.ToPtr(int32(5)), PipPosition: lo.ToPtr(int32(4)),
+			})
+		}
+		return resp
+	default:
+		return nil
+	}
+}
+
+func TestSymbolCatalog(t *testing.T) {
+	t.Parallel()
+
+	symbols := &fakeSymbols{names: make(map[int64]string), assets: []int64{1}}
+	for id := int64(1); id <= 150; id++ {
+		symbols.names[id] = fmt.Sprintf("SYMBOL%d", id)
+		symbols.assets = append(symbols.assets, id)
+	}
+	transport := &fakeTransport{respond: symbols.respond}
+	c := newTestClient(transport)
+	require.NoError(t, c.Start())
+	defer func() { require.NoError(t, c.Stop()) }()
+
+	ctx := context.Background()
+	catalog, err := NewSymbolCatalog(ctx, c.Account(1))
+	require.NoError(t, err)
+	defer catalog.Close()
+
+	t.Run("Should load the details at the first lookup", func(t *testing.T) {
+		require.Len(t, catalog.Symbols(), 150)
+		symbols.mutex.Lock()
+		require.Zero(t, symbols.byIDCalls)
+		symbols.mutex.Unlock()
+
+		cached, ok := catalog.CachedSymbol(10)
+		require.True(t, ok)
+		require.Nil(t, cached.Details)
+		require.Equal(t, "asset-10", cached.BaseAsset.GetName())
+
+		symbol, err := catalog.SymbolByName(ctx, "symbol10")
+		require.NoError(t, err)
+		require.Equal(t, int64(10), symbol.ID)
+		require.Equal(t, int32(5), symbol.Digits)
+		require.Equal(t, int32(4), symbol.PipPosition)
+		require.Equal(t, "asset-10", symbol.BaseAsset.GetName())
+		require.Equal(t, "asset-1", symbol.QuoteAsset.GetName())
+		require.Equal(t, "Forex", symbol.Category.GetName())
+		_, err = catalog.Symbol(ctx, 10)
+		require.NoError(t, err)
+		symbols.mutex.Lock()
+		require.Equal(t, []int{1}, symbols.byIDLength)
+		symbols.mutex.Unlock()
+
+		_, err = catalog.Symbol(ctx, 151)
+		require.ErrorIs(t, err, ErrSymbolNotFound)
+		_, err = catalog.SymbolByName(ctx, "unknown")
+		require.ErrorIs(t, err, ErrSymbolNotFound)
+		asset, ok := catalog.Asset(1)
+		require.True(t, ok)
+		require.Equal(t, "asset-1", asset.GetName())
+		_, ok = catalog.Category(1)
+		require.True(t, ok)
+	})
+
+	t.Run("Should load the details in batches", func(t *testing.T) {
+		symbols.mutex.Lock()
+		symbols.byIDLength = nil
+		symbols.mutex.Unlock()
+
+		ids := make([]int64, 0, 150)
+		for id := int64(1); id <= 150; id++ {
+			ids = append(ids, id)
+		}
+		errs := make(chan error, 3)
+		for range 3 {
+			go func() { errs <- catalog.LoadDetails(ctx, ids...) }()
+		}
+		for range 3 {
+			require.NoError(t, <-errs)
+		}
+		for _, symbol := range catalog.Symbols() {
+			require.NotNil(t, symbol.Details)
+		}
+
+		// The symbol 10 was already loaded and the concurrent loads wait for the first one.
+		symbols.mutex.Lock()
+		require.Equal(t, 149, lo.Sum(symbols.byIDLength))
+		require.LessOrEqual(t, len(symbols.byIDLength), 6)
+		symbols.mutex.Unlock()
+	})
+
+	t.Run("Should refresh the changed symbols", func(t *testing.T) {
+		symbols.mutex.Lock()
+		symbols.names[1] = "RENAMED"
+		symbols.names[151] = "SYMBOL151"
+		symbols.assets = append(symbols.assets, 151)
+		delete(symbols.names, 2)
+		symbols.byIDLength = nil
+		symbols.mutex.Unlock()
+
+		transport.event(&openapi.ProtoOASymbolChangedEvent{
+			CtidTraderAccountId: lo.ToPtr(int64(1)),
+			SymbolId:            []int64{1, 2, 151},
+		})
+		require.Eventually(t, func() bool {
+			_, err := catalog.SymbolByName(ctx, "RENAMED")
+			return err == nil
+		}, 5*time.Second, time.Millisecond)
+
+		_, err := catalog.SymbolByName(ctx, "SYMBOL1")
+		require.ErrorIs(t, err, ErrSymbolNotFound)
+		_, ok := catalog.CachedSymbol(2)
+		require.False(t, ok)
+		cached, ok := catalog.CachedSymbol(151)
+		require.True(t, ok)
+		require.Nil(t, cached.Details)
+		require.Equal(t, "asset-151", cached.BaseAsset.GetName())
+		require.Len(t, catalog.Symbols(), 150)
+
+		// The details of the symbols 1 and 2 were loaded before, so they're requested again.
+		symbols.mutex.Lock()
+		require.Equal(t, []int{2}, symbols.byIDLength)
+		symbols.mutex.Unlock()
+	})
+}
@@ -0,0 +1,236 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// tickWindow is the time range of the windows downloaded concurrently by DownloadTicks and DownloadQuotes.
+const tickWindow = 24 * time.Hour
+
+// Tick is a decoded 'openapi.ProtoOATickData', it's the bid or the ask price at a moment.
+type Tick struct {
+	Time  time.Time
+	Price Price
+}
+
+// Quote has the bid and ask prices at a moment.
+type Quote struct {
+	Time time.Time
+	Bid  Price
+	Ask  Price
+}
+
+// DecodeTicks decodes the ticks of a 'ProtoOAGetTickDataRes'. The response has the ticks from the newest to the oldest,
+// the first one with the absolute time and price and the others with the difference to the previous one. The result
+// is in chronological order.
+func DecodeTicks(res *openapi.ProtoOAGetTickDataRes) []Tick {
+	data := res.GetTickData()
+	ticks := make([]Tick, len(data))
+	var timestamp, price int64
+	for i, tick := range data {
+		timestamp += tick.GetTimestamp()
+		price += tick.GetTick()
+		ticks[len(data)-1-i] = Tick{Time: time.UnixMilli(timestamp).UTC(), Price: Price(price)}
+	}
+	return ticks
+}
+
+// TickQuery selects the ticks to download.
+type TickQuery struct {
+	SymbolID int64
+
+	// From and To are the half-open range, [From, To), of the ticks time.
+	From time.Time
+	To   time.Time
+
+	// Concurrency is the number of windows downloaded at the same time, defaults to 4. The requests are still subject
+	// to the historical rate limit of the client.
+	Concurrency int
+}
+
+// TickIterator streams the downloaded ticks in chronological order. It's used like CandleIterator.
+type TickIterator struct {
+	downloader *downloader[Tick]
+}
+
+// Next moves to the next tick, it returns false at the end of the ticks or when an error happens.
+func (i *TickIterator) Next() bool {
+	return i.downloader.next()
+}
+
+// Tick returns the tick read by Next.
+func (i *TickIterator) Tick() Tick {
+	return i.downloader.current
+}
+
+// Err returns the error that stopped the iteration.
+func (i *TickIterator) Err() error {
+	return i.downloader.err
+}
+
+// Close stops the download.
+func (i *TickIterator) Close() {
+	i.downloader.close()
+}
+
+// QuoteIterator streams the downloaded quotes in chronological order. It's used like CandleIterator.
+type QuoteIterator struct {
+	downloader *downloader[Quote]
+	quote      Quote
+}
+
+// Next moves to the next quote, it returns false at the end of the quotes or when an error happens.
+func (i *QuoteIterator) Next() bool {
+	for i.downloader.next() {
+		change := i.downloader.current
+		i.quote.Time = change.Time
+		if change.Bid != 0 {
+			i.quote.Bid = change.Bid
+		}
+		if change.Ask != 0 {
+			i.quote.Ask = change.Ask
+		}
+		if i.quote.Bid != 0 && i.quote.Ask != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote returns the quote read by Next.
+func (i *QuoteIterator) Quote() Quote {
+	return i.quote
+}
+
+// Err returns the error that stopped the iteration.
+func (i *QuoteIterator) Err() error {
+	return i.downloader.err
+}
+
+// Close stops the download.
+func (i *QuoteIterator) Close() {
+	i.downloader.close()
+}
+
+// DownloadTicks downloads the bid or ask ticks of a symbol. Each window is loaded backwards, from the newest to the
+// oldest tick, while the responses have more ticks than the ones returned.
+func (a *Account) DownloadTicks(
+	ctx context.Context, q TickQuery, quoteType openapi.ProtoOAQuoteType,
+) *TickIterator {
+	if !q.From.Before(q.To) {
+		return &TickIterator{downloader: newFailedDownloader[Tick](errors.New("invalid time range"))}
+	}
+	fetch := func(ctx context.Context, w downloadWindow) ([]Tick, error) {
+		return a.fetchTicks(ctx, q.SymbolID, quoteType, w)
+	}
+	windows := splitWindows(q.From, q.To, tickWindow)
+	return &TickIterator{downloader: newDownloader(ctx, windows, q.Concurrency, fetch, nil)}
+}
+
+// DownloadQuotes downloads the bid and ask ticks of a symbol and merges them into quotes. There is a quote for each
+// millisecond that has ticks, and the quotes start once both the bid and ask are known.
+func (a *Account) DownloadQuotes(ctx context.Context, q TickQuery) *QuoteIterator {
+	if !q.From.Before(q.To) {
+		return &QuoteIterator{downloader: newFailedDownloader[Quote](errors.New("invalid time range"))}
+	}
+	fetch := func(ctx context.Context, w downloadWindow) ([]Quote, error) {
+		bids, err := a.fetchTicks(ctx, q.SymbolID, openapi.ProtoOAQuoteType_BID, w)
+		if err != nil {
+			return nil, err
+		}
+		asks, err := a.fetchTicks(ctx, q.SymbolID, openapi.ProtoOAQuoteType_ASK, w)
+		if err != nil {
+			return nil, err
+		}
+		return mergeTicks(bids, asks), nil
+	}
+	windows := splitWindows(q.From, q.To, tickWindow)
+	return &QuoteIterator{downloader: newDownloader(ctx, windows, q.Concurrency, fetch, nil)}
+}
+
+// fetchTicks loads the ticks of a window. The responses have the newest ticks, so the pagination goes backwards until
+// the start of the window. A millisecond can have many ticks, so the ticks of the oldest millisecond of a truncated
+// response are discarded and requested again with the next page.
+func (a *Account) fetchTicks(
+	ctx context.Context, symbolID int64, quoteType openapi.ProtoOAQuoteType, w downloadWindow,
+) ([]Tick, error) {
+	var pages [][]Tick
+	from, to := w.from.UnixMilli(), w.to.UnixMilli()-1
+	for {
+		req := &openapi.ProtoOAGetTickDataReq{
+			SymbolId:      proto.Int64(symbolID),
+			Type:          quoteType.Enum(),
+			FromTimestamp: proto.Int64(from),
+			ToTimestamp:   proto.Int64(to),
+		}
+		res, err := AccountCommand[*openapi.ProtoOAGetTickDataReq, *openapi.ProtoOAGetTickDataRes](ctx, a, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the tick data: %w", err)
+		}
+		ticks := DecodeTicks(res)
+		if !res.GetHasMore() || len(ticks) == 0 {
+			pages = append(pages, ticks)
+			break
+		}
+
+		oldest := ticks[0].Time.UnixMilli()
+		next := oldest
+		if ticks[len(ticks)-1].Time.UnixMilli() == oldest {
+			// The whole page is a single millisecond, it can't be requested again.
+			next = oldest - 1
+		} else {
+			for len(ticks) > 0 && ticks[0].Time.UnixMilli() == oldest {
+				ticks = ticks[1:]
+			}
+		}
+		pages = append(pages, ticks)
+		if next < from {
+			break
+		}
+		to = next
+	}
+
+	var result []Tick
+	for i := len(pages) - 1; i >= 0; i-- {
+		for _, tick := range pages[i] {
+			if !tick.Time.Before(w.from) && tick.Time.Before(w.to) {
+				result = append(result, tick)
+			}
+		}
+	}
+	return result, nil
+}
+
+// mergeTicks merges the bid and ask ticks into the changes of the quote, a price is zero when it didn't change. The
+// ticks of the same millisecond are combined and the last one wins.
+func mergeTicks(bids, asks []Tick) []Quote {
+	quotes := make([]Quote, 0, len(bids)+len(asks))
+	add := func(tick Tick, bid bool) {
+		if n := len(quotes); n == 0 || !quotes[n-1].Time.Equal(tick.Time) {
+			quotes = append(quotes, Quote{Time: tick.Time})
+		}
+		if bid {
+			quotes[len(quotes)-1].Bid = tick.Price
+		} else {
+			quotes[len(quotes)-1].Ask = tick.Price
+		}
+	}
+	var i, j int
+	for i < len(bids) || j < len(asks) {
+		if j == len(asks) || (i < len(bids) && !bids[i].Time.After(asks[j].Time)) {
+			add(bids[i], true)
+			i++
+		} else {
+			add(asks[j], false)
+			j++
+		}
+	}
+	return quotes
+}
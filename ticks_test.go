@@ -0,0 +1,151 @@
+package ctrader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// fakeTicks answers 'ProtoOAGetTickDataReq' like the server: at most chunkSize ticks, the newest ones, delta encoded
+// from the newest to the oldest.
+type fakeTicks struct {
+	mutex     sync.Mutex
+	chunkSize int
+	ticks     map[openapi.ProtoOAQuoteType][]Tick
+	requests  int
+}
+
+func (f *fakeTicks) respond(req proto.Message) proto.Message {
+	v, ok := req.(*openapi.ProtoOAGetTickDataReq)
+	if !ok {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests++
+
+	var selected []Tick
+	for _, tick := range f.ticks[v.GetType()] {
+		if timestamp := tick.Time.UnixMilli(); timestamp >= v.GetFromTimestamp() && timestamp <= v.GetToTimestamp() {
+			selected = append(selected, tick)
+		}
+	}
+	resp := &openapi.ProtoOAGetTickDataRes{CtidTraderAccountId: v.CtidTraderAccountId, HasMore: lo.ToPtr(false)}
+	if len(selected) > f.chunkSize {
+		selected = selected[len(selected)-f.chunkSize:]
+		resp.HasMore = lo.ToPtr(true)
+	}
+	var timestamp, price int64
+	for i := len(selected) - 1; i >= 0; i-- {
+		resp.TickData = append(resp.TickData, &openapi.ProtoOATickData{
+			Timestamp: lo.ToPtr(selected[i].Time.UnixMilli() - timestamp),
+			Tick:      lo.ToPtr(int64(selected[i].Price) - price),
+		})
+		timestamp, price = selected[i].Time.UnixMilli(), int64(selected[i].Price)
+	}
+	return resp
+}
+
+func TestDecodeTicks(t *testing.T) {
+	t.Parallel()
+
+	ticks := DecodeTicks(&openapi.ProtoOAGetTickDataRes{
+		TickData: []*openapi.ProtoOATickData{
+			{Timestamp: lo.ToPtr(int64(1700000002000)), Tick: lo.ToPtr(int64(108010))},
+			{Timestamp: lo.ToPtr(int64(-500)), Tick: lo.ToPtr(int64(-5))},
+			{Timestamp: lo.ToPtr(int64(-1500)), Tick: lo.ToPtr(int64(15))},
+		},
+	})
+	require.Equal(t, []Tick{
+		{Time: time.UnixMilli(1700000000000).UTC(), Price: 108020},
+		{Time: time.UnixMilli(1700000001500).UTC(), Price: 108005},
+		{Time: time.UnixMilli(1700000002000).UTC(), Price: 108010},
+	}, ticks)
+}
+
+func TestDownloadTicks(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(36 * time.Hour)
+	newFakeTicks := func() *fakeTicks {
+		f := &fakeTicks{chunkSize: 100, ticks: make(map[openapi.ProtoOAQuoteType][]Tick)}
+		for i := 0; i < 1000; i++ {
+			at := from.Add(time.Duration(i) * 3 * time.Minute)
+			f.ticks[openapi.ProtoOAQuoteType_BID] = append(f.ticks[openapi.ProtoOAQuoteType_BID],
+				Tick{Time: at, Price: Price(100000 + i)},
+				Tick{Time: at, Price: Price(200000 + i)},
+			)
+			f.ticks[openapi.ProtoOAQuoteType_ASK] = append(f.ticks[openapi.ProtoOAQuoteType_ASK],
+				Tick{Time: at.Add(time.Minute), Price: Price(300000 + i)},
+			)
+		}
+		return f
+	}
+	start := func(t *testing.T, ticks *fakeTicks) *Account {
+		t.Helper()
+		c := newTestClient(&fakeTransport{respond: ticks.respond})
+		c.RateLimit.Disabled = true
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		return c.Account(1)
+	}
+
+	t.Run("Should download the ticks", func(t *testing.T) {
+		t.Parallel()
+		ticks := newFakeTicks()
+		account := start(t, ticks)
+
+		iterator := account.DownloadTicks(context.Background(), TickQuery{SymbolID: 1, From: from, To: to},
+			openapi.ProtoOAQuoteType_BID)
+		defer iterator.Close()
+		var result []Tick
+		for iterator.Next() {
+			result = append(result, iterator.Tick())
+		}
+		require.NoError(t, iterator.Err())
+		require.Equal(t, ticks.ticks[openapi.ProtoOAQuoteType_BID][:2*720], result)
+
+		ticks.mutex.Lock()
+		defer ticks.mutex.Unlock()
+		require.Greater(t, ticks.requests, 2*720/ticks.chunkSize)
+	})
+
+	t.Run("Should merge the quotes", func(t *testing.T) {
+		t.Parallel()
+		account := start(t, newFakeTicks())
+
+		iterator := account.DownloadQuotes(context.Background(), TickQuery{SymbolID: 1, From: from, To: to})
+		defer iterator.Close()
+		var result []Quote
+		for iterator.Next() {
+			result = append(result, iterator.Quote())
+		}
+		require.NoError(t, iterator.Err())
+
+		// The first bid has no ask yet, then there are a quote for each ask and each bid.
+		require.Len(t, result, 2*720-1)
+		require.Equal(t, Quote{Time: from.Add(time.Minute), Bid: 200000, Ask: 300000}, result[0])
+		require.Equal(t, Quote{Time: from.Add(3 * time.Minute), Bid: 200001, Ask: 300000}, result[1])
+		require.Equal(t, Quote{Time: from.Add(4 * time.Minute), Bid: 200001, Ask: 300001}, result[2])
+		for i := 1; i < len(result); i++ {
+			require.True(t, result[i].Time.After(result[i-1].Time))
+		}
+	})
+
+	t.Run("Should validate the time range", func(t *testing.T) {
+		t.Parallel()
+		account := start(t, newFakeTicks())
+		iterator := account.DownloadQuotes(context.Background(), TickQuery{SymbolID: 1, From: to, To: from})
+		require.False(t, iterator.Next())
+		require.EqualError(t, iterator.Err(), "invalid time range")
+		iterator.Close()
+	})
+}
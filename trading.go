@@ -0,0 +1,249 @@
+package ctrader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// ExecutionWait defines which execution event is returned by the trading requests.
+type ExecutionWait int
+
+const (
+	// WaitResponse returns the first execution event sent as the response, like 'ORDER_ACCEPTED'.
+	WaitResponse ExecutionWait = iota
+
+	// WaitTerminal waits until the request reaches a terminal execution type or fails with a
+	// 'ProtoOAOrderErrorEvent'. The terminal types depend on the request: 'ORDER_FILLED', 'ORDER_CANCELLED',
+	// 'ORDER_EXPIRED' or 'ORDER_REJECTED' for new orders and closed positions, 'ORDER_REPLACED' or 'ORDER_REJECTED'
+	// for the amends and 'ORDER_CANCELLED' or 'ORDER_CANCEL_REJECTED' for the cancellations. Pending orders may take
+	// a long time to get there, so the context should be used to limit the wait.
+	WaitTerminal
+)
+
+// NewOrder sends a new order. Rejected orders return the execution event together with an OrderError.
+func (c *Client) NewOrder(
+	ctx context.Context, req *openapi.ProtoOANewOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return c.trade(ctx, req, wait, orderTerminal)
+}
+
+// AmendOrder changes a pending order.
+func (c *Client) AmendOrder(
+	ctx context.Context, req *openapi.ProtoOAAmendOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return c.trade(ctx, req, wait, amendTerminal)
+}
+
+// AmendPositionSLTP changes the stop loss and take profit of a position.
+func (c *Client) AmendPositionSLTP(
+	ctx context.Context, req *openapi.ProtoOAAmendPositionSLTPReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return c.trade(ctx, req, wait, amendTerminal)
+}
+
+// CancelOrder cancels a pending order.
+func (c *Client) CancelOrder(
+	ctx context.Context, req *openapi.ProtoOACancelOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return c.trade(ctx, req, wait, cancelTerminal)
+}
+
+// ClosePosition closes the position, fully or partially. The execution events refer to the closing order created by
+// the server.
+func (c *Client) ClosePosition(
+	ctx context.Context, req *openapi.ProtoOAClosePositionReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return c.trade(ctx, req, wait, orderTerminal)
+}
+
+// NewOrder is the account scoped version of Client.NewOrder.
+func (a *Account) NewOrder(
+	ctx context.Context, req *openapi.ProtoOANewOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return accountTrade(ctx, a, req, wait, orderTerminal)
+}
+
+// AmendOrder is the account scoped version of Client.AmendOrder.
+func (a *Account) AmendOrder(
+	ctx context.Context, req *openapi.ProtoOAAmendOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return accountTrade(ctx, a, req, wait, amendTerminal)
+}
+
+// AmendPositionSLTP is the account scoped version of Client.AmendPositionSLTP.
+func (a *Account) AmendPositionSLTP(
+	ctx context.Context, req *openapi.ProtoOAAmendPositionSLTPReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return accountTrade(ctx, a, req, wait, amendTerminal)
+}
+
+// CancelOrder is the account scoped version of Client.CancelOrder.
+func (a *Account) CancelOrder(
+	ctx context.Context, req *openapi.ProtoOACancelOrderReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return accountTrade(ctx, a, req, wait, cancelTerminal)
+}
+
+// ClosePosition is the account scoped version of Client.ClosePosition.
+func (a *Account) ClosePosition(
+	ctx context.Context, req *openapi.ProtoOAClosePositionReq, wait ExecutionWait,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	return accountTrade(ctx, a, req, wait, orderTerminal)
+}
+
+func accountTrade[T proto.Message](
+	ctx context.Context, a *Account, req T, wait ExecutionWait, terminal func(openapi.ProtoOAExecutionType) bool,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	scopedReq, err := accountScope(a, req)
+	if err != nil {
+		return nil, err
+	}
+	return a.client.trade(ctx, scopedReq, wait, terminal)
+}
+
+func (c *Client) trade(
+	ctx context.Context, req proto.Message, wait ExecutionWait, terminal func(openapi.ProtoOAExecutionType) bool,
+) (*openapi.ProtoOAExecutionEvent, error) {
+	var queue executionQueue
+	if wait == WaitTerminal {
+		// The subscription starts before the request is sent, otherwise the terminal event could be lost.
+		accountID := req.(interface{ GetCtidTraderAccountId() int64 }).GetCtidTraderAccountId() //nolint:forcetypeassert
+		unsubscribeExecution := On(c, func(e *openapi.ProtoOAExecutionEvent) {
+			if e.GetCtidTraderAccountId() == accountID {
+				queue.push(e)
+			}
+		})
+		defer unsubscribeExecution()
+		unsubscribeOrderError := On(c, func(e *openapi.ProtoOAOrderErrorEvent) {
+			if e.GetCtidTraderAccountId() == accountID {
+				queue.push(e)
+			}
+		})
+		defer unsubscribeOrderError()
+	}
+
+	event, err := Command[proto.Message, *openapi.ProtoOAExecutionEvent](ctx, c, req)
+	if err != nil {
+		return nil, err
+	}
+	if wait == WaitResponse || terminal(event.GetExecutionType()) {
+		return event, executionError(event)
+	}
+
+	// The amends of the stop loss and take profit of positions don't have an order, so the position is used instead.
+	orderID, positionID := event.GetOrder().GetOrderId(), event.GetPosition().GetPositionId()
+	match := func(eventOrderID, eventPositionID int64) bool {
+		if orderID != 0 {
+			return eventOrderID == orderID
+		}
+		return eventPositionID == positionID
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return event, fmt.Errorf(
+				"context error while waiting for the order '%d' of the position '%d': %w", orderID, positionID, ctx.Err(),
+			)
+		case <-queue.signal():
+		}
+		for _, message := range queue.pop() {
+			switch e := message.(type) {
+			case *openapi.ProtoOAExecutionEvent:
+				if !match(e.GetOrder().GetOrderId(), e.GetPosition().GetPositionId()) {
+					continue
+				}
+				event = e
+				if terminal(e.GetExecutionType()) {
+					return e, executionError(e)
+				}
+			case *openapi.ProtoOAOrderErrorEvent:
+				if match(e.GetOrderId(), e.GetPositionId()) {
+					return event, newOrderError(e)
+				}
+			}
+		}
+	}
+}
+
+// orderTerminal tells if the execution type finishes a new order or the closing of a position.
+func orderTerminal(t openapi.ProtoOAExecutionType) bool {
+	switch t {
+	case openapi.ProtoOAExecutionType_ORDER_FILLED,
+		openapi.ProtoOAExecutionType_ORDER_CANCELLED,
+		openapi.ProtoOAExecutionType_ORDER_EXPIRED,
+		openapi.ProtoOAExecutionType_ORDER_REJECTED:
+		return true
+	default:
+		return false
+	}
+}
+
+// amendTerminal tells if the execution type finishes the amend of an order or of a position.
+func amendTerminal(t openapi.ProtoOAExecutionType) bool {
+	return t == openapi.ProtoOAExecutionType_ORDER_REPLACED || t == openapi.ProtoOAExecutionType_ORDER_REJECTED
+}
+
+// cancelTerminal tells if the execution type finishes the cancellation of an order.
+func cancelTerminal(t openapi.ProtoOAExecutionType) bool {
+	return t == openapi.ProtoOAExecutionType_ORDER_CANCELLED || t == openapi.ProtoOAExecutionType_ORDER_CANCEL_REJECTED
+}
+
+// executionError returns an OrderError if the execution was rejected. The execution events don't have a description.
+func executionError(e *openapi.ProtoOAExecutionEvent) error {
+	switch e.GetExecutionType() {
+	case openapi.ProtoOAExecutionType_ORDER_REJECTED, openapi.ProtoOAExecutionType_ORDER_CANCEL_REJECTED:
+		return OrderError{
+			ErrorCode:           ErrorCode(e.GetErrorCode()),
+			CtidTraderAccountID: e.GetCtidTraderAccountId(),
+			OrderID:             e.GetOrder().GetOrderId(),
+			PositionID:          e.GetPosition().GetPositionId(),
+		}
+	default:
+		return nil
+	}
+}
+
+// executionQueue is an unbounded queue of 'ProtoOAExecutionEvent' and 'ProtoOAOrderErrorEvent' messages, this way the
+// event goroutine is never blocked by a slow consumer.
+type executionQueue struct {
+	mutex  sync.Mutex
+	events []proto.Message
+	notify chan struct{}
+}
+
+func (q *executionQueue) push(e proto.Message) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.events = append(q.events, e)
+	q.init()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *executionQueue) pop() []proto.Message {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	events := q.events
+	q.events = nil
+	return events
+}
+
+func (q *executionQueue) signal() <-chan struct{} {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.init()
+	return q.notify
+}
+
+func (q *executionQueue) init() {
+	if q.notify == nil {
+		q.notify = make(chan struct{}, 1)
+	}
+}
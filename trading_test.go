@@ -0,0 +1,198 @@
+package ctrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func TestTrading(t *testing.T) {
+	t.Parallel()
+
+	start := func(t *testing.T, respond func(*fakeTransport, proto.Message) proto.Message) (*Client, *fakeTransport) {
+		transport := &fakeTransport{}
+		transport.respond = func(req proto.Message) proto.Message {
+			if respond == nil {
+				return nil
+			}
+			return respond(transport, req)
+		}
+		c := newTestClient(transport)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		return c, transport
+	}
+
+	t.Run("Should return the response execution event", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, nil)
+		event, err := c.NewOrder(context.Background(), newTestOrder(), WaitResponse)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, event.GetExecutionType())
+	})
+
+	t.Run("Should wait for the terminal execution event", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, func(transport *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOANewOrderReq); !ok {
+				return nil
+			}
+			go func() {
+				transport.event(newTestExecution(openapi.ProtoOAExecutionType_ORDER_FILLED, 11, 0))
+				transport.event(newTestExecution(openapi.ProtoOAExecutionType_ORDER_PARTIAL_FILL, 10, 0))
+				transport.event(newTestExecution(openapi.ProtoOAExecutionType_ORDER_FILLED, 10, 5))
+			}()
+			return newTestExecution(openapi.ProtoOAExecutionType_ORDER_ACCEPTED, 10, 0)
+		})
+		event, err := c.NewOrder(context.Background(), newTestOrder(), WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_FILLED, event.GetExecutionType())
+		require.Equal(t, int64(10), event.GetOrder().GetOrderId())
+		require.Equal(t, int64(5), event.GetOrder().GetPositionId())
+	})
+
+	t.Run("Should return an error when the order is rejected", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, func(_ *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOANewOrderReq); !ok {
+				return nil
+			}
+			event := newTestExecution(openapi.ProtoOAExecutionType_ORDER_REJECTED, 10, 0)
+			event.ErrorCode = lo.ToPtr(string(ErrNotEnoughMoney))
+			return event
+		})
+		event, err := c.NewOrder(context.Background(), newTestOrder(), WaitTerminal)
+		require.ErrorIs(t, err, ErrNotEnoughMoney)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_REJECTED, event.GetExecutionType())
+		var errOrder OrderError
+		require.ErrorAs(t, err, &errOrder)
+		require.Equal(t, int64(10), errOrder.OrderID)
+	})
+
+	t.Run("Should return an error from the order error event", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, func(_ *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOACancelOrderReq); !ok {
+				return nil
+			}
+			return &openapi.ProtoOAOrderErrorEvent{
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+				ErrorCode:           lo.ToPtr(string(ErrOrderNotFound)),
+				OrderId:             lo.ToPtr(int64(10)),
+			}
+		})
+		req := &openapi.ProtoOACancelOrderReq{CtidTraderAccountId: lo.ToPtr(int64(1)), OrderId: lo.ToPtr(int64(10))}
+		event, err := c.CancelOrder(context.Background(), req, WaitResponse)
+		require.ErrorIs(t, err, ErrOrderNotFound)
+		require.Nil(t, event)
+	})
+
+	t.Run("Should return the order error event received after the acceptance", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, func(transport *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOANewOrderReq); !ok {
+				return nil
+			}
+			go transport.event(&openapi.ProtoOAOrderErrorEvent{
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+				ErrorCode:           lo.ToPtr(string(ErrNotEnoughMoney)),
+				Description:         lo.ToPtr("not enough money"),
+				OrderId:             lo.ToPtr(int64(10)),
+			})
+			return newTestExecution(openapi.ProtoOAExecutionType_ORDER_ACCEPTED, 10, 0)
+		})
+		ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
+		defer ctxCancel()
+		event, err := c.NewOrder(ctx, newTestOrder(), WaitTerminal)
+		require.ErrorIs(t, err, ErrNotEnoughMoney)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, event.GetExecutionType())
+		var errOrder OrderError
+		require.ErrorAs(t, err, &errOrder)
+		require.Equal(t, "not enough money", errOrder.Description)
+	})
+
+	t.Run("Should use the terminal execution types of the request", func(t *testing.T) {
+		t.Parallel()
+		ctx, ctxCancel := context.WithTimeout(context.Background(), time.Second)
+		defer ctxCancel()
+		c, _ := start(t, func(transport *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOAAmendPositionSLTPReq); !ok {
+				return nil
+			}
+			// The server answers before the replacement, which is matched by the position as there is no order.
+			position := func(id int64) *openapi.ProtoOAPosition {
+				return newTestPosition(id, openapi.ProtoOAPositionStatus_POSITION_STATUS_OPEN, 1)
+			}
+			go func() {
+				for _, id := range []int64{6, 5} {
+					transport.event(&openapi.ProtoOAExecutionEvent{
+						CtidTraderAccountId: lo.ToPtr(int64(1)),
+						ExecutionType:       openapi.ProtoOAExecutionType_ORDER_REPLACED.Enum(),
+						Position:            position(id),
+					})
+				}
+			}()
+			return &openapi.ProtoOAExecutionEvent{
+				CtidTraderAccountId: lo.ToPtr(int64(1)),
+				ExecutionType:       openapi.ProtoOAExecutionType_ORDER_ACCEPTED.Enum(),
+				Position:            position(5),
+			}
+		})
+
+		amendSLTP := &openapi.ProtoOAAmendPositionSLTPReq{
+			CtidTraderAccountId: lo.ToPtr(int64(1)), PositionId: lo.ToPtr(int64(5)),
+		}
+		event, err := c.AmendPositionSLTP(ctx, amendSLTP, WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_REPLACED, event.GetExecutionType())
+		require.Equal(t, int64(5), event.GetPosition().GetPositionId())
+
+		amend := &openapi.ProtoOAAmendOrderReq{CtidTraderAccountId: lo.ToPtr(int64(1)), OrderId: lo.ToPtr(int64(10))}
+		event, err = c.AmendOrder(ctx, amend, WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_REPLACED, event.GetExecutionType())
+
+		cancel := &openapi.ProtoOACancelOrderReq{CtidTraderAccountId: lo.ToPtr(int64(1)), OrderId: lo.ToPtr(int64(10))}
+		event, err = c.CancelOrder(ctx, cancel, WaitTerminal)
+		require.NoError(t, err)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_CANCELLED, event.GetExecutionType())
+	})
+
+	t.Run("Should stop waiting when the context is done", func(t *testing.T) {
+		t.Parallel()
+		c, _ := start(t, func(_ *fakeTransport, req proto.Message) proto.Message {
+			if _, ok := req.(*openapi.ProtoOANewOrderReq); !ok {
+				return nil
+			}
+			return newTestExecution(openapi.ProtoOAExecutionType_ORDER_ACCEPTED, 10, 0)
+		})
+		ctx, ctxCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer ctxCancel()
+		event, err := c.NewOrder(ctx, newTestOrder(), WaitTerminal)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, openapi.ProtoOAExecutionType_ORDER_ACCEPTED, event.GetExecutionType())
+	})
+
+	t.Run("Should scope the request to the account", func(t *testing.T) {
+		t.Parallel()
+		c, transport := start(t, nil)
+		req := &openapi.ProtoOAClosePositionReq{PositionId: lo.ToPtr(int64(5)), Volume: lo.ToPtr(int64(100000))}
+		event, err := c.Account(1).ClosePosition(context.Background(), req, WaitResponse)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), event.GetOrder().GetPositionId())
+		require.Nil(t, req.CtidTraderAccountId)
+
+		sent, ok := lo.Last(transport.sent())
+		require.True(t, ok)
+		require.Equal(t, int64(1), sent.(*openapi.ProtoOAClosePositionReq).GetCtidTraderAccountId())
+
+		_, err = c.Account(2).NewOrder(context.Background(), newTestOrder(), WaitResponse)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,113 @@
+package ctrader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Replay feeds a recorded session to the client instead of connecting to the server. Only the inbound messages are
+// replayed, the application authorization and the keepalive are skipped, and requests sent during the replay fail with
+// ErrReplay. The recorded responses have their client message ID removed, this way they're delivered to the event
+// handlers together with the events, as there is no request waiting for them.
+//
+// The client state changes to StateDisconnected at the end of the records, with the read error if there is one.
+type Replay struct {
+	// Reader has the records written by a Recorder.
+	Reader io.Reader
+
+	// Speed multiplies the original pace of the messages, defaults to 1. Use 'math.Inf(1)' to replay without waiting.
+	Speed float64
+}
+
+// ErrReplay is returned by the requests sent during a replay, as there is no server to answer them.
+var ErrReplay = errors.New("requests are not sent during a replay")
+
+type transportReplay struct {
+	replay         Replay
+	finish         func(error)
+	handlerMessage func([]byte)
+	handlerError   func(error)
+	stopSignal     chan struct{}
+	wg             sync.WaitGroup
+}
+
+func (t *transportReplay) start(string) error {
+	if t.replay.Reader == nil {
+		return errors.New("replay reader is not set")
+	}
+	t.stopSignal = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if stopped, err := t.run(); !stopped {
+			t.finish(err)
+		}
+	}()
+	return nil
+}
+
+func (t *transportReplay) run() (stopped bool, err error) {
+	speed := t.replay.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	reader := NewRecordReader(t.replay.Reader)
+	var previous time.Time
+	for reader.Next() {
+		record := reader.Record()
+		if record.Direction != DirectionInbound {
+			continue
+		}
+		if !previous.IsZero() && !math.IsInf(speed, 1) {
+			if !t.wait(time.Duration(float64(record.Time.Sub(previous)) / speed)) {
+				return true, nil
+			}
+		}
+		previous = record.Time
+
+		message := record.Message
+		message.ClientMsgId = nil
+		payload, err := proto.Marshal(message)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal the message: %w", err)
+		}
+		t.handlerMessage(payload)
+	}
+	return false, reader.Err()
+}
+
+// wait returns false if the transport was stopped.
+func (t *transportReplay) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-t.stopSignal:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *transportReplay) stop() error {
+	if t.stopSignal != nil {
+		close(t.stopSignal)
+	}
+	t.wg.Wait()
+	t.stopSignal = nil
+	return nil
+}
+
+func (t *transportReplay) send([]byte) error {
+	return ErrReplay
+}
+
+func (t *transportReplay) setHandler(handlerMessage func([]byte), handlerError func(error)) {
+	t.handlerMessage = handlerMessage
+	t.handlerError = handlerError
+}
@@ -15,6 +15,7 @@ import (
 
 type transportTCP struct {
 	deadline       time.Duration
+	tlsConfig      *tls.Config
 	conn           *tls.Conn
 	reader         io.Reader
 	sendMutex      sync.Mutex
@@ -26,10 +27,12 @@ type transportTCP struct {
 
 // start should only be used after setHandlerMessage and setHandlerError functions are called.
 func (t *transportTCP) start(address string) error {
+	// The transport is reused by the reconnections, so the stop signal from the previous connection is cleared.
+	t.stopSignal.Store(false)
 	tlsDialer := net.Dialer{
 		Deadline: time.Now().Add(t.deadline),
 	}
-	conn, err := tls.DialWithDialer(&tlsDialer, "tcp", address, nil)
+	conn, err := tls.DialWithDialer(&tlsDialer, "tcp", address, t.tlsConfig)
 	if err != nil {
 		return fmt.Errorf("tls dial failed: %w", err)
 	}
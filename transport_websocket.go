@@ -0,0 +1,96 @@
+package ctrader
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type transportWebSocket struct {
+	deadline       time.Duration
+	messageType    int
+	tlsConfig      *tls.Config
+	conn           *websocket.Conn
+	sendMutex      sync.Mutex
+	wg             sync.WaitGroup
+	stopSignal     atomic.Bool
+	handlerMessage func([]byte)
+	handlerError   func(error)
+}
+
+// start should only be used after setHandlerMessage and setHandlerError functions are called. The address is a
+// WebSocket URL, like 'wss://demo.ctraderapi.com:5036'. Messages are exchanged using the frame type defined at
+// messageType, which should be binary for protobuf and text for JSON.
+func (t *transportWebSocket) start(address string) error {
+	t.stopSignal.Store(false)
+	dialer := websocket.Dialer{
+		HandshakeTimeout: t.deadline,
+		TLSClientConfig:  t.tlsConfig,
+	}
+	conn, resp, err := dialer.Dial(address, nil)
+	if err != nil {
+		return fmt.Errorf("websocket dial failed: %w", err)
+	}
+	if err = resp.Body.Close(); err != nil {
+		return fmt.Errorf("failed to close the handshake response body: %w", err)
+	}
+	t.conn = conn
+	t.receive()
+	return nil
+}
+
+func (t *transportWebSocket) stop() error {
+	t.stopSignal.Store(true)
+
+	// Unlike the TCP transport, the receive loop can't be woken up by read deadlines because a timed out WebSocket
+	// connection can't be read anymore. Closing the connection unblocks the reader instead.
+	err := t.conn.Close()
+	t.wg.Wait()
+	if err != nil {
+		return fmt.Errorf("connection close failed: %w", err)
+	}
+	return nil
+}
+
+func (t *transportWebSocket) send(payload []byte) error {
+	t.sendMutex.Lock()
+	defer t.sendMutex.Unlock()
+
+	if err := t.conn.SetWriteDeadline(time.Now().Add(t.deadline)); err != nil {
+		return fmt.Errorf("failed to set the write deadline into the connection: %w", err)
+	}
+	if err := t.conn.WriteMessage(t.messageType, payload); err != nil {
+		return fmt.Errorf("connection write failed: %w", err)
+	}
+	return nil
+}
+
+func (t *transportWebSocket) receive() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for {
+			messageType, payload, err := t.conn.ReadMessage()
+			if err != nil {
+				if t.stopSignal.Load() {
+					return
+				}
+				t.handlerError(fmt.Errorf("failed to read: %w", err))
+				return
+			}
+			if messageType != t.messageType {
+				continue
+			}
+			t.handlerMessage(payload)
+		}
+	}()
+}
+
+func (t *transportWebSocket) setHandler(handlerMessage func([]byte), handlerError func(error)) {
+	t.handlerMessage = handlerMessage
+	t.handlerError = handlerError
+}
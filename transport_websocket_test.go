@@ -0,0 +1,137 @@
+package ctrader
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+func newWebSocketServer(t *testing.T, handler func(*websocket.Conn)) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestTransportWebSocket(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should echo the messages", func(t *testing.T) {
+		t.Parallel()
+		address := newWebSocketServer(t, func(conn *websocket.Conn) {
+			for {
+				messageType, payload, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if err = conn.WriteMessage(websocket.TextMessage, []byte("ignored")); err != nil {
+					return
+				}
+				if err = conn.WriteMessage(messageType, payload); err != nil {
+					return
+				}
+			}
+		})
+
+		received := make(chan []byte, 1)
+		transport := transportWebSocket{deadline: time.Second, messageType: websocket.BinaryMessage}
+		transport.setHandler(
+			func(payload []byte) { received <- payload },
+			func(err error) { t.Errorf("unexpected error: %s", err) },
+		)
+		require.NoError(t, transport.start(address))
+		require.NoError(t, transport.send([]byte("hello")))
+		select {
+		case payload := <-received:
+			require.Equal(t, []byte("hello"), payload)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timeout waiting for the message")
+		}
+		require.NoError(t, transport.stop())
+	})
+
+	t.Run("Should report when the connection drops", func(t *testing.T) {
+		t.Parallel()
+		address := newWebSocketServer(t, func(*websocket.Conn) {})
+
+		errs := make(chan error, 1)
+		transport := transportWebSocket{deadline: time.Second, messageType: websocket.BinaryMessage}
+		transport.setHandler(func([]byte) {}, func(err error) { errs <- err })
+		require.NoError(t, transport.start(address))
+		select {
+		case err := <-errs:
+			require.ErrorContains(t, err, "failed to read")
+		case <-time.After(time.Second):
+			require.FailNow(t, "timeout waiting for the error")
+		}
+		require.NoError(t, transport.stop())
+	})
+}
+
+func TestServerAddress(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "demo.ctraderapi.com:5035", serverAddress(false, TransportTCP, EncodingProtobuf))
+	require.Equal(t, "live.ctraderapi.com:5036", serverAddress(true, TransportTCP, EncodingJSON))
+	require.Equal(t, "wss://demo.ctraderapi.com:5035", serverAddress(false, TransportWebSocket, EncodingProtobuf))
+	require.Equal(t, "wss://live.ctraderapi.com:5036", serverAddress(true, TransportWebSocket, EncodingJSON))
+}
+
+func TestClientWebSocketProtobuf(t *testing.T) {
+	t.Parallel()
+	received := make(chan int, 1)
+	address := newWebSocketServer(t, func(conn *websocket.Conn) {
+		for {
+			messageType, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message openapi.ProtoMessage
+			if messageType != websocket.BinaryMessage || proto.Unmarshal(payload, &message) != nil {
+				t.Errorf("unexpected message '%d'", messageType)
+				return
+			}
+			if message.GetPayloadType() != uint32(openapi.ProtoOAPayloadType_PROTO_OA_APPLICATION_AUTH_REQ) {
+				continue
+			}
+			received <- messageType
+			buf, err := codecProtobuf{}.encode(
+				message.GetClientMsgId(),
+				uint32(openapi.ProtoOAPayloadType_PROTO_OA_APPLICATION_AUTH_RES),
+				&openapi.ProtoOAApplicationAuthRes{},
+			)
+			if err != nil || conn.WriteMessage(websocket.BinaryMessage, buf) != nil {
+				return
+			}
+		}
+	})
+
+	c := &Client{
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Deadline:  time.Second,
+		Transport: TransportWebSocket,
+		Encoding:  EncodingProtobuf,
+		Address:   address,
+	}
+	require.NoError(t, c.Start())
+	require.Equal(t, websocket.BinaryMessage, <-received)
+	require.Equal(t, StateReady, c.State())
+	require.NoError(t, c.Stop())
+}
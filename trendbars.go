@@ -0,0 +1,154 @@
+package ctrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// TrendbarQuery selects the trendbars to download.
+type TrendbarQuery struct {
+	SymbolID int64
+	Period   openapi.ProtoOATrendbarPeriod
+
+	// From and To are the half-open range, [From, To), of the candles open time.
+	From time.Time
+	To   time.Time
+
+	// Concurrency is the number of windows downloaded at the same time, defaults to 4. The requests are still subject
+	// to the historical rate limit of the client.
+	Concurrency int
+}
+
+// CandleIterator streams the downloaded candles in order. It's used like 'bufio.Scanner' and must be closed after use:
+//
+//	candles := account.DownloadTrendbars(ctx, query)
+//	defer candles.Close()
+//	for candles.Next() {
+//		candle := candles.Candle()
+//	}
+//	if err := candles.Err(); err != nil {
+//		return err
+//	}
+type CandleIterator struct {
+	downloader *downloader[Candle]
+}
+
+// Next moves to the next candle, it returns false at the end of the candles or when an error happens.
+func (i *CandleIterator) Next() bool {
+	return i.downloader.next()
+}
+
+// Candle returns the candle read by Next.
+func (i *CandleIterator) Candle() Candle {
+	return i.downloader.current
+}
+
+// Err returns the error that stopped the iteration.
+func (i *CandleIterator) Err() error {
+	return i.downloader.err
+}
+
+// Close stops the download.
+func (i *CandleIterator) Close() {
+	i.downloader.close()
+}
+
+// DownloadTrendbars downloads the candles of a symbol. The time range is split into the largest windows accepted by
+// 'ProtoOAGetTrendbarsReq' for the period, and the windows with more trendbars than a response can hold are paginated.
+func (a *Account) DownloadTrendbars(ctx context.Context, q TrendbarQuery) *CandleIterator {
+	size, err := trendbarWindow(q.Period)
+	if err != nil {
+		return &CandleIterator{downloader: newFailedDownloader[Candle](err)}
+	}
+	if !q.From.Before(q.To) {
+		return &CandleIterator{downloader: newFailedDownloader[Candle](errors.New("invalid time range"))}
+	}
+	fetch := func(ctx context.Context, w downloadWindow) ([]Candle, error) {
+		return a.fetchTrendbars(ctx, q, w)
+	}
+	timeOf := func(c Candle) time.Time { return c.Time }
+	windows := splitWindows(q.From, q.To, size)
+	return &CandleIterator{downloader: newDownloader(ctx, windows, q.Concurrency, fetch, timeOf)}
+}
+
+// fetchTrendbars loads the candles of a window. When the response has more trendbars than the ones returned, the
+// request is repeated with the part of the window that is still missing.
+func (a *Account) fetchTrendbars(ctx context.Context, q TrendbarQuery, w downloadWindow) ([]Candle, error) {
+	var candles []Candle
+	from, to := w.from, w.to.Add(-time.Millisecond)
+	for !from.After(to) {
+		req := &openapi.ProtoOAGetTrendbarsReq{
+			FromTimestamp: proto.Int64(from.UnixMilli()),
+			ToTimestamp:   proto.Int64(to.UnixMilli()),
+			Period:        q.Period.Enum(),
+			SymbolId:      proto.Int64(q.SymbolID),
+		}
+		res, err := AccountCommand[*openapi.ProtoOAGetTrendbarsReq, *openapi.ProtoOAGetTrendbarsRes](ctx, a, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the trendbars: %w", err)
+		}
+		page, err := CandlesFromTrendbars(res)
+		if err != nil {
+			return nil, err
+		}
+		for _, candle := range page {
+			if !candle.Time.Before(w.from) && candle.Time.Before(w.to) {
+				candles = append(candles, candle)
+			}
+		}
+		if !res.GetHasMore() || len(page) == 0 {
+			break
+		}
+
+		earliest, latest := page[0].Time, page[0].Time
+		for _, candle := range page {
+			if candle.Time.Before(earliest) {
+				earliest = candle.Time
+			}
+			if candle.Time.After(latest) {
+				latest = candle.Time
+			}
+		}
+		switch {
+		case earliest.After(from):
+			to = earliest.Add(-time.Millisecond)
+		case latest.Before(to):
+			from = latest.Add(time.Millisecond)
+		default:
+			return candles, nil
+		}
+	}
+	return candles, nil
+}
+
+// trendbarWindow returns the largest time range accepted by 'ProtoOAGetTrendbarsReq' for the period.
+func trendbarWindow(period openapi.ProtoOATrendbarPeriod) (time.Duration, error) {
+	switch period {
+	case openapi.ProtoOATrendbarPeriod_M1,
+		openapi.ProtoOATrendbarPeriod_M2,
+		openapi.ProtoOATrendbarPeriod_M3,
+		openapi.ProtoOATrendbarPeriod_M4,
+		openapi.ProtoOATrendbarPeriod_M5:
+		return 302400000 * time.Millisecond, nil
+	case openapi.ProtoOATrendbarPeriod_M10,
+		openapi.ProtoOATrendbarPeriod_M15,
+		openapi.ProtoOATrendbarPeriod_M30,
+		openapi.ProtoOATrendbarPeriod_H1:
+		return 21168000000 * time.Millisecond, nil
+	case openapi.ProtoOATrendbarPeriod_H4,
+		openapi.ProtoOATrendbarPeriod_H12,
+		openapi.ProtoOATrendbarPeriod_D1:
+		return 31622400000 * time.Millisecond, nil
+	case openapi.ProtoOATrendbarPeriod_W1,
+		openapi.ProtoOATrendbarPeriod_MN1:
+		return 158112000000 * time.Millisecond, nil
+	default:
+		return 0, fmt.Errorf("unknown trendbar period '%d'", period)
+	}
+}
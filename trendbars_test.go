@@ -0,0 +1,143 @@
+package ctrader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/diegobernardes/ctrader/openapi"
+)
+
+// fakeTrendbars answers 'ProtoOAGetTrendbarsReq' with a M1 trendbar per minute. Like the server, it returns at most
+// chunkSize trendbars, the most recent ones, and sets 'hasMore' when there are more.
+type fakeTrendbars struct {
+	mutex     sync.Mutex
+	chunkSize int
+	requests  []*openapi.ProtoOAGetTrendbarsReq
+	fail      bool
+}
+
+func (f *fakeTrendbars) respond(req proto.Message) proto.Message {
+	v, ok := req.(*openapi.ProtoOAGetTrendbarsReq)
+	if !ok {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, v)
+	if f.fail {
+		return &openapi.ProtoOAErrorRes{ErrorCode: lo.ToPtr("INCORRECT_BOUNDARIES")}
+	}
+
+	resp := &openapi.ProtoOAGetTrendbarsRes{
+		CtidTraderAccountId: v.CtidTraderAccountId,
+		Period:              v.Period,
+		SymbolId:            v.SymbolId,
+	}
+	from := (v.GetFromTimestamp() + time.Minute.Milliseconds() - 1) / time.Minute.Milliseconds()
+	to := v.GetToTimestamp() / time.Minute.Milliseconds()
+	if int(to-from+1) > f.chunkSize {
+		from = to - int64(f.chunkSize) + 1
+		resp.HasMore = lo.ToPtr(true)
+	}
+	for minute := from; minute <= to; minute++ {
+		resp.Trendbar = append(resp.Trendbar, &openapi.ProtoOATrendbar{
+			Volume:                lo.ToPtr(int64(1)),
+			Low:                   lo.ToPtr(minute % 1000),
+			DeltaHigh:             lo.ToPtr(uint64(2)),
+			UtcTimestampInMinutes: lo.ToPtr(uint32(minute)),
+		})
+	}
+	return resp
+}
+
+func TestDownloadTrendbars(t *testing.T) {
+	t.Parallel()
+
+	start := func(t *testing.T, trendbars *fakeTrendbars) *Account {
+		t.Helper()
+		c := newTestClient(&fakeTransport{respond: trendbars.respond})
+		c.RateLimit.Disabled = true
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Stop()) })
+		return c.Account(1)
+	}
+
+	t.Run("Should download the candles in order", func(t *testing.T) {
+		t.Parallel()
+		trendbars := &fakeTrendbars{chunkSize: 1000}
+		account := start(t, trendbars)
+		from := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+		to := from.Add(10 * 24 * time.Hour)
+
+		candles := account.DownloadTrendbars(context.Background(), TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: from, To: to, Concurrency: 2,
+		})
+		defer candles.Close()
+		expected := from.Truncate(time.Minute).Add(time.Minute)
+		var count int
+		for candles.Next() {
+			candle := candles.Candle()
+			require.Equal(t, expected, candle.Time)
+			require.Equal(t, time.Minute, candle.Period)
+			require.Equal(t, candle.Low+2, candle.High)
+			expected = expected.Add(time.Minute)
+			count++
+		}
+		require.NoError(t, candles.Err())
+		require.Equal(t, 10*24*60, count)
+
+		trendbars.mutex.Lock()
+		defer trendbars.mutex.Unlock()
+		windows := make(map[int64]struct{})
+		for _, req := range trendbars.requests {
+			require.Equal(t, int64(1), req.GetSymbolId())
+			require.LessOrEqual(t, req.GetToTimestamp()-req.GetFromTimestamp(), int64(302400000))
+			windows[req.GetFromTimestamp()] = struct{}{}
+		}
+		require.Len(t, windows, 3)
+		require.Len(t, trendbars.requests, 17)
+	})
+
+	t.Run("Should stop the download when closed", func(t *testing.T) {
+		t.Parallel()
+		trendbars := &fakeTrendbars{chunkSize: 100}
+		account := start(t, trendbars)
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		candles := account.DownloadTrendbars(context.Background(), TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: from, To: from.Add(365 * 24 * time.Hour),
+		})
+		require.True(t, candles.Next())
+		require.Equal(t, from, candles.Candle().Time)
+		candles.Close()
+		require.NoError(t, candles.Err())
+	})
+
+	t.Run("Should return the errors", func(t *testing.T) {
+		t.Parallel()
+		trendbars := &fakeTrendbars{chunkSize: 1000, fail: true}
+		account := start(t, trendbars)
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		candles := account.DownloadTrendbars(context.Background(), TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: from, To: from.Add(30 * 24 * time.Hour),
+		})
+		require.False(t, candles.Next())
+		var protoOAError ProtoOAError
+		require.ErrorAs(t, candles.Err(), &protoOAError)
+		candles.Close()
+
+		candles = account.DownloadTrendbars(context.Background(), TrendbarQuery{
+			SymbolID: 1, Period: openapi.ProtoOATrendbarPeriod_M1, From: from, To: from,
+		})
+		require.False(t, candles.Next())
+		require.EqualError(t, candles.Err(), "invalid time range")
+		candles.Close()
+	})
+}